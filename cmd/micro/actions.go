@@ -66,13 +66,16 @@ func (v *View) Center(usePlugin bool) bool {
 		return false
 	}
 
-	v.Topline = v.Cursor.Y - v.Height/2
-	if v.Topline+v.Height > v.Buf.NumLines {
-		v.Topline = v.Buf.NumLines - v.Height
+	// The lines hidden by folds don't count
+	numLines := v.Buf.visibleLines()
+	top := v.Buf.visibleIndex(v.Cursor.Y) - v.Height/2
+	if top+v.Height > numLines {
+		top = numLines - v.Height
 	}
-	if v.Topline < 0 {
-		v.Topline = 0
+	if top < 0 {
+		top = 0
 	}
+	v.Topline = v.Buf.visibleLine(top)
 
 	if usePlugin {
 		return PostActionCall("Center", v)
@@ -80,14 +83,14 @@ func (v *View) Center(usePlugin bool) bool {
 	return true
 }
 
-// CursorUp moves the cursor up
+// CursorUp moves the cursor up, by a row of the screen with soft wrapping
 func (v *View) CursorUp(usePlugin bool) bool {
 	if usePlugin && !PreActionCall("CursorUp", v) {
 		return false
 	}
 
 	v.deselect(0)
-	v.Cursor.Up()
+	v.moveDisplayLines(v.Cursor, -1)
 
 	go v.What(usePlugin)
 
@@ -97,14 +100,14 @@ func (v *View) CursorUp(usePlugin bool) bool {
 	return true
 }
 
-// CursorDown moves the cursor down
+// CursorDown moves the cursor down, by a row of the screen with soft wrapping
 func (v *View) CursorDown(usePlugin bool) bool {
 	if usePlugin && !PreActionCall("CursorDown", v) {
 		return false
 	}
 
 	v.deselect(1)
-	v.Cursor.Down()
+	v.moveDisplayLines(v.Cursor, 1)
 
 	go v.What(usePlugin)
 
@@ -197,7 +200,7 @@ func (v *View) SelectUp(usePlugin bool) bool {
 	if !v.Cursor.HasSelection() {
 		v.Cursor.OrigSelection[0] = v.Cursor.Loc
 	}
-	v.Cursor.Up()
+	v.moveDisplayLines(v.Cursor, -1)
 	v.Cursor.SelectTo(v.Cursor.Loc)
 
 	go v.What(usePlugin)
@@ -217,7 +220,7 @@ func (v *View) SelectDown(usePlugin bool) bool {
 	if !v.Cursor.HasSelection() {
 		v.Cursor.OrigSelection[0] = v.Cursor.Loc
 	}
-	v.Cursor.Down()
+	v.moveDisplayLines(v.Cursor, 1)
 	v.Cursor.SelectTo(v.Cursor.Loc)
 
 	go v.What(usePlugin)
@@ -508,9 +511,9 @@ func (v *View) CursorStart(usePlugin bool) bool {
 
 	v.deselect(0)
 
+	v.recordJump()
 	v.Cursor.X = 0
 	v.Cursor.Y = 0
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	go v.What(usePlugin)
 
 	if usePlugin {
@@ -527,8 +530,8 @@ func (v *View) CursorEnd(usePlugin bool) bool {
 
 	v.deselect(0)
 
+	v.recordJump()
 	v.Cursor.Loc = v.Buf.End()
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	go v.What(usePlugin)
 
 	if usePlugin {
@@ -881,10 +884,18 @@ func (v *View) Save(usePlugin bool) bool {
 		return false
 	}
 
-	if v.Type == vtHelp {
-		// We can't save the help text
+	if v.Type == vtHelp || v.Type == vtScratch {
+		// We can't save the help text or scratch buffers
 		return false
 	}
+	if n := len(v.Buf.Conflicts()); n > 0 {
+		choice, canceled := messenger.YesNoPrompt("The file still has " + conflictMessage(n) + ". Save anyway? (y,n)")
+		messenger.Reset()
+		messenger.Clear()
+		if !choice || canceled {
+			return false
+		}
+	}
 	// If this is an empty buffer, ask for a filename
 	if v.Buf.Path == "" {
 		v.SaveAs(false)
@@ -1321,9 +1332,9 @@ func (v *View) GotoFile(usePlugin bool) bool {
 	}, func(message Message) {
 		var f File
 		json.Unmarshal(message.Value2, &f)
+		v.recordJump()
 		v.Buf.Save()
 		v.Open(f.Path)
-		cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 
 		// Move cursor and view if possible.
 		if message.Extra.line+1 < v.Buf.NumLines && message.Extra.line-1 >= 0 {
@@ -1371,7 +1382,6 @@ func (v *View) Start(usePlugin bool) bool {
 
 	v.Topline = 0
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("Start", v)
 	}
@@ -1534,7 +1544,7 @@ func (v *View) CallStack(usePlugin bool) bool {
 			}
 			return messages
 		}, func(message Message) {
-			cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
+			v.recordJump()
 			v.Buf.Save()
 			v.Open(strings.Split(string(message.Value2), ":")[0])
 			x, _ := strconv.Atoi(strings.Split(string(message.Value2), ":")[2])
@@ -1542,7 +1552,6 @@ func (v *View) CallStack(usePlugin bool) bool {
 			v.Buf.Cursor.X = x - 1
 			v.Buf.Cursor.Y = y - 1
 			v.Relocate()
-			cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 		}, nil, v)
 	}
 	if usePlugin {
@@ -1566,7 +1575,7 @@ func (v *View) Callers(usePlugin bool) bool {
 			}
 			return messages
 		}, func(message Message) {
-			cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
+			v.recordJump()
 			v.Buf.Save()
 			v.Open(strings.Split(string(message.Value2), ":")[0])
 			x, _ := strconv.Atoi(strings.Split(string(message.Value2), ":")[2])
@@ -1574,7 +1583,6 @@ func (v *View) Callers(usePlugin bool) bool {
 			v.Buf.Cursor.X = x - 1
 			v.Buf.Cursor.Y = y - 1
 			v.Relocate()
-			cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 		}, nil, v)
 	}
 	if usePlugin {
@@ -1600,7 +1608,7 @@ func (v *View) PointsTo(usePlugin bool) bool {
 			}
 			return messages
 		}, func(message Message) {
-			cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
+			v.recordJump()
 			v.Buf.Save()
 			v.Open(strings.Split(string(message.Value2), ":")[0])
 			x, _ := strconv.Atoi(strings.Split(string(message.Value2), ":")[2])
@@ -1608,7 +1616,6 @@ func (v *View) PointsTo(usePlugin bool) bool {
 			v.Buf.Cursor.X = x - 1
 			v.Buf.Cursor.Y = y - 1
 			v.Relocate()
-			cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 		}, nil, v)
 	}
 	if usePlugin {
@@ -1632,6 +1639,7 @@ func (v *View) Implements(usePlugin bool) bool {
 			}
 			return messages
 		}, func(message Message) {
+			v.recordJump()
 			v.Buf.Save()
 			v.Open(strings.Split(string(message.Value2), ":")[0])
 			x, _ := strconv.Atoi(strings.Split(string(message.Value2), ":")[2])
@@ -1639,7 +1647,6 @@ func (v *View) Implements(usePlugin bool) bool {
 			v.Buf.Cursor.X = x - 1
 			v.Buf.Cursor.Y = y - 1
 			v.Relocate()
-			cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 		}, nil, v)
 	}
 	if usePlugin {
@@ -1688,6 +1695,7 @@ func (v *View) Definition(usePlugin bool) bool {
 	}
 	if v.Buf.FileType() == "go" {
 		definition := getDefinition(v)
+		v.recordJump()
 		v.Buf.Save()
 		v.Open(strings.Split(definition.ObjPos, ":")[0])
 		x, _ := strconv.Atoi(strings.Split(definition.ObjPos, ":")[2])
@@ -1695,7 +1703,6 @@ func (v *View) Definition(usePlugin bool) bool {
 		v.Buf.Cursor.X = x - 1
 		v.Buf.Cursor.Y = y - 1
 		v.Relocate()
-		cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 
 		go v.What(usePlugin)
 	}
@@ -1705,30 +1712,15 @@ func (v *View) Definition(usePlugin bool) bool {
 	return true
 }
 
-// Describe describe selected syntax: definition, methods, etc
+// Describe opens the hover box with the signature, the documentation and the
+// declaration of the Go identifier under the cursor
 func (v *View) Describe(usePlugin bool) bool {
 	if usePlugin && !PreActionCall("Describe", v) {
 		return false
 	}
-	type Info struct {
-		Desc   string `json:"desc"`
-		Pos    string `json:"pos"`
-		Detail string `json:"detail"`
-		Value  struct {
-			Type   string `json:"type"`
-			Objpos string `json:"objpos"`
-		} `json:"value"`
-	}
 	if v.Buf.FileType() == "go" {
-		description := getDescription(v)
-
-		autocomplete.OpenNoPrompt(func(v *View) (messages Messages) {
-			messages = Messages{}
-			if description.Detail == "value" {
-				messages = append(messages, Message{MessageToDisplay: fmt.Sprintf("%s %s", strings.TrimSpace(description.Desc), description.Value.Type)})
-			}
-			return messages
-		}, nil, nil, v)
+		v.Cursor.Relocate()
+		v.showHover(v.Cursor.Loc, cursorGX, cursorGY, false)
 	}
 	if usePlugin {
 		return PostActionCall("Describe", v)
@@ -1873,13 +1865,12 @@ func (v *View) End(usePlugin bool) bool {
 		return false
 	}
 
-	if v.Height > v.Buf.NumLines {
+	if numLines := v.Buf.visibleLines(); v.Height > numLines {
 		v.Topline = 0
 	} else {
-		v.Topline = v.Buf.NumLines - v.Height
+		v.Topline = v.Buf.visibleLine(numLines - v.Height)
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("End", v)
 	}
@@ -1892,13 +1883,12 @@ func (v *View) PageUp(usePlugin bool) bool {
 		return false
 	}
 
-	if v.Topline > v.Height {
+	if v.Buf.visibleIndex(v.Topline) > v.Height {
 		v.ScrollUp(v.Height)
 	} else {
 		v.Topline = 0
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("PageUp", v)
 	}
@@ -1911,57 +1901,19 @@ func (v *View) PageDown(usePlugin bool) bool {
 		return false
 	}
 
-	if v.Buf.NumLines-(v.Topline+v.Height) > v.Height {
+	numLines := v.Buf.visibleLines()
+	if numLines-(v.Buf.visibleIndex(v.Topline)+v.Height) > v.Height {
 		v.ScrollDown(v.Height)
-	} else if v.Buf.NumLines >= v.Height {
-		v.Topline = v.Buf.NumLines - v.Height
+	} else if numLines >= v.Height {
+		v.Topline = v.Buf.visibleLine(numLines - v.Height)
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("PageDown", v)
 	}
 	return false
 }
 
-// NextLoc moves char to next place
-func (v *View) NextLoc(usePlugin bool) bool {
-	if usePlugin && !PreActionCall("NextLoc", v) {
-		return false
-	}
-	next := cursorLocations.GetNext()
-	if next.Path != "" {
-		v.Buf.Save()
-		v.Open(next.Path)
-	}
-	v.Buf.Cursor.X = next.X
-	v.Buf.Cursor.Y = next.Y
-	v.Buf.Cursor.Relocate()
-	if usePlugin {
-		return PostActionCall("NextLoc", v)
-	}
-	return true
-}
-
-// PrevLoc moves char to prev place
-func (v *View) PrevLoc(usePlugin bool) bool {
-	if usePlugin && !PreActionCall("PrevLoc", v) {
-		return false
-	}
-	prev := cursorLocations.GetPrev()
-	if prev.Path != "" {
-		v.Buf.Save()
-		v.Open(prev.Path)
-	}
-	v.Buf.Cursor.X = prev.X
-	v.Buf.Cursor.Y = prev.Y
-	v.Buf.Cursor.Relocate()
-	if usePlugin {
-		return PostActionCall("PrevLoc", v)
-	}
-	return true
-}
-
 // CursorPageUp places the cursor a page up
 func (v *View) CursorPageUp(usePlugin bool) bool {
 	if usePlugin && !PreActionCall("CursorPageUp", v) {
@@ -1976,7 +1928,6 @@ func (v *View) CursorPageUp(usePlugin bool) bool {
 	}
 	v.Cursor.UpN(v.Height)
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("CursorPageUp", v)
 	}
@@ -1997,7 +1948,6 @@ func (v *View) CursorPageDown(usePlugin bool) bool {
 	}
 	v.Cursor.DownN(v.Height)
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("CursorPageDown", v)
 	}
@@ -2010,13 +1960,12 @@ func (v *View) HalfPageUp(usePlugin bool) bool {
 		return false
 	}
 
-	if v.Topline > v.Height/2 {
+	if v.Buf.visibleIndex(v.Topline) > v.Height/2 {
 		v.ScrollUp(v.Height / 2)
 	} else {
 		v.Topline = 0
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("HalfPageUp", v)
 	}
@@ -2029,15 +1978,15 @@ func (v *View) HalfPageDown(usePlugin bool) bool {
 		return false
 	}
 
-	if v.Buf.NumLines-(v.Topline+v.Height) > v.Height/2 {
+	numLines := v.Buf.visibleLines()
+	if numLines-(v.Buf.visibleIndex(v.Topline)+v.Height) > v.Height/2 {
 		v.ScrollDown(v.Height / 2)
 	} else {
-		if v.Buf.NumLines >= v.Height {
-			v.Topline = v.Buf.NumLines - v.Height
+		if numLines >= v.Height {
+			v.Topline = v.Buf.visibleLine(numLines - v.Height)
 		}
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("HalfPageDown", v)
 	}
@@ -2083,10 +2032,9 @@ func (v *View) JumpLine(usePlugin bool) bool {
 	}
 	// Move cursor and view if possible.
 	if lineint < v.Buf.NumLines && lineint >= 0 {
+		v.recordJump()
 		v.Cursor.X = 0
 		v.Cursor.Y = lineint
-
-		cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 		if usePlugin {
 			return PostActionCall("JumpLine", v)
 		}
@@ -2187,8 +2135,14 @@ func (v *View) Quit(usePlugin bool) bool {
 		return false
 	}
 
-	// Make sure not to quit if there are unsaved changes
-	if v.CanClose() {
+	// Make sure not to quit if there are unsaved changes, unless another view
+	// shows the buffer
+	last := len(tabs) == 1 && len(tabs[curTab].views) == 1
+	if (len(bufferViews(v.Buf)) > 1 || v.CanClose()) && (!last || saveHiddenBuffers()) {
+		if last {
+			saveAutoSession()
+			saveJumpList()
+		}
 		v.CloseBuffer()
 		if len(tabs[curTab].views) > 1 {
 			v.splitNode.Delete()
@@ -2217,6 +2171,7 @@ func (v *View) Quit(usePlugin bool) bool {
 			screen.Fini()
 			os.Exit(0)
 		}
+		releaseBuffer(v.Buf)
 	}
 
 	if usePlugin {
@@ -2232,15 +2187,20 @@ func (v *View) QuitAll(usePlugin bool) bool {
 	}
 
 	closeAll := true
+	asked := make(map[*Buffer]bool)
 	for _, tab := range tabs {
 		for _, v := range tab.views {
-			if !v.CanClose() {
+			if !asked[v.Buf] && !v.CanClose() {
 				closeAll = false
 			}
+			asked[v.Buf] = true
 		}
 	}
+	closeAll = closeAll && saveHiddenBuffers()
 
 	if closeAll {
+		saveAutoSession()
+		saveJumpList()
 		for _, tab := range tabs {
 			for _, v := range tab.views {
 				v.CloseBuffer()
@@ -2276,7 +2236,6 @@ func (v *View) AddTab(usePlugin bool) bool {
 		}
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("AddTab", v)
 	}
@@ -2295,7 +2254,6 @@ func (v *View) PreviousTab(usePlugin bool) bool {
 		curTab = len(tabs) - 1
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("PreviousTab", v)
 	}
@@ -2314,7 +2272,6 @@ func (v *View) NextTab(usePlugin bool) bool {
 		curTab = 0
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("NextTab", v)
 	}
@@ -2329,7 +2286,6 @@ func (v *View) VSplitBinding(usePlugin bool) bool {
 
 	v.VSplit(NewBuffer(strings.NewReader(""), ""))
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("VSplit", v)
 	}
@@ -2344,7 +2300,6 @@ func (v *View) HSplitBinding(usePlugin bool) bool {
 
 	v.HSplit(NewBuffer(strings.NewReader(""), ""))
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("HSplit", v)
 	}
@@ -2366,8 +2321,6 @@ func (v *View) Unsplit(usePlugin bool) bool {
 		}
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
-
 	if usePlugin {
 		return PostActionCall("Unsplit", v)
 	}
@@ -2387,7 +2340,6 @@ func (v *View) NextSplit(usePlugin bool) bool {
 		tab.CurView = 0
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("NextSplit", v)
 	}
@@ -2407,14 +2359,13 @@ func (v *View) PreviousSplit(usePlugin bool) bool {
 		tab.CurView = len(tab.views) - 1
 	}
 
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	if usePlugin {
 		return PostActionCall("PreviousSplit", v)
 	}
 	return false
 }
 
-var curMacro []interface{}
+var curMacro Macro
 var recordingMacro bool
 
 // ToggleMacro toggles recording of a macro
@@ -2426,7 +2377,7 @@ func (v *View) ToggleMacro(usePlugin bool) bool {
 	recordingMacro = !recordingMacro
 
 	if recordingMacro {
-		curMacro = []interface{}{}
+		curMacro = nil
 		messenger.Message("Recording")
 	} else {
 		messenger.Message("Stopped recording")
@@ -2444,27 +2395,7 @@ func (v *View) PlayMacro(usePlugin bool) bool {
 		return false
 	}
 
-	for _, action := range curMacro {
-		switch t := action.(type) {
-		case rune:
-			// Insert a character
-			if v.Cursor.HasSelection() {
-				v.Cursor.DeleteSelection()
-				v.Cursor.ResetSelection()
-			}
-			v.Buf.Insert(v.Cursor.Loc, string(t))
-			v.Cursor.Right()
-
-			for pl := range loadedPlugins {
-				_, err := Call(pl+".onRune", string(t), v)
-				if err != nil && !strings.HasPrefix(err.Error(), "function does not exist") {
-					TermMessage(err)
-				}
-			}
-		case func(*View, bool) bool:
-			t(v, true)
-		}
-	}
+	curMacro.Play(v)
 
 	if usePlugin {
 		return PostActionCall("PlayMacro", v)
@@ -163,6 +163,62 @@ func PluginCmdComplete(input string) (chosen string, suggestions []string) {
 	return chosen, suggestions
 }
 
+// SessionCmdComplete autocompletes the subcommands of the session command
+func SessionCmdComplete(input string) (chosen string, suggestions []string) {
+	for _, cmd := range []string{"save", "load", "delete", "list"} {
+		if strings.HasPrefix(cmd, input) {
+			suggestions = append(suggestions, cmd)
+		}
+	}
+
+	if len(suggestions) == 1 {
+		chosen = suggestions[0]
+	}
+	return chosen, suggestions
+}
+
+// SessionNameComplete autocompletes the names of saved sessions
+func SessionNameComplete(input string) (chosen string, suggestions []string) {
+	for _, name := range ListSessions() {
+		if strings.HasPrefix(name, input) {
+			suggestions = append(suggestions, name)
+		}
+	}
+
+	if len(suggestions) == 1 {
+		chosen = suggestions[0]
+	}
+	return chosen, suggestions
+}
+
+// MacroCmdComplete autocompletes the subcommands of the macro command
+func MacroCmdComplete(input string) (chosen string, suggestions []string) {
+	for _, cmd := range []string{"save", "play", "lines", "edit", "delete", "list"} {
+		if strings.HasPrefix(cmd, input) {
+			suggestions = append(suggestions, cmd)
+		}
+	}
+
+	if len(suggestions) == 1 {
+		chosen = suggestions[0]
+	}
+	return chosen, suggestions
+}
+
+// MacroNameComplete autocompletes the names of saved macros
+func MacroNameComplete(input string) (chosen string, suggestions []string) {
+	for _, name := range ListMacros() {
+		if strings.HasPrefix(name, input) {
+			suggestions = append(suggestions, name)
+		}
+	}
+
+	if len(suggestions) == 1 {
+		chosen = suggestions[0]
+	}
+	return chosen, suggestions
+}
+
 func PluginNameComplete(input string) (chosen string, suggestions []string) {
 	for _, pp := range GetAllPluginPackages() {
 		if strings.HasPrefix(pp.Name, input) {
@@ -5,7 +5,6 @@ import (
 
 	"strconv"
 
-	"github.com/zyedidia/clipboard"
 	"github.com/zyedidia/tcell"
 )
 
@@ -229,97 +228,64 @@ func (a *AutocompletionBox) filterAutocomplete() {
 	}
 }
 
+// completionActions are the actions of the completion keymap context
+var completionActions = map[string]func(a *AutocompletionBox){
+	"Accept": func(a *AutocompletionBox) {
+		if a.AcceptEnter != nil {
+			if len(a.messagesToshow) > a.selected && len(a.messagesToshow) > 0 {
+				message := a.messagesToshow[a.selected]
+				message.Extra = a.Extra
+				f := a.AcceptEnter
+				a.Reset()
+				f(message)
+			}
+		}
+	},
+	"Complete": func(a *AutocompletionBox) {
+		if a.AcceptTab != nil {
+			if len(a.messagesToshow) > a.selected && a.selected > -1 {
+				message := a.messagesToshow[a.selected]
+				message.Extra = a.Extra
+				f := a.AcceptTab
+				a.Reset()
+				f(message)
+			}
+		}
+	},
+	"Cancel": func(a *AutocompletionBox) {
+		a.Reset()
+	},
+	"CursorUp": func(a *AutocompletionBox) {
+		if a.selected > 0 {
+			a.selected--
+		}
+	},
+	"CursorDown": func(a *AutocompletionBox) {
+		if len(a.messagesToshow)-1 > a.selected {
+			a.selected++
+		}
+	},
+}
+
 // HandleEvent handles an event for the prompter
 func (a *AutocompletionBox) HandleEvent(event tcell.Event, v *View) (swallow bool) {
-	switch e := event.(type) {
-	case *tcell.EventKey:
-		switch e.Key() {
-		case tcell.KeyEnter:
-			if a.AcceptEnter != nil {
-				if len(a.messagesToshow) > a.selected && len(a.messagesToshow) > 0 {
-					message := a.messagesToshow[a.selected]
-					message.Extra = a.Extra
-					f := a.AcceptEnter
-					a.Reset()
-					f(message)
-				}
-			}
-			return true
-		case tcell.KeyTAB:
-			if a.AcceptTab != nil {
-				if len(a.messagesToshow) > a.selected && a.selected > -1 {
-					message := a.messagesToshow[a.selected]
-					message.Extra = a.Extra
-					f := a.AcceptTab
-					a.Reset()
-					f(message)
+	e, isKey := event.(*tcell.EventKey)
+	if isKey {
+		if names := contextActions("completion", e); names != nil {
+			for _, name := range names {
+				if action, ok := completionActions[name]; ok {
+					action(a)
 				}
 			}
 			return true
-		case tcell.KeyESC:
-			a.Reset()
-			return true
-		case tcell.KeyUp:
-			if autocomplete.selected > 0 {
-				autocomplete.selected--
-			}
-			return true
-		case tcell.KeyDown:
-			if len(autocomplete.messagesToshow)-1 > autocomplete.selected {
-				autocomplete.selected++
-			}
-			return true
 		}
 	}
 	if !a.showPrompt {
 		return false
 	}
-	switch e := event.(type) {
-	case *tcell.EventKey:
-		if e.Key() != tcell.KeyRune || e.Modifiers() != 0 {
-			for key, actions := range bindings {
-				if e.Key() == key.keyCode {
-					if e.Key() == tcell.KeyRune {
-						if e.Rune() != key.r {
-							continue
-						}
-					}
-					if e.Modifiers() == key.modifiers {
-						for _, action := range actions {
-							funcName := FuncName(action)
-							switch funcName {
-							case "main.(*View).CursorLeft":
-								if a.cursorx > 0 {
-									a.cursorx--
-								}
-							case "main.(*View).CursorRight":
-								if a.cursorx < Count(a.response) {
-									a.cursorx++
-								}
-							case "main.(*View).CursorStart", "main.(*View).StartOfLine":
-								a.cursorx = 0
-							case "main.(*View).CursorEnd", "main.(*View).EndOfLine":
-								a.cursorx = Count(a.response)
-							case "main.(*View).Backspace":
-								if a.cursorx > 0 {
-									a.response = string([]rune(a.response)[:a.cursorx-1]) + string([]rune(a.response)[a.cursorx:])
-									a.cursorx--
-								}
-							case "main.(*View).Paste":
-								clip, _ := clipboard.ReadAll("clipboard")
-								a.response = Insert(a.response, a.cursorx, clip)
-								a.cursorx += Count(clip)
-							}
-						}
-					}
-				}
-			}
-		}
-		switch e.Key() {
-		case tcell.KeyRune:
-			a.response = Insert(a.response, a.cursorx, string(e.Rune()))
-			a.cursorx++
-		}
+	if isKey && !editText(globalActions(e), &a.response, &a.cursorx) && e.Key() == tcell.KeyRune {
+		a.response = Insert(a.response, a.cursorx, string(e.Rune()))
+		a.cursorx++
 	}
 	a.filterAutocomplete()
 	return true
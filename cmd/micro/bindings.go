@@ -9,104 +9,149 @@ import (
 	"github.com/zyedidia/tcell"
 )
 
-var bindings map[Key][]func(*View, bool) bool
 var helpBinding string
 
-var bindingActions = map[string]func(*View, bool) bool{
-	"CursorUp":            (*View).CursorUp,
-	"CursorDown":          (*View).CursorDown,
-	"CursorPageUp":        (*View).CursorPageUp,
-	"CursorPageDown":      (*View).CursorPageDown,
-	"CursorLeft":          (*View).CursorLeft,
-	"CursorRight":         (*View).CursorRight,
-	"CursorStart":         (*View).CursorStart,
-	"CursorEnd":           (*View).CursorEnd,
-	"SelectToStart":       (*View).SelectToStart,
-	"SelectToEnd":         (*View).SelectToEnd,
-	"SelectUp":            (*View).SelectUp,
-	"SelectDown":          (*View).SelectDown,
-	"SelectLeft":          (*View).SelectLeft,
-	"SelectRight":         (*View).SelectRight,
-	"WordRight":           (*View).WordRight,
-	"WordLeft":            (*View).WordLeft,
-	"SelectWordRight":     (*View).SelectWordRight,
-	"SelectWordLeft":      (*View).SelectWordLeft,
-	"DeleteWordRight":     (*View).DeleteWordRight,
-	"DeleteWordLeft":      (*View).DeleteWordLeft,
-	"SelectToStartOfLine": (*View).SelectToStartOfLine,
-	"SelectToEndOfLine":   (*View).SelectToEndOfLine,
-	"InsertNewline":       (*View).InsertNewline,
-	"InsertSpace":         (*View).InsertSpace,
-	"Backspace":           (*View).Backspace,
-	"Delete":              (*View).Delete,
-	"InsertTab":           (*View).InsertTab,
-	"Save":                (*View).Save,
-	"SaveAs":              (*View).SaveAs,
-	"Find":                (*View).Find,
-	"FindNext":            (*View).FindNext,
-	"FindPrevious":        (*View).FindPrevious,
-	"Center":              (*View).Center,
-	"Undo":                (*View).Undo,
-	"Redo":                (*View).Redo,
-	"Copy":                (*View).Copy,
-	"Cut":                 (*View).Cut,
-	"CutLine":             (*View).CutLine,
-	"DuplicateLine":       (*View).DuplicateLine,
-	"DeleteLine":          (*View).DeleteLine,
-	"MoveLinesUp":         (*View).MoveLinesUp,
-	"MoveLinesDown":       (*View).MoveLinesDown,
-	"IndentSelection":     (*View).IndentSelection,
-	"OutdentSelection":    (*View).OutdentSelection,
-	"OutdentLine":         (*View).OutdentLine,
-	"Paste":               (*View).Paste,
-	"PastePrimary":        (*View).PastePrimary,
-	"SelectAll":           (*View).SelectAll,
-	"OpenFile":            (*View).OpenFile,
-	"GotoFile":            (*View).GotoFile,
-	"Start":               (*View).Start,
-	"End":                 (*View).End,
-	"PageUp":              (*View).PageUp,
-	"PageDown":            (*View).PageDown,
-	"HalfPageUp":          (*View).HalfPageUp,
-	"HalfPageDown":        (*View).HalfPageDown,
-	"StartOfLine":         (*View).StartOfLine,
-	"EndOfLine":           (*View).EndOfLine,
-	"ToggleHelp":          (*View).ToggleHelp,
-	"ToggleRuler":         (*View).ToggleRuler,
-	"JumpLine":            (*View).JumpLine,
-	"ClearStatus":         (*View).ClearStatus,
-	"ShellMode":           (*View).ShellMode,
-	"CommandMode":         (*View).CommandMode,
-	"Escape":              (*View).Escape,
-	"Quit":                (*View).Quit,
-	"QuitAll":             (*View).QuitAll,
-	"AddTab":              (*View).AddTab,
-	"PreviousTab":         (*View).PreviousTab,
-	"NextTab":             (*View).NextTab,
-	"NextSplit":           (*View).NextSplit,
-	"PreviousSplit":       (*View).PreviousSplit,
-	"Unsplit":             (*View).Unsplit,
-	"VSplit":              (*View).VSplitBinding,
-	"HSplit":              (*View).HSplitBinding,
-	"ToggleMacro":         (*View).ToggleMacro,
-	"PlayMacro":           (*View).PlayMacro,
-	"Format":              (*View).Format,
-	"NextLoc":             (*View).NextLoc,
-	"PrevLoc":             (*View).PrevLoc,
-	"GotoDefinition":      (*View).Definition,
-	"Referrers":           (*View).Referrers,
-	"Describe":            (*View).Describe,
-	"Rename":              (*View).Rename,
-	"Autocomplete":        (*View).Autocomplete,
-	"GotoGutterMesssage":  (*View).GotoGutterMesssage,
-	"SelectWord":          (*View).SelectWord,
-	"What":                (*View).What,
-	"Suggest":             (*View).Suggest,
-	"Template":            (*View).Template,
-	"ExtractVariable":     (*View).ExtractVariable,
+var bindingActions map[string]func(*View, bool) bool
 
-	// This was changed to InsertNewline but I don't want to break backwards compatibility
-	"InsertEnter": (*View).InsertNewline,
+func init() {
+	bindingActions = map[string]func(*View, bool) bool{
+		"CursorUp":            (*View).CursorUp,
+		"CursorDown":          (*View).CursorDown,
+		"CursorPageUp":        (*View).CursorPageUp,
+		"CursorPageDown":      (*View).CursorPageDown,
+		"CursorLeft":          (*View).CursorLeft,
+		"CursorRight":         (*View).CursorRight,
+		"CursorStart":         (*View).CursorStart,
+		"CursorEnd":           (*View).CursorEnd,
+		"SelectToStart":       (*View).SelectToStart,
+		"SelectToEnd":         (*View).SelectToEnd,
+		"SelectUp":            (*View).SelectUp,
+		"SelectDown":          (*View).SelectDown,
+		"SelectLeft":          (*View).SelectLeft,
+		"SelectRight":         (*View).SelectRight,
+		"WordRight":           (*View).WordRight,
+		"WordLeft":            (*View).WordLeft,
+		"SelectWordRight":     (*View).SelectWordRight,
+		"SelectWordLeft":      (*View).SelectWordLeft,
+		"DeleteWordRight":     (*View).DeleteWordRight,
+		"DeleteWordLeft":      (*View).DeleteWordLeft,
+		"SelectToStartOfLine": (*View).SelectToStartOfLine,
+		"SelectToEndOfLine":   (*View).SelectToEndOfLine,
+		"InsertNewline":       (*View).InsertNewline,
+		"InsertSpace":         (*View).InsertSpace,
+		"Backspace":           (*View).Backspace,
+		"Delete":              (*View).Delete,
+		"InsertTab":           (*View).InsertTab,
+		"Save":                (*View).Save,
+		"SaveAs":              (*View).SaveAs,
+		"Find":                (*View).Find,
+		"FindNext":            (*View).FindNext,
+		"FindPrevious":        (*View).FindPrevious,
+		"Center":              (*View).Center,
+		"Undo":                (*View).Undo,
+		"Redo":                (*View).Redo,
+		"Copy":                (*View).Copy,
+		"Cut":                 (*View).Cut,
+		"CutLine":             (*View).CutLine,
+		"DuplicateLine":       (*View).DuplicateLine,
+		"DeleteLine":          (*View).DeleteLine,
+		"MoveLinesUp":         (*View).MoveLinesUp,
+		"MoveLinesDown":       (*View).MoveLinesDown,
+		"IndentSelection":     (*View).IndentSelection,
+		"OutdentSelection":    (*View).OutdentSelection,
+		"OutdentLine":         (*View).OutdentLine,
+		"Paste":               (*View).Paste,
+		"PastePrimary":        (*View).PastePrimary,
+		"SelectAll":           (*View).SelectAll,
+		"OpenFile":            (*View).OpenFile,
+		"GotoFile":            (*View).GotoFile,
+		"Start":               (*View).Start,
+		"End":                 (*View).End,
+		"PageUp":              (*View).PageUp,
+		"PageDown":            (*View).PageDown,
+		"HalfPageUp":          (*View).HalfPageUp,
+		"HalfPageDown":        (*View).HalfPageDown,
+		"StartOfLine":         (*View).StartOfLine,
+		"EndOfLine":           (*View).EndOfLine,
+		"StartOfDisplayLine":  (*View).StartOfDisplayLine,
+		"EndOfDisplayLine":    (*View).EndOfDisplayLine,
+		"ToggleHelp":          (*View).ToggleHelp,
+		"ToggleRuler":         (*View).ToggleRuler,
+		"JumpLine":            (*View).JumpLine,
+		"ClearStatus":         (*View).ClearStatus,
+		"ShellMode":           (*View).ShellMode,
+		"CommandMode":         (*View).CommandMode,
+		"CommandPalette":      (*View).CommandPalette,
+		"Escape":              (*View).Escape,
+		"Quit":                (*View).Quit,
+		"QuitAll":             (*View).QuitAll,
+		"AddTab":              (*View).AddTab,
+		"PreviousTab":         (*View).PreviousTab,
+		"NextTab":             (*View).NextTab,
+		"NextSplit":           (*View).NextSplit,
+		"PreviousSplit":       (*View).PreviousSplit,
+		"Unsplit":             (*View).Unsplit,
+		"VSplit":              (*View).VSplitBinding,
+		"HSplit":              (*View).HSplitBinding,
+		"ToggleMacro":         (*View).ToggleMacro,
+		"PlayMacro":           (*View).PlayMacro,
+		"Format":              (*View).Format,
+		"NextLoc":             (*View).NextLoc,
+		"PrevLoc":             (*View).PrevLoc,
+		"JumpList":            (*View).JumpList,
+		"PrevChange":          (*View).PrevChange,
+		"NextChange":          (*View).NextChange,
+		"ChangeList":          (*View).ChangeList,
+		"SetBookmark":         (*View).SetBookmark,
+		"GotoBookmark":        (*View).GotoBookmark,
+		"DeleteBookmarks":     (*View).DeleteBookmarks,
+		"BookmarkList":        (*View).BookmarkList,
+		"Fold":                (*View).Fold,
+		"Unfold":              (*View).Unfold,
+		"ToggleFold":          (*View).ToggleFold,
+		"FoldAll":             (*View).FoldAll,
+		"UnfoldAll":           (*View).UnfoldAll,
+		"BufferList":          (*View).BufferList,
+		"GrowSplitWidth":      (*View).GrowSplitWidth,
+		"ShrinkSplitWidth":    (*View).ShrinkSplitWidth,
+		"GrowSplitHeight":     (*View).GrowSplitHeight,
+		"ShrinkSplitHeight":   (*View).ShrinkSplitHeight,
+		"EqualizeSplits":      (*View).EqualizeSplits,
+		"ZoomSplit":           (*View).ZoomSplit,
+		"SwapSplit":           (*View).SwapSplit,
+		"RotateSplits":        (*View).RotateSplits,
+		"GotoDefinition":      (*View).Definition,
+		"Referrers":           (*View).Referrers,
+		"Describe":            (*View).Describe,
+		"Rename":              (*View).Rename,
+		"Autocomplete":        (*View).Autocomplete,
+		"GotoGutterMesssage":  (*View).GotoGutterMesssage,
+		"SelectWord":          (*View).SelectWord,
+		"What":                (*View).What,
+		"Suggest":             (*View).Suggest,
+		"Template":            (*View).Template,
+		"ExtractVariable":     (*View).ExtractVariable,
+		"NextHunk":            (*View).NextHunk,
+		"PreviousHunk":        (*View).PreviousHunk,
+		"PreviewHunk":         (*View).PreviewHunk,
+		"RevertHunk":          (*View).RevertHunk,
+		"StageHunk":           (*View).StageHunk,
+		"DiffGet":             (*View).DiffGet,
+		"DiffPut":             (*View).DiffPut,
+		"ConflictTakeOurs":    (*View).ConflictTakeOurs,
+		"ConflictTakeTheirs":  (*View).ConflictTakeTheirs,
+		"ConflictTakeBoth":    (*View).ConflictTakeBoth,
+		"ConflictEdit":        (*View).ConflictEdit,
+		"NextConflict":        (*View).NextConflict,
+		"PreviousConflict":    (*View).PreviousConflict,
+		"GotoSymbol":          (*View).GotoSymbol,
+		"GotoWorkspaceSymbol": (*View).GotoWorkspaceSymbol,
+		"CallHierarchy":       (*View).CallHierarchy,
+		"TypeHierarchy":       (*View).TypeHierarchy,
+
+		// This was changed to InsertNewline but I don't want to break backwards compatibility
+		"InsertEnter": (*View).InsertNewline,
+	}
 }
 
 var bindingKeys = map[string]tcell.Key{
@@ -248,9 +293,11 @@ type Key struct {
 
 // InitBindings initializes the keybindings for micro
 func InitBindings() {
-	bindings = make(map[Key][]func(*View, bool) bool)
+	globalKeyMap = newKeyMap()
+	filetypeKeyMaps = make(map[string]*keyMap)
+	viewTypeKeyMaps = make(map[string]*keyMap)
 
-	var parsed map[string]string
+	var parsed map[string]interface{}
 	defaults := DefaultBindings()
 
 	filename := configDir + "/bindings.json"
@@ -268,7 +315,22 @@ func InitBindings() {
 	}
 
 	parseBindings(defaults)
-	parseBindings(parsed)
+	initContextBindings()
+
+	// Plain values are global bindings, objects are filetype or view type maps
+	userBindings := make(map[string]string)
+	for k, v := range parsed {
+		if s, ok := v.(string); ok {
+			userBindings[k] = s
+		}
+	}
+	errs := duplicateKeys(userBindings)
+	parseBindings(userBindings)
+	errs = append(errs, parseScopedBindings(parsed)...)
+	errs = append(errs, bindingConflicts()...)
+	if len(errs) > 0 {
+		TermMessage("Conflicting or invalid keybindings in bindings.json:\n" + strings.Join(errs, "\n"))
+	}
 }
 
 func parseBindings(userBindings map[string]string) {
@@ -343,6 +405,9 @@ modSearch:
 
 // findAction will find 'action' using string 'v'
 func findAction(v string) (action func(*View, bool) bool) {
+	if strings.HasPrefix(v, macroPrefix) {
+		return macroBinding(strings.TrimPrefix(v, macroPrefix))
+	}
 	action, ok := bindingActions[v]
 	if !ok {
 		// If the user seems to be binding a function that doesn't exist
@@ -353,10 +418,11 @@ func findAction(v string) (action func(*View, bool) bool) {
 }
 
 // BindKey takes a key and an action and binds the two together
+// The key can also be a sequence of keys separated by spaces
 func BindKey(k, v string) {
-	key, ok := findKey(k)
-	if !ok {
-		TermMessage("Unknown keybinding: " + k)
+	seq, err := parseKeySequence(k)
+	if err != nil {
+		TermMessage(err.Error())
 		return
 	}
 	if v == "ToggleHelp" {
@@ -366,21 +432,7 @@ func BindKey(k, v string) {
 		helpBinding = ""
 	}
 
-	actionNames := strings.Split(v, ",")
-	if actionNames[0] == "UnbindKey" {
-		delete(bindings, key)
-		if len(actionNames) == 1 {
-			actionNames = make([]string, 0, 0)
-		} else {
-			actionNames = append(actionNames[:0], actionNames[1:]...)
-		}
-	}
-	actions := make([]func(*View, bool) bool, 0, len(actionNames))
-	for _, actionName := range actionNames {
-		actions = append(actions, findAction(actionName))
-	}
-
-	bindings[key] = actions
+	globalKeyMap.bind(seq, strings.Split(v, ","))
 }
 
 // DefaultBindings returns a map containing micro's default keybindings
@@ -438,6 +490,11 @@ func DefaultBindings() map[string]string {
 		"CtrlB":          "ShellMode",
 		"CtrlQ":          "Quit",
 		"CtrlE":          "CommandMode",
+		"Alt-x":          "CommandPalette",
+		"Alt-o":          "GotoSymbol",
+		"Alt-w":          "GotoWorkspaceSymbol",
+		"Alt-h":          "CallHierarchy",
+		"Alt-t":          "TypeHierarchy",
 		"CtrlW":          "SelectWord",
 		"CtrlU":          "ToggleMacro",
 		"CtrlJ":          "PlayMacro",
@@ -451,6 +508,20 @@ func DefaultBindings() map[string]string {
 		"CtrlN":     "GotoFile",
 		"AltRight":  "NextLoc",
 		"AltLeft":   "PrevLoc",
+		"Alt-j":     "JumpList",
+		"Alt-,":     "PrevChange",
+		"Alt-.":     "NextChange",
+		"Alt-m":     "SetBookmark",
+		"Alt-'":     "GotoBookmark",
+		"Alt-k":     "BookmarkList",
+		"Alt-z":     "ToggleFold",
+		"Alt-u":     "BufferList",
+		"Alt->":     "GrowSplitWidth",
+		"Alt-<":     "ShrinkSplitWidth",
+		"Alt-+":     "GrowSplitHeight",
+		"Alt-_":     "ShrinkSplitHeight",
+		"Alt-=":     "EqualizeSplits",
+		"Alt-Z":     "ZoomSplit",
 		"F4":        "GotoDefinition",
 		"F6":        "Rename",
 		"F7":        "Referrers",
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A BlameLine holds the commit which last changed a line of a file
+type BlameLine struct {
+	Hash    string
+	Author  string
+	Time    time.Time
+	Summary string
+}
+
+// Committed returns whether the line is part of a commit
+// Lines which were changed in the working tree have a hash of only zeros
+func (b BlameLine) Committed() bool {
+	return strings.Trim(b.Hash, "0") != ""
+}
+
+// String formats the blame information for display in the blame view
+func (b BlameLine) String() string {
+	if !b.Committed() {
+		return "Not committed yet"
+	}
+	author := []rune(b.Author)
+	if len(author) > 16 {
+		author = author[:16]
+	}
+	return fmt.Sprintf("%s %s %s", b.Hash[:8], b.Time.Format("2006-01-02"), string(author))
+}
+
+// ParseBlame parses the output of git blame --porcelain into one BlameLine per line of the file
+func ParseBlame(out string) []BlameLine {
+	commits := make(map[string]*BlameLine)
+	var lines []BlameLine
+	var cur *BlameLine
+	for _, l := range strings.Split(out, "\n") {
+		if strings.HasPrefix(l, "\t") {
+			// The content of the line ends the entry
+			if cur != nil {
+				lines = append(lines, *cur)
+			}
+			continue
+		}
+		fields := strings.SplitN(l, " ", 2)
+		if len(fields) == 2 && isCommitHash(fields[0]) {
+			if c, ok := commits[fields[0]]; ok {
+				cur = c
+			} else {
+				cur = &BlameLine{Hash: fields[0]}
+				commits[fields[0]] = cur
+			}
+			continue
+		}
+		if cur == nil || len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "author":
+			cur.Author = fields[1]
+		case "author-time":
+			if sec, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				cur.Time = time.Unix(sec, 0)
+			}
+		case "summary":
+			cur.Summary = fields[1]
+		}
+	}
+	return lines
+}
+
+// isCommitHash returns whether s is a full SHA-1 or SHA-256 commit hash
+func isCommitHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// GitBlame returns the blame information for the given text of the file at path
+// The text is passed to git so unsaved changes are taken into account
+func GitBlame(root, path, text string) ([]BlameLine, error) {
+	out, err := gitCommand(root, text, "blame", "--porcelain", "--contents", "-", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBlame(out), nil
+}
+
+// A GitLogEntry is a commit in the history of a file
+type GitLogEntry struct {
+	Hash    string
+	Date    string
+	Author  string
+	Subject string
+	// The path of the file in this commit, which differs from the current path
+	// if the file was renamed
+	Path string
+}
+
+// String formats the log entry for display in the log view
+func (e GitLogEntry) String() string {
+	return fmt.Sprintf("%s %s %-16s %s", e.Hash, e.Date, e.Author, e.Subject)
+}
+
+// GitFileLog returns the history of the file at path, following renames
+func GitFileLog(root, path string) ([]GitLogEntry, error) {
+	out, err := gitCommand(root, "", "log", "--follow", "--date=short", "--name-only",
+		"--format=%x1e%h%x1f%ad%x1f%an%x1f%s", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []GitLogEntry
+	for _, commit := range strings.Split(out, "\x1e") {
+		lines := strings.Split(strings.TrimSpace(commit), "\n")
+		fields := strings.Split(lines[0], "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		entry := GitLogEntry{fields[0], fields[1], fields[2], fields[3], path}
+		for _, l := range lines[1:] {
+			if l = strings.TrimSpace(l); l != "" {
+				entry.Path = l
+				break
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// viewIsOpen returns whether the view is still displayed in one of the tabs
+func viewIsOpen(v *View) bool {
+	for _, t := range tabs {
+		for _, view := range t.views {
+			if view == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gitFileOf returns the repository and path of the file in the view
+// displaying an error if the file is not in a repository
+func gitFileOf(v *View) (string, string, bool) {
+	if v.Buf.Path == "" {
+		messenger.Error("This buffer has no file")
+		return "", "", false
+	}
+	root, path, err := GitRepoFile(v.Buf.AbsPath)
+	if err != nil {
+		messenger.Error("Not in a git repository: ", err.Error())
+		return "", "", false
+	}
+	return root, path, true
+}
+
+// Blame opens a vertical split next to the current view which shows the commit,
+// author and date of each line
+// Pressing enter on a line opens the diff of its commit in a new tab
+func Blame(args []string) {
+	v := CurView()
+	root, path, ok := gitFileOf(v)
+	if !ok {
+		return
+	}
+	blame, err := GitBlame(root, path, v.Buf.String())
+	if err != nil {
+		messenger.Error("git blame: ", err.Error())
+		return
+	}
+
+	lines := make([]string, len(blame))
+	width := 0
+	for i, b := range blame {
+		lines[i] = b.String()
+		width = Max(width, Count(lines[i]))
+	}
+	buf := NewScratchBuffer(strings.Join(lines, "\n"), "Blame "+path, "Unknown")
+	buf.Settings["ruler"] = false
+
+	v.VSplit(buf)
+	bv := CurView()
+	bv.Type = vtScratch
+	bv.Width = width + 2
+	bv.LockWidth = true
+	tabs[curTab].Resize()
+	LinkViews(v, bv)
+
+	bv.lineAction = func(bv *View, line int) {
+		if line >= len(blame) {
+			return
+		}
+		if !blame[line].Committed() {
+			messenger.Message("This line is not committed yet")
+			return
+		}
+		messenger.Message(blame[line].Summary)
+		showGitCommit(root, blame[line].Hash)
+	}
+}
+
+// showGitCommit opens the diff of the given commit in a new tab
+func showGitCommit(root, hash string) {
+	out, err := gitCommand(root, "", "show", "--stat", "--patch", hash)
+	if err != nil {
+		messenger.Error("git show: ", err.Error())
+		return
+	}
+	AddTabWithBuffer(NewScratchBuffer(out, hash[:8], "patch"))
+	CurView().Type = vtScratch
+}
+
+// GitLog opens a list of the commits which changed the current file
+// Pressing enter on a commit opens the file as it was in that commit next to the current view
+func GitLog(args []string) {
+	v := CurView()
+	root, path, ok := gitFileOf(v)
+	if !ok {
+		return
+	}
+	entries, err := GitFileLog(root, path)
+	if err != nil {
+		messenger.Error("git log: ", err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		messenger.Message("No commits for ", path)
+		return
+	}
+
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+	filetype := v.Buf.FileType()
+	buf := NewScratchBuffer(strings.Join(lines, "\n"), "Log "+path, "Unknown")
+	buf.Settings["ruler"] = false
+
+	v.HSplit(buf)
+	lv := CurView()
+	lv.Type = vtScratch
+	lv.lineAction = func(lv *View, line int) {
+		if line >= len(entries) {
+			return
+		}
+		e := entries[line]
+		out, err := gitCommand(root, "", "show", e.Hash+":"+e.Path)
+		if err != nil {
+			messenger.Error("git show: ", err.Error())
+			return
+		}
+		rev := NewScratchBuffer(out, e.Hash+":"+e.Path, filetype)
+		if viewIsOpen(v) {
+			v.VSplit(rev)
+		} else {
+			lv.VSplit(rev)
+		}
+		CurView().Type = vtScratch
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitBlame(t *testing.T) {
+	dir, cleanup := newTestRepo(t, "file.txt", "one\ntwo\n")
+	defer cleanup()
+
+	blame, err := GitBlame(dir, "file.txt", "one\nchanged\ntwo\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blame) != 3 {
+		t.Fatalf("GitBlame returned %d lines, want 3", len(blame))
+	}
+	for i, want := range []bool{true, false, true} {
+		if blame[i].Committed() != want {
+			t.Errorf("line %d committed = %v, want %v", i, blame[i].Committed(), want)
+		}
+	}
+	if blame[0].Author != "test" || blame[0].Summary != "initial" {
+		t.Errorf("unexpected blame for line 0: %+v", blame[0])
+	}
+	if blame[0].Hash != blame[2].Hash {
+		t.Errorf("lines 0 and 2 should have the same commit")
+	}
+}
+
+func TestGitFileLog(t *testing.T) {
+	dir, cleanup := newTestRepo(t, "old.txt", "one\n")
+	defer cleanup()
+
+	if _, err := gitCommand(dir, "", "mv", "old.txt", "new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("one\ntwo\n"), 0644)
+	for _, args := range [][]string{
+		{"add", "new.txt"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "rename"},
+	} {
+		if _, err := gitCommand(dir, "", args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := GitFileLog(dir, "new.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("GitFileLog returned %d entries, want 2", len(entries))
+	}
+	if entries[0].Subject != "rename" || entries[0].Path != "new.txt" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Subject != "initial" || entries[1].Path != "old.txt" {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/zyedidia/tcell"
+)
+
+// A Bookmark is a named location in a file
+// Bookmarks named a to z belong to their file, and bookmarks named A to Z are
+// global: going to one opens its file
+type Bookmark struct {
+	Name string
+	Path string
+	Loc  Loc
+}
+
+// The bookmarks of each file by absolute path, and the global bookmarks
+var (
+	fileBookmarks   = make(map[string]map[rune]*Mark)
+	globalBookmarks = make(map[rune]*Mark)
+)
+
+// savedBookmarkLocs holds the location of each bookmark in the saved text of
+// its file, which is the one stored; a bookmark set in a modified buffer has
+// none until the buffer is saved
+var savedBookmarkLocs = make(map[*Mark]Loc)
+
+// bookmarkName returns the letter naming a bookmark and whether it is global
+func bookmarkName(name string) (rune, bool, error) {
+	if len(name) == 1 {
+		r := rune(name[0])
+		if r >= 'a' && r <= 'z' {
+			return r, false, nil
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r, true, nil
+		}
+	}
+	return 0, false, errors.New("Invalid bookmark name " + name)
+}
+
+// bookmarkLetters returns the letters bookmarks can be named with
+func bookmarkLetters() []rune {
+	var letters []rune
+	for r := 'a'; r <= 'z'; r++ {
+		letters = append(letters, r, r-'a'+'A')
+	}
+	return letters
+}
+
+// AddBookmark sets the bookmark with the given name at a location of a file,
+// moving it if it was set already
+func AddBookmark(name, path string, loc Loc) error {
+	r, global, err := bookmarkName(name)
+	if err != nil {
+		return err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	setBookmark(r, global, path, loc)
+	return saveBookmarks()
+}
+
+// setBookmark sets a bookmark without saving the bookmarks
+func setBookmark(r rune, global bool, path string, loc Loc) {
+	removeBookmark(r, path)
+	m := NewMark(path, loc)
+	if b := findBuffer(path); b == nil || !b.IsModified {
+		savedBookmarkLocs[m] = loc
+	}
+	if global {
+		globalBookmarks[r] = m
+		return
+	}
+	if fileBookmarks[path] == nil {
+		fileBookmarks[path] = make(map[rune]*Mark)
+	}
+	fileBookmarks[path][r] = m
+}
+
+// GetBookmark returns the bookmark with the given name, or nil if it is not set
+// The path is only used for the bookmarks named a to z
+func GetBookmark(name, path string) *Mark {
+	r, global, err := bookmarkName(name)
+	if err != nil {
+		return nil
+	}
+	if global {
+		return globalBookmarks[r]
+	}
+	path, _ = filepath.Abs(path)
+	return fileBookmarks[path][r]
+}
+
+// RemoveBookmark deletes the bookmark with the given name and returns whether
+// it was set
+func RemoveBookmark(name, path string) bool {
+	r, _, err := bookmarkName(name)
+	if err != nil {
+		return false
+	}
+	path, _ = filepath.Abs(path)
+	if !removeBookmark(r, path) {
+		return false
+	}
+	saveBookmarks()
+	return true
+}
+
+// removeBookmark deletes a bookmark without saving the bookmarks
+func removeBookmark(r rune, path string) bool {
+	m, ok := globalBookmarks[r]
+	if ok {
+		delete(globalBookmarks, r)
+	} else if m, ok = fileBookmarks[path][r]; ok {
+		delete(fileBookmarks[path], r)
+		if len(fileBookmarks[path]) == 0 {
+			delete(fileBookmarks, path)
+		}
+	}
+	if ok {
+		m.Delete()
+		delete(savedBookmarkLocs, m)
+	}
+	return ok
+}
+
+// pathBookmarks returns the bookmarks in a file by letter, global ones included
+func pathBookmarks(path string) map[rune]*Mark {
+	marks := make(map[rune]*Mark)
+	for r, m := range fileBookmarks[path] {
+		marks[r] = m
+	}
+	for r, m := range globalBookmarks {
+		if m.Path == path {
+			marks[r] = m
+		}
+	}
+	return marks
+}
+
+// saveFileBookmarks stores the bookmarks of a file where they are in its text
+// once it was saved
+func saveFileBookmarks(path string) {
+	marks := pathBookmarks(path)
+	if len(marks) == 0 {
+		return
+	}
+	for _, m := range marks {
+		savedBookmarkLocs[m] = m.Loc
+	}
+	saveBookmarks()
+}
+
+// revertBookmarks puts the bookmarks of a file whose changes were discarded
+// back where they are in the saved file, and deletes those which were set
+// since it was last saved
+func revertBookmarks(path string) {
+	for r, m := range pathBookmarks(path) {
+		if loc, ok := savedBookmarkLocs[m]; ok {
+			m.Loc = loc
+		} else {
+			removeBookmark(r, path)
+		}
+	}
+}
+
+// renameBookmarks files the bookmarks of a file or of the files in a
+// directory under their new path after a rename, once renameMarks moved them
+func renameBookmarks(from, to string) {
+	var paths []string
+	for path := range fileBookmarks {
+		if inPath(path, from) {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range paths {
+		fileBookmarks[to+path[len(from):]] = fileBookmarks[path]
+		delete(fileBookmarks, path)
+	}
+	saveBookmarks()
+}
+
+// removeBookmarks deletes the bookmarks of a deleted file or of the files in
+// a deleted directory
+func removeBookmarks(dir string) {
+	for r, m := range globalBookmarks {
+		if inPath(m.Path, dir) {
+			removeBookmark(r, m.Path)
+		}
+	}
+	for path, marks := range fileBookmarks {
+		if inPath(path, dir) {
+			for r := range marks {
+				removeBookmark(r, path)
+			}
+		}
+	}
+	saveBookmarks()
+}
+
+// ListBookmarks returns all the bookmarks, the global ones first, then those
+// of each file sorted by path
+func ListBookmarks() []Bookmark {
+	var list []Bookmark
+	for r, m := range globalBookmarks {
+		list = append(list, Bookmark{string(r), m.Path, m.Loc})
+	}
+	for _, marks := range fileBookmarks {
+		for r, m := range marks {
+			list = append(list, Bookmark{string(r), m.Path, m.Loc})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if ga, gb := a.Name < "a", b.Name < "a"; ga != gb {
+			return ga
+		}
+		if a.Path != b.Path && a.Name >= "a" {
+			return a.Path < b.Path
+		}
+		return a.Name < b.Name
+	})
+	return list
+}
+
+// bookmarksPath returns the file the bookmarks are kept in
+func bookmarksPath() string {
+	return filepath.Join(configDir, "bookmarks")
+}
+
+// saveBookmarks stores the bookmarks for the next time micro starts, at their
+// location in the saved text of their file
+func saveBookmarks() error {
+	var list []Bookmark
+	for _, b := range ListBookmarks() {
+		m := GetBookmark(b.Name, b.Path)
+		if loc, ok := savedBookmarkLocs[m]; ok {
+			list = append(list, Bookmark{b.Name, b.Path, loc})
+		}
+	}
+	file, err := os.Create(bookmarksPath())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return gob.NewEncoder(file).Encode(list)
+}
+
+// loadBookmarks reads the bookmarks stored by saveBookmarks
+func loadBookmarks() {
+	file, err := os.Open(bookmarksPath())
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	var list []Bookmark
+	if gob.NewDecoder(file).Decode(&list) != nil {
+		return
+	}
+	for _, b := range list {
+		if r, global, err := bookmarkName(b.Name); err == nil {
+			setBookmark(r, global, b.Path, b.Loc)
+		}
+	}
+}
+
+// bookmarkLines returns the name of the bookmark shown in the gutter for each
+// line of the buffer which has one
+func (b *Buffer) bookmarkLines() map[int]rune {
+	lines := make(map[int]rune)
+	add := func(r rune, m *Mark) {
+		if cur, ok := lines[m.Loc.Y]; !ok || r < cur {
+			lines[m.Loc.Y] = r
+		}
+	}
+	for r, m := range fileBookmarks[b.AbsPath] {
+		add(r, m)
+	}
+	for r, m := range globalBookmarks {
+		if m.Path == b.AbsPath {
+			add(r, m)
+		}
+	}
+	return lines
+}
+
+// bookmarkStyle returns the style of the bookmark names in the gutter
+func bookmarkStyle() tcell.Style {
+	if style, ok := colorscheme["bookmark"]; ok {
+		return style
+	}
+	return defStyle.Foreground(tcell.ColorBlue)
+}
+
+// SetBookmark asks for a letter and sets the bookmark with that name at the
+// cursor
+func (v *View) SetBookmark(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("SetBookmark", v) {
+		return false
+	}
+
+	if v.Buf.Path == "" {
+		messenger.Error("Save the file before setting a bookmark")
+	} else if r, canceled := messenger.LetterPrompt("Set bookmark (a-z in this file, A-Z global): ", bookmarkLetters()...); !canceled {
+		if err := AddBookmark(string(r), v.Buf.AbsPath, v.Cursor.Loc); err != nil {
+			messenger.Error("Could not save the bookmarks: ", err)
+		} else {
+			messenger.Message("Set bookmark ", string(r))
+		}
+	}
+
+	if usePlugin {
+		return PostActionCall("SetBookmark", v)
+	}
+	return true
+}
+
+// GotoBookmark asks for the name of a bookmark and jumps to it
+func (v *View) GotoBookmark(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("GotoBookmark", v) {
+		return false
+	}
+
+	if r, canceled := messenger.LetterPrompt("Go to bookmark: ", bookmarkLetters()...); !canceled {
+		if m := GetBookmark(string(r), v.Buf.AbsPath); m == nil {
+			messenger.Error("Bookmark ", string(r), " is not set")
+		} else {
+			v.recordJump()
+			v.gotoMark(m)
+		}
+	}
+
+	if usePlugin {
+		return PostActionCall("GotoBookmark", v)
+	}
+	return true
+}
+
+// DeleteBookmarks deletes the bookmarks on the line of the cursor
+func (v *View) DeleteBookmarks(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("DeleteBookmarks", v) {
+		return false
+	}
+
+	deleted := false
+	for _, b := range ListBookmarks() {
+		if b.Path == v.Buf.AbsPath && b.Loc.Y == v.Cursor.Y {
+			deleted = RemoveBookmark(b.Name, b.Path) || deleted
+		}
+	}
+	if !deleted {
+		messenger.Error("No bookmark on this line")
+	}
+
+	if usePlugin {
+		return PostActionCall("DeleteBookmarks", v)
+	}
+	return true
+}
+
+// BookmarkList opens a fuzzy finder of the bookmarks of all files, with the
+// line of each
+func (v *View) BookmarkList(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("BookmarkList", v) {
+		return false
+	}
+
+	list := ListBookmarks()
+	if len(list) == 0 {
+		messenger.Message("No bookmark is set")
+	} else {
+		marks := make([]*Mark, len(list))
+		labels := make([]string, len(list))
+		for i, b := range list {
+			marks[i] = GetBookmark(b.Name, b.Path)
+			labels[i] = b.Name + " "
+		}
+		v.openMarkPicker(marks, labels, func(i int) {
+			v.recordJump()
+			v.gotoMark(marks[i])
+		})
+	}
+
+	if usePlugin {
+		return PostActionCall("BookmarkList", v)
+	}
+	return true
+}
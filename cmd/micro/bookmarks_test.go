@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "bookmarks")
+	defer os.RemoveAll(dir)
+	defer func(d string) { configDir = d }(configDir)
+	configDir = dir
+	defer func() {
+		for _, b := range ListBookmarks() {
+			removeBookmark(rune(b.Name[0]), b.Path)
+		}
+	}()
+
+	for _, b := range []Bookmark{
+		{"a", "/a.go", Loc{1, 2}},
+		{"a", "/b.go", Loc{0, 4}},
+		{"b", "/a.go", Loc{0, 7}},
+		{"A", "/b.go", Loc{3, 9}},
+		{"b", "/a.go", Loc{0, 5}},
+	} {
+		if err := AddBookmark(b.Name, b.Path, b.Loc); err != nil {
+			t.Fatalf("AddBookmark %v: %v", b, err)
+		}
+	}
+	if err := AddBookmark("1", "/a.go", Loc{}); err == nil {
+		t.Errorf("AddBookmark accepted the name 1")
+	}
+	want := []Bookmark{
+		{"A", "/b.go", Loc{3, 9}},
+		{"a", "/a.go", Loc{1, 2}},
+		{"b", "/a.go", Loc{0, 5}},
+		{"a", "/b.go", Loc{0, 4}},
+	}
+	if list := ListBookmarks(); !reflect.DeepEqual(list, want) {
+		t.Errorf("ListBookmarks: got %v, want %v", list, want)
+	}
+	if m := GetBookmark("A", "/a.go"); m == nil || m.Path != "/b.go" {
+		t.Errorf("GetBookmark A: got %v", m)
+	}
+	if m := GetBookmark("b", "/b.go"); m != nil {
+		t.Errorf("GetBookmark b of /b.go: got %v", m)
+	}
+
+	// The bookmarks follow the edits of their file
+	b := &Buffer{AbsPath: "/b.go"}
+	b.moveMarks(func(loc Loc) Loc { return locAfterInsert(loc, Loc{0, 2}, Loc{0, 5}) })
+	if lines := b.bookmarkLines(); !reflect.DeepEqual(lines, map[int]rune{7: 'a', 12: 'A'}) {
+		t.Errorf("bookmarkLines: got %v", lines)
+	}
+	saveFileBookmarks("/b.go")
+
+	if !RemoveBookmark("a", "/b.go") || RemoveBookmark("a", "/b.go") {
+		t.Errorf("RemoveBookmark a of /b.go")
+	}
+	want = []Bookmark{
+		{"A", "/b.go", Loc{3, 12}},
+		{"a", "/a.go", Loc{1, 2}},
+		{"b", "/a.go", Loc{0, 5}},
+	}
+	for _, b := range ListBookmarks() {
+		removeBookmark(rune(b.Name[0]), b.Path)
+	}
+	if len(fileMarks) != 0 {
+		t.Errorf("marks of removed bookmarks are still moved: %v", fileMarks)
+	}
+	loadBookmarks()
+	if list := ListBookmarks(); !reflect.DeepEqual(list, want) {
+		t.Errorf("loadBookmarks: got %v, want %v", list, want)
+	}
+}
+
+func TestDiscardedBookmarks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "bookmarks")
+	defer os.RemoveAll(dir)
+	defer func(d string) { configDir = d }(configDir)
+	configDir = dir
+	defer func(list []*Buffer) { bufferList = list }(bufferList)
+	bufferList = nil
+	defer func() {
+		for _, b := range ListBookmarks() {
+			removeBookmark(rune(b.Name[0]), b.Path)
+		}
+	}()
+
+	AddBookmark("a", "/a.go", Loc{0, 5})
+	AddBookmark("B", "/a.go", Loc{0, 8})
+	b := &Buffer{Path: "/a.go", AbsPath: "/a.go"}
+	registerBuffer(b)
+	b.moveMarks(func(loc Loc) Loc { return locAfterInsert(loc, Loc{0, 0}, Loc{0, 10}) })
+	b.IsModified = true
+	AddBookmark("c", "/a.go", Loc{0, 1})
+	AddBookmark("d", "/d.go", Loc{0, 2})
+
+	// Only the locations in the saved files are stored
+	want := []Bookmark{
+		{"B", "/a.go", Loc{0, 8}},
+		{"a", "/a.go", Loc{0, 5}},
+		{"d", "/d.go", Loc{0, 2}},
+	}
+	for _, b := range ListBookmarks() {
+		removeBookmark(rune(b.Name[0]), b.Path)
+	}
+	loadBookmarks()
+	if list := ListBookmarks(); !reflect.DeepEqual(list, want) {
+		t.Errorf("loadBookmarks: got %v, want %v", list, want)
+	}
+	for _, b := range ListBookmarks() {
+		removeBookmark(rune(b.Name[0]), b.Path)
+	}
+
+	// Discarding the changes puts the bookmarks back where they were saved
+	b.IsModified = false
+	AddBookmark("a", "/a.go", Loc{0, 15})
+	AddBookmark("e", "/a.go", Loc{0, 3})
+	b.IsModified = true
+	AddBookmark("c", "/a.go", Loc{0, 1})
+	GetBookmark("e", "/a.go").Loc = Loc{0, 4}
+	unloadBuffer(b)
+	want = []Bookmark{
+		{"a", "/a.go", Loc{0, 15}},
+		{"e", "/a.go", Loc{0, 3}},
+	}
+	if list := ListBookmarks(); !reflect.DeepEqual(list, want) || findBuffer("/a.go") != nil {
+		t.Errorf("unloadBuffer: got %v, want %v", list, want)
+	}
+}
@@ -27,7 +27,9 @@ type Buffer struct {
 	// This stores all the text in the buffer as an array of lines
 	*LineArray
 
-	Cursor Cursor
+	// The cursor of the view the buffer was last used in, which undo and
+	// redo move
+	Cursor *Cursor
 
 	// Path to the file on disk
 	Path string
@@ -46,9 +48,35 @@ type Buffer struct {
 
 	// Syntax highlighting rules
 	rules []SyntaxRule
+	// The rules which find the symbols of filetypes other than Go
+	symbolRules []SymbolRule
+	// The symbols of the current text
+	symbols *symbolCache
 
 	// Buffer local settings
 	Settings map[string]interface{}
+
+	// The diff against the file in HEAD, used for the git gutter
+	git gitState
+
+	// Incremented every time the text changes
+	version int
+
+	// The merge conflicts in the text
+	conflicts conflictState
+
+	// The locations of the last edits, oldest first, and the index of the
+	// change PrevChange and NextChange are at
+	changes     []Loc
+	changeIndex int
+
+	// The closed folds and the ranges which can be folded
+	folds foldState
+	// The scopes shown by the sticky scroll header
+	scopes scopeState
+
+	// When the buffer was last used, for the buffer switcher
+	used int
 }
 
 // The SerializedBuffer holds the types that get serialized when a buffer is saved
@@ -57,21 +85,33 @@ type SerializedBuffer struct {
 	EventHandler *EventHandler
 	Cursor       Cursor
 	ModTime      time.Time
+	// The change list and the closed folds, restored with the cursor
+	Changes []Loc
+	Folds   []Fold
 }
 
 func NewBufferFromString(text, path string) *Buffer {
 	return NewBuffer(strings.NewReader(text), path)
 }
 
+// NewScratchBuffer creates a read-only buffer which is not backed by a file
+// The filetype is used for syntax highlighting
+func NewScratchBuffer(text, name, filetype string) *Buffer {
+	b := NewBufferFromString(text, "")
+	b.name = name
+	b.Settings["readonly"] = true
+	b.Settings["filetype"] = filetype
+	b.Settings["minimap"] = false
+	b.UpdateRules()
+	unlistBuffer(b)
+	return b
+}
+
 // NewBuffer creates a new buffer from a given reader with a given path
 func NewBuffer(reader io.Reader, path string) *Buffer {
 	if path != "" {
-		for _, tab := range tabs {
-			for _, view := range tab.views {
-				if view.Buf.Path == path {
-					return view.Buf
-				}
-			}
+		if b := findBuffer(path); b != nil {
+			return b
 		}
 	}
 
@@ -94,10 +134,16 @@ func NewBuffer(reader io.Reader, path string) *Buffer {
 	b.ModTime, _ = GetModTime(b.Path)
 
 	b.EventHandler = NewEventHandler(b)
+	b.RefreshGitBase()
 
 	b.Update()
 	b.FindFileType()
 	b.UpdateRules()
+	if b.FileType() == "go" && goModuleRoot() != "" {
+		// Without a go.mod the working directory could be any directory,
+		// so it is only indexed when the workspace symbols are asked for
+		IndexWorkspace()
+	}
 
 	if _, err := os.Stat(configDir + "/buffers/"); os.IsNotExist(err) {
 		os.Mkdir(configDir+"/buffers/", os.ModePerm)
@@ -130,7 +176,7 @@ func NewBuffer(reader io.Reader, path string) *Buffer {
 			}
 		}
 	}
-	b.Cursor = Cursor{
+	b.Cursor = &Cursor{
 		Loc: Loc{
 			X: cursorStartX,
 			Y: cursorStartY,
@@ -153,9 +199,15 @@ func NewBuffer(reader io.Reader, path string) *Buffer {
 				TermMessage(err.Error(), "\n", "You may want to remove the files in ~/.config/micro/buffers (these files store the information for the 'saveundo' and 'savecursor' options) if this problem persists.")
 			}
 			if b.Settings["savecursor"].(bool) {
-				b.Cursor = buffer.Cursor
+				*b.Cursor = buffer.Cursor
 				b.Cursor.buf = b
 				b.Cursor.Relocate()
+				// The change list only applies to the text it was made on
+				if b.ModTime == buffer.ModTime {
+					b.changes = buffer.Changes
+					b.changeIndex = len(b.changes)
+					b.folds.closed = buffer.Folds
+				}
 			}
 
 			if b.Settings["saveundo"].(bool) {
@@ -169,6 +221,7 @@ func NewBuffer(reader io.Reader, path string) *Buffer {
 		file.Close()
 	}
 
+	registerBuffer(b)
 	return b
 }
 
@@ -186,6 +239,8 @@ func (b *Buffer) GetName() string {
 // This is called when the colorscheme changes
 func (b *Buffer) UpdateRules() {
 	b.rules = GetRules(b)
+	b.symbolRules = GetSymbolRules(b)
+	b.symbols = nil
 }
 
 // FindFileType identifies this buffer's filetype based on the extension or header
@@ -242,11 +297,24 @@ func (b *Buffer) ReOpen() {
 	b.IsModified = false
 	b.Update()
 	b.Cursor.Relocate()
+	b.RefreshGitBase()
+}
+
+// SetText replaces the whole text of the buffer without recording an undo
+// event, which also works on read-only buffers such as the outline
+func (b *Buffer) SetText(text string) {
+	b.LineArray = NewLineArray(strings.NewReader(text))
+	b.EventHandler = NewEventHandler(b)
+	b.IsModified = false
+	b.Update()
+	b.Cursor.Relocate()
 }
 
 // Update fetches the string from the rope and updates the `text` and `lines` in the buffer
 func (b *Buffer) Update() {
 	b.NumLines = len(b.lines)
+	b.git.dirty = true
+	b.version++
 }
 
 // Save saves the buffer to its default path
@@ -268,8 +336,10 @@ func (b *Buffer) Serialize() error {
 			gob.Register(TextEvent{})
 			err = enc.Encode(SerializedBuffer{
 				b.EventHandler,
-				b.Cursor,
+				*b.Cursor,
 				b.ModTime,
+				b.changes,
+				b.folds.closed,
 			})
 		}
 		file.Close()
@@ -308,6 +378,13 @@ func (b *Buffer) SaveAs(filename string) error {
 	if err == nil {
 		b.IsModified = false
 		b.ModTime, _ = GetModTime(filename)
+		b.RefreshGitBase()
+		if b.FileType() == "go" {
+			refreshWorkspaceFile(b.AbsPath, str)
+			goGraphSaved()
+		}
+		saveFileBookmarks(b.AbsPath)
+		refreshExplorers()
 		return b.Serialize()
 	}
 	b.ModTime, _ = GetModTime(filename)
@@ -359,6 +436,7 @@ func (b *Buffer) SaveAsWithSudo(filename string) error {
 	if err == nil {
 		b.IsModified = false
 		b.ModTime, _ = GetModTime(filename)
+		b.RefreshGitBase()
 		b.Serialize()
 	}
 	return err
@@ -367,11 +445,16 @@ func (b *Buffer) SaveAsWithSudo(filename string) error {
 func (b *Buffer) insert(pos Loc, value []byte) {
 	b.IsModified = true
 	b.LineArray.insert(pos, value)
+	end := insertEnd(pos, value)
+	b.moveMarks(func(loc Loc) Loc { return locAfterInsert(loc, pos, end) })
+	b.addChange(end)
 	b.Update()
 }
 func (b *Buffer) remove(start, end Loc) string {
 	b.IsModified = true
 	sub := b.LineArray.remove(start, end)
+	b.moveMarks(func(loc Loc) Loc { return locAfterRemove(loc, start, end) })
+	b.addChange(start)
 	b.Update()
 	return sub
 }
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bufferList holds every loaded buffer, whether a view shows it or it is
+// hidden; help, log and scratch buffers are left out
+var bufferList []*Buffer
+
+// bufferClock counts the uses of buffers, for the most recently used order
+var bufferClock int
+
+// registerBuffer adds a buffer to the buffer list if it isn't in it already
+func registerBuffer(b *Buffer) {
+	for _, buf := range bufferList {
+		if buf == b {
+			return
+		}
+	}
+	bufferList = append(bufferList, b)
+}
+
+// unlistBuffer removes a buffer from the buffer list
+func unlistBuffer(b *Buffer) {
+	for i, buf := range bufferList {
+		if buf == b {
+			bufferList = append(bufferList[:i], bufferList[i+1:]...)
+			return
+		}
+	}
+}
+
+// touchBuffer marks a buffer as the most recently used one
+func touchBuffer(b *Buffer) {
+	bufferClock++
+	b.used = bufferClock
+}
+
+// findBuffer returns the loaded buffer of a file, or nil if it isn't loaded
+func findBuffer(path string) *Buffer {
+	absPath, _ := filepath.Abs(path)
+	for _, b := range bufferList {
+		if b.Path != "" && (b.Path == path || b.AbsPath == absPath) {
+			return b
+		}
+	}
+	return nil
+}
+
+// recentBuffers returns the buffer list, most recently used first
+func recentBuffers() []*Buffer {
+	list := append([]*Buffer(nil), bufferList...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].used > list[j].used
+	})
+	return list
+}
+
+// bufferViews returns the views which show a buffer, in every tab
+func bufferViews(b *Buffer) []*View {
+	var views []*View
+	for _, t := range tabs {
+		for _, v := range t.views {
+			if v.Buf == b {
+				views = append(views, v)
+			}
+		}
+	}
+	return views
+}
+
+// bufferByName returns the listed buffer whose name, path or number in the
+// buffer list is the argument
+func bufferByName(name string) *Buffer {
+	if n, err := strconv.Atoi(name); err == nil && n >= 1 && n <= len(bufferList) {
+		return bufferList[n-1]
+	}
+	if b := findBuffer(name); b != nil {
+		return b
+	}
+	for _, b := range bufferList {
+		if b.GetName() == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// keepBuffer keeps a buffer no view shows any more loaded, unless it is an
+// unnamed buffer without text
+func keepBuffer(b *Buffer) {
+	if b.Path != "" || b.IsModified {
+		registerBuffer(b)
+	}
+}
+
+// releaseBuffer removes a buffer which was replaced or closed from the buffer
+// list if no view shows it any more and it has unsaved changes, which were
+// discarded, or no name, so that it can't be opened again
+func releaseBuffer(b *Buffer) {
+	if b != nil && len(bufferViews(b)) == 0 && (b.IsModified || b.Path == "") {
+		unloadBuffer(b)
+	}
+}
+
+// unloadBuffer removes a buffer from the buffer list, putting the bookmarks
+// of its file back where they were saved if its changes are discarded
+func unloadBuffer(b *Buffer) {
+	if b.IsModified && b.Path != "" {
+		revertBookmarks(b.AbsPath)
+	}
+	unlistBuffer(b)
+}
+
+// showBuffer shows a buffer in the view, keeping the buffer it showed
+// loaded even if it has unsaved changes
+func (v *View) showBuffer(b *Buffer) {
+	if b == v.Buf {
+		return
+	}
+	old := v.Buf
+	v.OpenBuffer(b)
+	if v.Type == vtDefault {
+		keepBuffer(old)
+	}
+}
+
+// otherBuffer returns the most recently used buffer other than b, or a new
+// empty buffer if there is none
+func otherBuffer(b *Buffer) *Buffer {
+	for _, buf := range recentBuffers() {
+		if buf != b {
+			return buf
+		}
+	}
+	return NewBuffer(strings.NewReader(""), "")
+}
+
+// bufferLabel returns the text of a buffer in the buffer switcher: its
+// number, whether it is modified or hidden, its name and the line of its
+// cursor
+func bufferLabel(b *Buffer) string {
+	n := 0
+	for i, buf := range bufferList {
+		if buf == b {
+			n = i + 1
+		}
+	}
+	flags := []byte("  ")
+	if b.IsModified {
+		flags[0] = '+'
+	}
+	if len(bufferViews(b)) == 0 {
+		flags[1] = 'h'
+	}
+	return fmt.Sprintf("%2d %s %s:%d", n, flags, b.GetName(), b.Cursor.Y+1)
+}
+
+// BufferList opens a fuzzy finder of the loaded buffers, most recently used
+// first, and shows the chosen one in the current view
+func (v *View) BufferList(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("BufferList", v) {
+		return false
+	}
+
+	list := recentBuffers()
+	if len(list) > 1 && list[0] == v.Buf {
+		// The current buffer comes last, so the default choice switches to
+		// the previous one
+		list = append(list[1:], list[0])
+	}
+	autocomplete.Open(func(v *View) (messages Messages) {
+		for i, b := range list {
+			messages = append(messages, Message{
+				Searchable:       b.GetName(),
+				MessageToDisplay: bufferLabel(b),
+				Value2:           []byte(strconv.Itoa(i)),
+			})
+		}
+		return messages
+	}, func(message Message) {
+		if i, err := strconv.Atoi(string(message.Value2)); err == nil && i < len(list) {
+			v.recordJump()
+			v.showBuffer(list[i])
+		}
+	}, nil, v)
+
+	if usePlugin {
+		return PostActionCall("BufferList", v)
+	}
+	return true
+}
+
+// closeBuffer shows another buffer in the views which show b
+// With unload the buffer is also removed from the buffer list once its
+// changes are saved or discarded, and it returns false if that was canceled
+func closeBuffer(b *Buffer, unload bool) bool {
+	views := bufferViews(b)
+	if unload && b.IsModified {
+		char, canceled := messenger.LetterPrompt("Save changes to "+b.GetName()+" before closing? (y,n,esc) ", 'y', 'n')
+		if canceled {
+			return false
+		}
+		if char == 'y' {
+			if b.Path == "" {
+				messenger.Error("No filename, use SaveAs to name the buffer")
+				return false
+			}
+			if err := b.Save(); err != nil {
+				messenger.Error(err.Error())
+				return false
+			}
+		}
+	}
+	other := otherBuffer(b)
+	for _, view := range views {
+		if view.Type == vtDefault {
+			view.OpenBuffer(other)
+		}
+	}
+	if unload {
+		b.Serialize()
+		unloadBuffer(b)
+	} else {
+		keepBuffer(b)
+	}
+	return true
+}
+
+// saveHiddenBuffers asks whether to save the hidden buffers with unsaved
+// changes before quitting, and returns false if that was canceled
+func saveHiddenBuffers() bool {
+	for _, b := range bufferList {
+		if !b.IsModified || len(bufferViews(b)) > 0 {
+			continue
+		}
+		char, canceled := messenger.LetterPrompt("Save changes to hidden buffer "+b.GetName()+" before closing? (y,n,esc) ", 'y', 'n')
+		if canceled {
+			return false
+		}
+		if char == 'y' {
+			if err := b.Save(); err != nil {
+				messenger.Error(err.Error())
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// BufferCmd shows the buffer with the given name, path or number in the
+// current view, or opens the buffer switcher without arguments
+func BufferCmd(args []string) {
+	v := CurView()
+	if len(args) == 0 {
+		v.BufferList(true)
+		return
+	}
+	b := bufferByName(strings.Join(args, " "))
+	if b == nil {
+		messenger.Error("No buffer ", strings.Join(args, " "))
+		return
+	}
+	v.recordJump()
+	v.showBuffer(b)
+}
+
+// bufferArg returns the buffer a close command applies to: the one named by
+// the arguments, or the buffer of the current view
+func bufferArg(args []string) *Buffer {
+	if len(args) == 0 {
+		return CurView().Buf
+	}
+	b := bufferByName(strings.Join(args, " "))
+	if b == nil {
+		messenger.Error("No buffer ", strings.Join(args, " "))
+	}
+	return b
+}
+
+// BufferClose hides a buffer: the views which show it show the previous
+// buffer instead, and it stays loaded
+func BufferClose(args []string) {
+	if b := bufferArg(args); b != nil {
+		closeBuffer(b, false)
+	}
+}
+
+// BufferDelete unloads a buffer, asking to save its changes
+func BufferDelete(args []string) {
+	if b := bufferArg(args); b != nil && closeBuffer(b, true) {
+		messenger.Message("Deleted buffer ", b.GetName())
+	}
+}
+
+// BufferWipe unloads a buffer like BufferDelete and also forgets its saved
+// cursor position and undo history
+func BufferWipe(args []string) {
+	if b := bufferArg(args); b != nil && closeBuffer(b, true) {
+		os.Remove(configDir + "/buffers/" + EscapePath(b.AbsPath))
+		messenger.Message("Wiped buffer ", b.GetName())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBufferList(t *testing.T) {
+	defer func(list []*Buffer) { bufferList = list }(bufferList)
+	bufferList = nil
+
+	newBuf := func(path string) *Buffer {
+		abs, _ := filepath.Abs(path)
+		b := &Buffer{Path: path, AbsPath: abs, Cursor: &Cursor{}}
+		registerBuffer(b)
+		return b
+	}
+	a, b, c := newBuf("a.go"), newBuf("dir/b.go"), newBuf("")
+	registerBuffer(a)
+	if len(bufferList) != 3 {
+		t.Fatalf("registerBuffer: got %d buffers, want 3", len(bufferList))
+	}
+
+	touchBuffer(b)
+	touchBuffer(a)
+	if list := recentBuffers(); list[0] != a || list[1] != b || list[2] != c {
+		t.Errorf("recentBuffers: wrong order")
+	}
+
+	tests := []struct {
+		name string
+		want *Buffer
+	}{
+		{"a.go", a},
+		{"./dir/b.go", b},
+		{"2", b},
+		{"3", c},
+		{"No name", c},
+		{"4", nil},
+		{"c.go", nil},
+	}
+	for _, test := range tests {
+		if got := bufferByName(test.name); got != test.want {
+			t.Errorf("bufferByName(%q): got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	a.IsModified = true
+	a.Cursor.Y = 4
+	if label := bufferLabel(a); label != " 1 +h a.go:5" {
+		t.Errorf("bufferLabel: got %q", label)
+	}
+
+	unlistBuffer(b)
+	if findBuffer("dir/b.go") != nil || len(bufferList) != 2 {
+		t.Errorf("unlistBuffer: the buffer is still listed")
+	}
+}
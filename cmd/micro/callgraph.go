@@ -0,0 +1,144 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// A callSite is a call from one function to another
+type callSite struct {
+	// The full name of the function at the other end of the call
+	fn string
+	// The position of the name of the called function in the call
+	pos token.Position
+}
+
+// A callFunc is a function or method of the call graph
+type callFunc struct {
+	// The name shown in the call hierarchy, such as pkg.Type.Method
+	name string
+	// The position of the name of the declaration
+	pos token.Position
+	// The calls in the body of the function, in order
+	calls []callSite
+	// The calls of this function in the workspace
+	callers []callSite
+}
+
+// callGraph holds the static calls between the functions declared in the Go
+// packages of the workspace, and the functions they call in other packages
+// Functions are keyed by the full name given by go/types, such as
+// (*example.com/pkg.Type).Method
+type callGraph struct {
+	funcs map[string]*callFunc
+	// The names and declarations of functions in each file, by absolute path
+	refs map[string][]graphRef
+}
+
+// newCallGraph returns an empty call graph
+func newCallGraph() *callGraph {
+	return &callGraph{funcs: make(map[string]*callFunc), refs: make(map[string][]graphRef)}
+}
+
+// funcName returns the name of a function as shown in the call hierarchy
+func funcName(f *types.Func) string {
+	name := f.Name()
+	if sig, ok := f.Type().(*types.Signature); ok && sig.Recv() != nil {
+		t := sig.Recv().Type()
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		if n, ok := t.(*types.Named); ok {
+			name = n.Obj().Name() + "." + name
+		}
+	}
+	if f.Pkg() != nil {
+		name = f.Pkg().Name() + "." + name
+	}
+	return name
+}
+
+// calledFunc returns the function named by the function expression of a
+// call, or nil for calls of builtins, conversions and function values
+func calledFunc(info *types.Info, expr ast.Expr) (*types.Func, *ast.Ident) {
+	for {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.Ident:
+			f, _ := info.Uses[e].(*types.Func)
+			return f, e
+		case *ast.SelectorExpr:
+			f, _ := info.Uses[e.Sel].(*types.Func)
+			return f, e.Sel
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// addFunc returns the node of a function, adding it to the graph if needed
+func (g *callGraph) addFunc(fset *token.FileSet, f *types.Func) *callFunc {
+	key := f.FullName()
+	if n, ok := g.funcs[key]; ok {
+		return n
+	}
+	n := &callFunc{name: funcName(f), pos: fset.Position(f.Pos())}
+	g.funcs[key] = n
+	return n
+}
+
+// addPackage adds the functions declared in the files of a type checked
+// package and the calls in their bodies
+func (g *callGraph) addPackage(fset *token.FileSet, files []*ast.File, info *types.Info) {
+	for _, file := range files {
+		path := fset.Position(file.Pos()).Filename
+		ast.Inspect(file, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if f, ok := info.Uses[id].(*types.Func); ok {
+					g.refs[path] = append(g.refs[path], graphRef{fset.Position(id.Pos()).Offset, fset.Position(id.End()).Offset, f.FullName(), false})
+				}
+			}
+			return true
+		})
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			f, ok := info.Defs[fd.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			caller := g.addFunc(fset, f)
+			g.refs[path] = append(g.refs[path],
+				graphRef{fset.Position(fd.Name.Pos()).Offset, fset.Position(fd.Name.End()).Offset, f.FullName(), false},
+				graphRef{fset.Position(fd.Pos()).Offset, fset.Position(fd.End()).Offset, f.FullName(), true})
+			if fd.Body == nil {
+				continue
+			}
+			ast.Inspect(fd.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if callee, id := calledFunc(info, call.Fun); callee != nil {
+					pos := fset.Position(id.Pos())
+					node := g.addFunc(fset, callee)
+					node.callers = append(node.callers, callSite{f.FullName(), pos})
+					caller.calls = append(caller.calls, callSite{callee.FullName(), pos})
+				}
+				return true
+			})
+		}
+	}
+}
+
+// funcAt returns the function named at a byte offset of a file, or else the
+// function whose declaration contains it
+func (g *callGraph) funcAt(path string, offset int) string {
+	return refAt(g.refs[path], offset)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildCallGraph(t *testing.T) {
+	root, _ := ioutil.TempDir("", "callgraph")
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "util"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "util", "util.go"), []byte(`package util
+
+import "strings"
+
+type T struct{}
+
+func (t *T) Clean(s string) string { return strings.TrimSpace(s) }
+
+func Upper(s string) string { return strings.ToUpper(s) }
+`), 0644)
+	main := filepath.Join(root, "main.go")
+	ioutil.WriteFile(main, []byte("package main\n"), 0644)
+	// The text of an open file is used instead of the file
+	src := `package main
+
+import "example.com/app/util"
+
+func main() {
+	run("a")
+	run("b")
+}
+
+func run(s string) {
+	t := &util.T{}
+	println(util.Upper(t.Clean(s)))
+}
+`
+	// Imports of the module are found from the working directory
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+	g := buildGoGraph(root, map[string]string{main: src}).calls
+
+	names := func(sites []callSite) string {
+		var s []string
+		for _, site := range sites {
+			s = append(s, g.funcs[site.fn].name)
+		}
+		return strings.Join(s, " ")
+	}
+	tests := []struct {
+		fn, calls, callers string
+	}{
+		{"example.com/app.main", "main.run main.run", ""},
+		{"example.com/app.run", "util.Upper util.T.Clean", "main.main main.main"},
+		{"example.com/app/util.Upper", "strings.ToUpper", "main.run"},
+		{"(*example.com/app/util.T).Clean", "strings.TrimSpace", "main.run"},
+	}
+	for _, test := range tests {
+		f, ok := g.funcs[test.fn]
+		if !ok {
+			t.Errorf("buildGoGraph: %s is missing", test.fn)
+			continue
+		}
+		if calls, callers := names(f.calls), names(f.callers); calls != test.calls || callers != test.callers {
+			t.Errorf("buildGoGraph %s: calls %q callers %q, want %q %q", test.fn, calls, callers, test.calls, test.callers)
+		}
+	}
+	if site := g.funcs["example.com/app.run"].callers[1]; site.pos.Filename != main || site.pos.Line != 7 || site.pos.Column != 2 {
+		t.Errorf("buildGoGraph: wrong call site %v", site.pos)
+	}
+
+	for offset, want := range map[int]string{
+		strings.Index(src, "run(\"a\")"): "example.com/app.run",
+		strings.Index(src, "Upper"):      "example.com/app/util.Upper",
+		strings.Index(src, "t := "):      "example.com/app.run",
+		strings.Index(src, "import"):     "",
+	} {
+		if fn := g.funcAt(main, offset); fn != want {
+			t.Errorf("funcAt %d: got %q, want %q", offset, fn, want)
+		}
+	}
+
+	expanded := map[string]bool{"in": true, "out/example.com/app/util.Upper#0": true}
+	want := "main.run  main.go:10\n" +
+		"  ▾ Incoming calls (2)\n" +
+		"      main.main  main.go:6\n" +
+		"      main.main  main.go:7\n" +
+		"  ▸ Outgoing calls (2)"
+	if text := callTreeText(g, callTreeRows(g, "example.com/app.run", expanded), expanded); text != want {
+		t.Errorf("callTreeText: got %q, want %q", text, want)
+	}
+	expanded["out"] = true
+	want = "main.run  main.go:10\n" +
+		"  ▾ Incoming calls (2)\n" +
+		"      main.main  main.go:6\n" +
+		"      main.main  main.go:7\n" +
+		"  ▾ Outgoing calls (2)\n" +
+		"    ▾ util.Upper  main.go:12\n" +
+		"        strings.ToUpper  util.go:9\n" +
+		"    ▸ util.T.Clean  main.go:12"
+	if text := callTreeText(g, callTreeRows(g, "example.com/app.run", expanded), expanded); text != want {
+		t.Errorf("callTreeText: got %q, want %q", text, want)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zyedidia/tcell"
+)
+
+// A callRow is a line of the call hierarchy tree
+type callRow struct {
+	// The function of the row, empty for the incoming and outgoing headers
+	fn string
+	// Where the row jumps to: the call, or the declaration for the root
+	pos token.Position
+	// Identifies the row in the expanded state
+	path     string
+	depth    int
+	incoming bool
+	// Whether expanding the row shows anything
+	children bool
+}
+
+// callTreeState links a call hierarchy view to the view it jumps in
+type callTreeState struct {
+	source *View
+	// The function the hierarchy is about
+	root     string
+	expanded map[string]bool
+	rows     []callRow
+}
+
+// The expanded rows of the call hierarchy of each function, which are kept
+// while the hierarchy is closed or shows another function
+var callTreeExpanded = make(map[string]map[string]bool)
+
+// callSites returns the calls into a function or out of it
+func (g *callGraph) callSites(fn string, incoming bool) []callSite {
+	f, ok := g.funcs[fn]
+	if !ok {
+		return nil
+	}
+	if incoming {
+		return f.callers
+	}
+	return f.calls
+}
+
+// callTreeRows returns the rows of the call hierarchy of a function
+// The children of a row are only listed if its path is expanded
+func callTreeRows(g *callGraph, root string, expanded map[string]bool) []callRow {
+	rows := []callRow{{fn: root, pos: g.funcs[root].pos}}
+	var add func(fn string, incoming bool, path string, depth int)
+	add = func(fn string, incoming bool, path string, depth int) {
+		seen := make(map[string]int)
+		for _, site := range g.callSites(fn, incoming) {
+			p := path + "/" + site.fn + "#" + strconv.Itoa(seen[site.fn])
+			seen[site.fn]++
+			rows = append(rows, callRow{
+				fn:       site.fn,
+				pos:      site.pos,
+				path:     p,
+				depth:    depth,
+				incoming: incoming,
+				children: len(g.callSites(site.fn, incoming)) > 0,
+			})
+			if expanded[p] {
+				add(site.fn, incoming, p, depth+1)
+			}
+		}
+	}
+	for _, incoming := range []bool{true, false} {
+		path := "out"
+		if incoming {
+			path = "in"
+		}
+		rows = append(rows, callRow{path: path, depth: 1, incoming: incoming, children: len(g.callSites(root, incoming)) > 0})
+		if expanded[path] {
+			add(root, incoming, path, 2)
+		}
+	}
+	return rows
+}
+
+// callTreeText returns the lines of the call hierarchy tree
+func callTreeText(g *callGraph, rows []callRow, expanded map[string]bool) string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		marker := "  "
+		if row.children && expanded[row.path] {
+			marker = "▾ "
+		} else if row.children {
+			marker = "▸ "
+		}
+		var label string
+		switch {
+		case row.fn == "" && row.incoming:
+			label = "Incoming calls (" + strconv.Itoa(len(g.callSites(rows[0].fn, true))) + ")"
+		case row.fn == "":
+			label = "Outgoing calls (" + strconv.Itoa(len(g.callSites(rows[0].fn, false))) + ")"
+		default:
+			label = g.funcs[row.fn].name
+			if row.pos.IsValid() {
+				label += "  " + filepath.Base(row.pos.Filename) + ":" + strconv.Itoa(row.pos.Line)
+			}
+		}
+		if i == 0 {
+			marker = ""
+		}
+		lines[i] = strings.Repeat("  ", row.depth) + marker + label
+	}
+	return strings.Join(lines, "\n")
+}
+
+// render refreshes the text of a call hierarchy view from the call graph,
+// keeping the cursor on the same line
+func (t *callTreeState) render(v *View, g *callGraph) {
+	if _, ok := g.funcs[t.root]; !ok {
+		v.Buf.SetText("The function " + t.root + " is gone")
+		t.rows = nil
+		return
+	}
+	t.rows = callTreeRows(g, t.root, t.expanded)
+	y := v.Cursor.Y
+	v.Buf.SetText(callTreeText(g, t.rows, t.expanded))
+	v.Buf.name = "Calls " + g.funcs[t.root].name
+	v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, Min(y, v.Buf.NumLines-1), 0
+	v.Relocate()
+}
+
+// jumpToPosition moves the cursor of the view to a position printed by
+// go/token, opening its file if needed, and records the jump
+func (v *View) jumpToPosition(pos token.Position) {
+	v.recordJump()
+	if pos.Filename != v.Buf.AbsPath && !v.openFile(pos.Filename) {
+		return
+	}
+	loc := Loc{0, Min(Max(pos.Line-1, 0), v.Buf.NumLines-1)}
+	line := v.Buf.Line(loc.Y)
+	loc.X = utf8.RuneCountInString(line[:Min(Max(pos.Column-1, 0), len(line))])
+	v.gotoSymbolLoc(loc)
+}
+
+// openTreeSplit opens a split on the left of the view for a hierarchy tree,
+// whose rows run enter and toggle with the Open and Toggle actions of the
+// tree keymap context
+func (v *View) openTreeSplit(name string, enter, toggle func(tv *View, line int)) *View {
+	buf := NewScratchBuffer("", name, "Unknown")
+	buf.Settings["ruler"] = false
+	v.VSplitIndex(buf, v.Num)
+	tv := CurView()
+	tv.Type = vtScratch
+	tv.Width = 40
+	tv.LockWidth = true
+	tabs[curTab].Resize()
+	tv.treeActions = map[string]func(tv *View, line int){
+		"Open":   enter,
+		"Toggle": toggle,
+	}
+	return tv
+}
+
+// treeKey runs the actions bound to a key in the tree keymap context, and in
+// the explorer context in the explorer, on the row under the cursor
+// It returns whether the key was bound to any
+func (v *View) treeKey(e *tcell.EventKey) bool {
+	names := contextActions("tree", e)
+	if v.explorer != nil {
+		names = append(contextActions("explorer", e), names...)
+	}
+	ran := false
+	for _, name := range names {
+		if action, ok := v.treeActions[name]; ok {
+			action(v, v.Cursor.Y)
+			ran = true
+		}
+	}
+	return ran
+}
+
+// openCallTree shows the call hierarchy of a function in the call hierarchy
+// view of the source view, opening a split on the left if there is none
+func openCallTree(src *View, g *callGraph, fn string) {
+	var tv *View
+	for _, view := range tabs[src.TabNum].views {
+		if view.callTree != nil && view.callTree.source == src {
+			tv = view
+		}
+	}
+	if tv == nil {
+		tv = src.openTreeSplit("Calls", callTreeEnter, callTreeToggle)
+	}
+	expanded, ok := callTreeExpanded[fn]
+	if !ok {
+		expanded = map[string]bool{"in": true, "out": true}
+		callTreeExpanded[fn] = expanded
+	}
+	tv.callTree = &callTreeState{source: src, root: fn, expanded: expanded}
+	tv.Cursor.Y = 0
+	tv.callTree.render(tv, g)
+	tabs[curTab].CurView = tv.Num
+}
+
+// callTreeEnter jumps to the call of the row under the cursor in the source
+// view, or to the declaration for the first row, and toggles the headers
+func callTreeEnter(tv *View, line int) {
+	t := tv.callTree
+	if line >= len(t.rows) {
+		return
+	}
+	row := t.rows[line]
+	if row.fn == "" {
+		callTreeToggle(tv, line)
+		return
+	}
+	if !viewIsOpen(t.source) {
+		messenger.Error("The view of the call hierarchy was closed")
+		return
+	}
+	tabs[curTab].CurView = t.source.Num
+	t.source.jumpToPosition(row.pos)
+}
+
+// callTreeToggle expands or collapses the row under the cursor
+func callTreeToggle(tv *View, line int) {
+	t := tv.callTree
+	if line == 0 || line >= len(t.rows) || !t.rows[line].children || goGraphs.graph == nil {
+		return
+	}
+	path := t.rows[line].path
+	if t.expanded[path] {
+		delete(t.expanded, path)
+	} else {
+		t.expanded[path] = true
+	}
+	t.render(tv, goGraphs.graph.calls)
+}
+
+// CallHierarchy shows the functions which call the Go function under the
+// cursor and the functions it calls, as a tree in a split on the left
+// In the call hierarchy itself it shows the hierarchy of the function of
+// the row under the cursor
+func (v *View) CallHierarchy(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("CallHierarchy", v) {
+		return false
+	}
+
+	if v.callTree != nil || v.Buf.FileType() == "go" {
+		if goGraphs.graph == nil || goGraphs.stale {
+			messenger.Message("Type checking the workspace...")
+		}
+		v.Cursor.Relocate()
+		src, path, offset := v, v.Buf.AbsPath, ByteOffset(v.Cursor.Loc, v.Buf)
+		var fn string
+		if t := v.callTree; t != nil {
+			src, fn = t.source, t.root
+			if v.Cursor.Y < len(t.rows) && t.rows[v.Cursor.Y].fn != "" {
+				fn = t.rows[v.Cursor.Y].fn
+			}
+		}
+		loadGoGraph(func(gg *goGraph) {
+			g := gg.calls
+			if fn == "" {
+				fn = g.funcAt(path, offset)
+			}
+			if !viewIsOpen(src) || src.TabNum != curTab {
+				return
+			}
+			if _, ok := g.funcs[fn]; !ok {
+				messenger.Error("No function under the cursor")
+				return
+			}
+			messenger.Reset()
+			openCallTree(src, g, fn)
+		})
+	}
+
+	if usePlugin {
+		return PostActionCall("CallHierarchy", v)
+	}
+	return true
+}
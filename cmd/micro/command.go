@@ -49,6 +49,19 @@ func init() {
 		"Cd":        Cd,
 		"Pwd":       Pwd,
 		"Open":      Open,
+		"Blame":     Blame,
+		"GitLog":    GitLog,
+		"Diff":      Diff,
+		"DiffHead":  DiffHead,
+		"DiffOff":   DiffOff,
+		"Session":   SessionCmd,
+		"Macro":     MacroCmd,
+		"Outline":   Outline,
+		"Explorer":  Explorer,
+		"Buffer":    BufferCmd,
+		"BufClose":  BufferClose,
+		"BufDelete": BufferDelete,
+		"BufWipe":   BufferWipe,
 	}
 }
 
@@ -101,6 +114,19 @@ func DefaultCommands() map[string]StrCommand {
 		"cd":       {"Cd", []Completion{FileCompletion}},
 		"pwd":      {"Pwd", []Completion{NoCompletion}},
 		"open":     {"Open", []Completion{FileCompletion}},
+		"blame":    {"Blame", []Completion{NoCompletion}},
+		"gitlog":   {"GitLog", []Completion{NoCompletion}},
+		"diff":     {"Diff", []Completion{FileCompletion, FileCompletion}},
+		"diffhead": {"DiffHead", []Completion{NoCompletion}},
+		"diffoff":  {"DiffOff", []Completion{NoCompletion}},
+		"session":  {"Session", []Completion{SessionCmdCompletion, SessionNameCompletion}},
+		"macro":    {"Macro", []Completion{MacroCmdCompletion, MacroNameCompletion, NoCompletion}},
+		"outline":  {"Outline", []Completion{NoCompletion}},
+		"explorer": {"Explorer", []Completion{NoCompletion}},
+		"buffer":   {"Buffer", []Completion{NoCompletion}},
+		"bclose":   {"BufClose", []Completion{NoCompletion}},
+		"bdelete":  {"BufDelete", []Completion{NoCompletion}},
+		"bwipe":    {"BufWipe", []Completion{NoCompletion}},
 	}
 }
 
@@ -192,15 +218,19 @@ func Cd(args []string) {
 		home, _ := homedir.Dir()
 		path := strings.Replace(args[0], "~", home, 1)
 		os.Chdir(path)
-		for _, tab := range tabs {
-			for _, view := range tab.views {
-				wd, _ := os.Getwd()
-				view.Buf.Path, _ = MakeRelative(view.Buf.AbsPath, wd)
-				if p, _ := filepath.Abs(view.Buf.Path); !strings.Contains(p, wd) {
-					view.Buf.Path = view.Buf.AbsPath
-				}
+		// The hidden buffers are listed too
+		for _, b := range bufferList {
+			if b.Path == "" {
+				continue
+			}
+			wd, _ := os.Getwd()
+			b.Path, _ = MakeRelative(b.AbsPath, wd)
+			if p, _ := filepath.Abs(b.Path); !strings.Contains(p, wd) {
+				b.Path = b.AbsPath
 			}
 		}
+		wd, _ := os.Getwd()
+		chdirExplorers(wd)
 	}
 }
 
@@ -328,17 +358,7 @@ func NewTab(args []string) {
 		file, _ := os.Open(filename)
 		defer file.Close()
 
-		tab := NewTabFromView(NewView(NewBuffer(file, filename)))
-		tab.SetNum(len(tabs))
-		tabs = append(tabs, tab)
-		curTab = len(tabs) - 1
-		if len(tabs) == 2 {
-			for _, t := range tabs {
-				for _, v := range t.views {
-					v.ToggleTabbar()
-				}
-			}
-		}
+		AddTabWithBuffer(NewBuffer(file, filename))
 	}
 }
 
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/zyedidia/tcell"
+)
+
+// The regions of a merge conflict
+const (
+	ConflictMarker = iota + 1
+	ConflictOurs
+	ConflictBase
+	ConflictTheirs
+)
+
+// A Conflict is a merge conflict left in a file by git
+// The fields are the line numbers of the conflict markers
+type Conflict struct {
+	Start int // <<<<<<<
+	Base  int // |||||||, or -1 if the conflict has no base section
+	Sep   int // =======
+	End   int // >>>>>>>
+}
+
+// Ours returns the range of lines of our side of the conflict
+func (c Conflict) Ours() (int, int) {
+	if c.Base >= 0 {
+		return c.Start + 1, c.Base
+	}
+	return c.Start + 1, c.Sep
+}
+
+// Theirs returns the range of lines of their side of the conflict
+func (c Conflict) Theirs() (int, int) {
+	return c.Sep + 1, c.End
+}
+
+// Region returns which part of the conflict the line is in
+func (c Conflict) Region(line int) int {
+	switch {
+	case line == c.Start || line == c.Base || line == c.Sep || line == c.End:
+		return ConflictMarker
+	case line < c.Start || line > c.End:
+		return 0
+	case c.Base >= 0 && line > c.Base && line < c.Sep:
+		return ConflictBase
+	case line < c.Sep:
+		return ConflictOurs
+	}
+	return ConflictTheirs
+}
+
+// conflictState caches the conflicts of a buffer
+type conflictState struct {
+	list    []Conflict
+	version int
+	valid   bool
+}
+
+// isConflictMarker returns whether the line is a conflict marker made of the given character
+func isConflictMarker(line string, c byte) bool {
+	if len(line) < 7 || strings.Count(line[:7], string(c)) != 7 {
+		return false
+	}
+	return len(line) == 7 || line[7] == ' ' || line[7] == '\r'
+}
+
+// ParseConflicts returns the merge conflicts in the given lines
+// Incomplete conflicts are ignored
+func ParseConflicts(lines []string) []Conflict {
+	var conflicts []Conflict
+	var cur *Conflict
+	for i, l := range lines {
+		switch {
+		case isConflictMarker(l, '<'):
+			cur = &Conflict{i, -1, -1, -1}
+		case cur == nil:
+		case isConflictMarker(l, '|') && cur.Base < 0 && cur.Sep < 0:
+			cur.Base = i
+		case strings.TrimSuffix(l, "\r") == "=======" && cur.Sep < 0:
+			cur.Sep = i
+		case isConflictMarker(l, '>') && cur.Sep >= 0:
+			cur.End = i
+			conflicts = append(conflicts, *cur)
+			cur = nil
+		}
+	}
+	return conflicts
+}
+
+// Conflicts returns the merge conflicts in the buffer
+func (b *Buffer) Conflicts() []Conflict {
+	if !b.conflicts.valid || b.conflicts.version != b.version {
+		lines := make([]string, len(b.lines))
+		for i, l := range b.lines {
+			lines[i] = string(l)
+		}
+		b.conflicts = conflictState{ParseConflicts(lines), b.version, true}
+	}
+	return b.conflicts.list
+}
+
+// ConflictAt returns the conflict containing the given line
+func (b *Buffer) ConflictAt(line int) (Conflict, bool) {
+	for _, c := range b.Conflicts() {
+		if line >= c.Start && line <= c.End {
+			return c, true
+		}
+	}
+	return Conflict{}, false
+}
+
+// conflictLineStyle returns the style for a line which is part of a merge conflict
+func (v *View) conflictLineStyle(line int) (tcell.Style, bool) {
+	c, ok := v.Buf.ConflictAt(line)
+	if !ok {
+		return defStyle, false
+	}
+	var group string
+	var fallback tcell.Color
+	switch c.Region(line) {
+	case ConflictMarker:
+		group, fallback = "conflict-marker", tcell.ColorGray
+	case ConflictOurs:
+		group, fallback = "conflict-ours", tcell.ColorDarkGreen
+	case ConflictBase:
+		group, fallback = "conflict-base", tcell.ColorOlive
+	default:
+		group, fallback = "conflict-theirs", tcell.ColorDarkBlue
+	}
+	if style, ok := colorscheme[group]; ok {
+		fg, _, _ := style.Decompose()
+		return defStyle.Background(fg), true
+	}
+	return defStyle.Background(fallback), true
+}
+
+// conflictMessage describes the number of conflicts in the buffer
+func conflictMessage(n int) string {
+	if n == 1 {
+		return "1 merge conflict"
+	}
+	return strconv.Itoa(n) + " merge conflicts"
+}
+
+// resolveConflict replaces the conflict under the cursor with the given ranges of its lines
+func (v *View) resolveConflict(ranges ...[2]int) bool {
+	if v.Buf.Settings["readonly"].(bool) {
+		messenger.Error("This buffer is read-only")
+		return false
+	}
+	c, ok := v.Buf.ConflictAt(v.Cursor.Y)
+	if !ok {
+		messenger.Error("No merge conflict under the cursor")
+		return false
+	}
+	text := ""
+	for _, r := range ranges {
+		for i := r[0]; i < r[1]; i++ {
+			text += v.Buf.Line(i) + "\n"
+		}
+	}
+	v.Cursor.ResetSelection()
+	if c.End == v.Buf.NumLines-1 {
+		// Don't add a newline at the end of the file
+		text = strings.TrimSuffix(text, "\n")
+	}
+	replaceLines(v.Buf, c.Start, c.End-c.Start+1, text)
+	v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, c.Start, 0
+	v.Cursor.Relocate()
+	if n := len(v.Buf.Conflicts()); n > 0 {
+		messenger.Message(conflictMessage(n), " left")
+	} else {
+		messenger.Message("All merge conflicts resolved")
+	}
+	return true
+}
+
+// ConflictTakeOurs resolves the conflict under the cursor by keeping our side
+func (v *View) ConflictTakeOurs(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ConflictTakeOurs", v) {
+		return false
+	}
+
+	if c, ok := v.Buf.ConflictAt(v.Cursor.Y); ok {
+		start, end := c.Ours()
+		v.resolveConflict([2]int{start, end})
+	} else {
+		messenger.Error("No merge conflict under the cursor")
+	}
+
+	if usePlugin {
+		return PostActionCall("ConflictTakeOurs", v)
+	}
+	return true
+}
+
+// ConflictTakeTheirs resolves the conflict under the cursor by keeping their side
+func (v *View) ConflictTakeTheirs(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ConflictTakeTheirs", v) {
+		return false
+	}
+
+	if c, ok := v.Buf.ConflictAt(v.Cursor.Y); ok {
+		start, end := c.Theirs()
+		v.resolveConflict([2]int{start, end})
+	} else {
+		messenger.Error("No merge conflict under the cursor")
+	}
+
+	if usePlugin {
+		return PostActionCall("ConflictTakeTheirs", v)
+	}
+	return true
+}
+
+// ConflictTakeBoth resolves the conflict under the cursor by keeping our side followed by theirs
+func (v *View) ConflictTakeBoth(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ConflictTakeBoth", v) {
+		return false
+	}
+
+	if c, ok := v.Buf.ConflictAt(v.Cursor.Y); ok {
+		oStart, oEnd := c.Ours()
+		tStart, tEnd := c.Theirs()
+		v.resolveConflict([2]int{oStart, oEnd}, [2]int{tStart, tEnd})
+	} else {
+		messenger.Error("No merge conflict under the cursor")
+	}
+
+	if usePlugin {
+		return PostActionCall("ConflictTakeBoth", v)
+	}
+	return true
+}
+
+// ConflictEdit removes the markers of the conflict under the cursor, keeping every
+// section, and selects the result so it can be edited by hand
+func (v *View) ConflictEdit(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ConflictEdit", v) {
+		return false
+	}
+
+	if c, ok := v.Buf.ConflictAt(v.Cursor.Y); ok {
+		var ranges [][2]int
+		oStart, oEnd := c.Ours()
+		ranges = append(ranges, [2]int{oStart, oEnd})
+		if c.Base >= 0 {
+			ranges = append(ranges, [2]int{c.Base + 1, c.Sep})
+		}
+		tStart, tEnd := c.Theirs()
+		ranges = append(ranges, [2]int{tStart, tEnd})
+
+		lines := 0
+		for _, r := range ranges {
+			lines += r[1] - r[0]
+		}
+		if v.resolveConflict(ranges...) && lines > 0 {
+			end := Loc{0, c.Start + lines}
+			if end.Y >= v.Buf.NumLines {
+				end = v.Buf.End()
+			}
+			v.Cursor.SetSelectionStart(Loc{0, c.Start})
+			v.Cursor.SetSelectionEnd(end)
+		}
+	} else {
+		messenger.Error("No merge conflict under the cursor")
+	}
+
+	if usePlugin {
+		return PostActionCall("ConflictEdit", v)
+	}
+	return true
+}
+
+// jumpToConflict moves the cursor to the start of the given conflict
+func (v *View) jumpToConflict(conflicts []Conflict, i int) {
+	v.Cursor.ResetSelection()
+	v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, conflicts[i].Start, 0
+	v.Relocate()
+	messenger.Message("Conflict ", i+1, " of ", len(conflicts))
+}
+
+// NextConflict moves the cursor to the next merge conflict, wrapping around
+func (v *View) NextConflict(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("NextConflict", v) {
+		return false
+	}
+
+	conflicts := v.Buf.Conflicts()
+	if len(conflicts) == 0 {
+		messenger.Message("No merge conflicts")
+	} else {
+		next := 0
+		for i, c := range conflicts {
+			if c.Start > v.Cursor.Y {
+				next = i
+				break
+			}
+		}
+		v.jumpToConflict(conflicts, next)
+	}
+
+	if usePlugin {
+		return PostActionCall("NextConflict", v)
+	}
+	return true
+}
+
+// PreviousConflict moves the cursor to the previous merge conflict, wrapping around
+func (v *View) PreviousConflict(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("PreviousConflict", v) {
+		return false
+	}
+
+	conflicts := v.Buf.Conflicts()
+	if len(conflicts) == 0 {
+		messenger.Message("No merge conflicts")
+	} else {
+		prev := len(conflicts) - 1
+		for i := len(conflicts) - 1; i >= 0; i-- {
+			if conflicts[i].End < v.Cursor.Y {
+				prev = i
+				break
+			}
+		}
+		v.jumpToConflict(conflicts, prev)
+	}
+
+	if usePlugin {
+		return PostActionCall("PreviousConflict", v)
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConflicts(t *testing.T) {
+	var tests = []struct {
+		text string
+		want []Conflict
+	}{
+		{"a\nb\n", nil},
+		{"<<<<<<< HEAD\na\n=======\nb\n>>>>>>> branch\n", []Conflict{{0, -1, 2, 4}}},
+		{"x\n<<<<<<< ours\na\n||||||| base\no\n=======\nb\n>>>>>>> theirs\ny", []Conflict{{1, 3, 5, 7}}},
+		{"<<<<<<< HEAD\r\na\r\n=======\r\nb\r\n>>>>>>> branch\r\n", []Conflict{{0, -1, 2, 4}}},
+		// Incomplete conflicts and lookalike lines are ignored
+		{"<<<<<<< HEAD\na\n=======\n", nil},
+		{"<<<<<<<<\n=======\n>>>>>>>\n", nil},
+		{"<<<<<<<\n<<<<<<<\n=======\n>>>>>>>\n", []Conflict{{1, -1, 2, 3}}},
+		{"<<<<<<<\n=======\n>>>>>>>\n<<<<<<<\n=======\n>>>>>>>", []Conflict{{0, -1, 1, 2}, {3, -1, 4, 5}}},
+	}
+	for _, test := range tests {
+		if got := ParseConflicts(strings.Split(test.text, "\n")); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseConflicts(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestConflictRegion(t *testing.T) {
+	c := Conflict{1, 3, 5, 7}
+	want := []int{0, ConflictMarker, ConflictOurs, ConflictMarker, ConflictBase,
+		ConflictMarker, ConflictTheirs, ConflictMarker, 0}
+	for line, region := range want {
+		if got := c.Region(line); got != region {
+			t.Errorf("Region(%d) = %d, want %d", line, got, region)
+		}
+	}
+}
@@ -239,13 +239,9 @@ func (c *Cursor) RuneUnder(x int) rune {
 }
 
 // UpN moves the cursor up N lines (if possible)
+// Lines hidden by folds are skipped
 func (c *Cursor) UpN(amount int) {
-	proposedY := c.Y - amount
-	if proposedY < 0 {
-		proposedY = 0
-	} else if proposedY >= c.buf.NumLines {
-		proposedY = c.buf.NumLines - 1
-	}
+	proposedY := c.buf.moveVisible(c.Y, -amount)
 	if proposedY == c.Y {
 		return
 	}
@@ -294,7 +290,7 @@ func (c *Cursor) Right() {
 	}
 	if c.X < Count(c.buf.Line(c.Y)) {
 		c.X++
-	} else {
+	} else if c.buf.moveVisible(c.Y, 1) != c.Y {
 		c.Down()
 		c.Start()
 	}
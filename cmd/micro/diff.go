@@ -0,0 +1,470 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/zyedidia/tcell"
+)
+
+// A DiffPair compares the buffers of two views which are displayed side by side
+// The first view holds the old text and the second view the new text, so the
+// hunks use the same orientation as the git gutter
+// Filler rows are drawn in each view where the other side has extra lines so
+// that both sides stay aligned
+type DiffPair struct {
+	views [2]*View
+	hunks []GitHunk
+
+	// The number of filler rows drawn before each line of both sides
+	fillers [2]map[int]int
+	// The lines which have filler rows before them, sorted
+	fillerLines [2][]int
+	// The number of filler rows above the topline of each view
+	topFill [2]int
+
+	// The hunk kind of each changed line and the runes which changed within it
+	kinds   [2]map[int]int
+	changed [2]map[int][][2]int
+
+	// The buffer versions the diff was computed for
+	versions [2]int
+}
+
+// NewDiffPair starts comparing the buffers of the two views
+func NewDiffPair(oldView, newView *View) *DiffPair {
+	d := new(DiffPair)
+	d.views = [2]*View{oldView, newView}
+	d.versions = [2]int{-1, -1}
+	oldView.diff = d
+	newView.diff = d
+	// The rows of the two sides are aligned line by line
+	oldView.Buf.folds.closed = nil
+	newView.Buf.folds.closed = nil
+	d.update()
+	d.sync(newView)
+	return d
+}
+
+// side returns 0 if v shows the old text and 1 if v shows the new text
+func (d *DiffPair) side(v *View) int {
+	if v == d.views[0] {
+		return 0
+	}
+	return 1
+}
+
+// Close stops comparing the two views
+func (d *DiffPair) Close() {
+	for _, v := range d.views {
+		v.diff = nil
+	}
+}
+
+// update recomputes the diff if one of the buffers changed
+func (d *DiffPair) update() {
+	if d.views[0].Buf.version == d.versions[0] && d.views[1].Buf.version == d.versions[1] {
+		return
+	}
+	d.versions = [2]int{d.views[0].Buf.version, d.views[1].Buf.version}
+	d.hunks = GitDiffHunks(d.views[0].Buf.String(), d.views[1].Buf.String())
+
+	for s := 0; s < 2; s++ {
+		d.fillers[s] = make(map[int]int)
+		d.fillerLines[s] = nil
+		d.kinds[s] = make(map[int]int)
+		d.changed[s] = make(map[int][][2]int)
+	}
+	for _, h := range d.hunks {
+		for i := range h.Old {
+			if i < len(h.New) {
+				d.kinds[0][h.OldStart+i] = HunkModified
+				d.kinds[1][h.Start+i] = HunkModified
+				old, new := DiffChangedRunes(h.Old[i], h.New[i])
+				d.changed[0][h.OldStart+i] = old
+				d.changed[1][h.Start+i] = new
+			} else {
+				d.kinds[0][h.OldStart+i] = HunkDeleted
+			}
+		}
+		for i := len(h.Old); i < len(h.New); i++ {
+			d.kinds[1][h.Start+i] = HunkAdded
+		}
+		if len(h.New) > len(h.Old) {
+			d.fillers[0][h.OldStart+len(h.Old)] += len(h.New) - len(h.Old)
+		} else if len(h.Old) > len(h.New) {
+			d.fillers[1][h.Start+len(h.New)] += len(h.Old) - len(h.New)
+		}
+	}
+	for s := 0; s < 2; s++ {
+		for line := range d.fillers[s] {
+			d.fillerLines[s] = append(d.fillerLines[s], line)
+		}
+		sort.Ints(d.fillerLines[s])
+	}
+}
+
+// DiffChangedRunes compares two versions of a line and returns the ranges
+// of runes which differ in each of them
+func DiffChangedRunes(old, new string) ([][2]int, [][2]int) {
+	old = strings.TrimSuffix(old, "\n")
+	new = strings.TrimSuffix(new, "\n")
+	differ := dmp.New()
+	diffs := differ.DiffCleanupSemantic(differ.DiffMain(old, new, false))
+
+	var oldRanges, newRanges [][2]int
+	oldX, newX := 0, 0
+	for _, diff := range diffs {
+		n := Count(diff.Text)
+		switch diff.Type {
+		case dmp.DiffEqual:
+			oldX += n
+			newX += n
+		case dmp.DiffDelete:
+			oldRanges = append(oldRanges, [2]int{oldX, oldX + n})
+			oldX += n
+		case dmp.DiffInsert:
+			newRanges = append(newRanges, [2]int{newX, newX + n})
+			newX += n
+		}
+	}
+	return oldRanges, newRanges
+}
+
+// rowOf returns the aligned row of a line of the given side
+// Rows count the lines and filler rows of a side from the start of the buffer
+func (d *DiffPair) rowOf(s, line int) int {
+	row := line
+	for _, l := range d.fillerLines[s] {
+		if l > line {
+			break
+		}
+		row += d.fillers[s][l]
+	}
+	return row
+}
+
+// lineAt returns the first line of the given side whose aligned row is at least row
+func (d *DiffPair) lineAt(s, row int) int {
+	fill := 0
+	for _, l := range d.fillerLines[s] {
+		if row-fill < l {
+			return row - fill
+		}
+		if row-fill-d.fillers[s][l] <= l {
+			return l
+		}
+		fill += d.fillers[s][l]
+	}
+	return row - fill
+}
+
+// sync moves the other view of the pair to the scroll position and cursor line of v
+func (d *DiffPair) sync(v *View) {
+	d.update()
+	s := d.side(v)
+	o := 1 - s
+	other := d.views[o]
+
+	// Filler rows above the first line can only be seen from the top of the buffer
+	d.topFill[s] = 0
+	if v.Topline == 0 {
+		d.topFill[s] = d.fillers[s][0]
+	}
+	row := d.rowOf(s, v.Topline) - d.topFill[s]
+	other.Topline = d.lineAt(o, row)
+	d.topFill[o] = d.rowOf(o, other.Topline) - row
+
+	other.Cursor.Y = d.lineAt(o, d.rowOf(s, v.Cursor.Y))
+	other.Cursor.Relocate()
+}
+
+// fillerRows returns the number of filler rows to draw before a line of the view
+func (d *DiffPair) fillerRows(v *View, line int) int {
+	s := d.side(v)
+	if line == v.Topline {
+		return d.topFill[s]
+	}
+	return d.fillers[s][line]
+}
+
+// bottomline returns the first line of the view which doesn't fit on the screen
+func (d *DiffPair) bottomline(v *View) int {
+	rows := 0
+	line := v.Topline
+	for {
+		rows += d.fillerRows(v, line) + 1
+		if rows > v.Height {
+			return line
+		}
+		line++
+	}
+}
+
+// lineAtScreenRow returns the line displayed at the given row of the view
+// Filler rows belong to the line below them
+func (d *DiffPair) lineAtScreenRow(v *View, y int) int {
+	rows := 0
+	line := v.Topline
+	for {
+		rows += d.fillerRows(v, line) + 1
+		if y < rows {
+			return line
+		}
+		line++
+	}
+}
+
+// hunkStarts returns the first line of every hunk on the side of the view
+func (d *DiffPair) hunkStarts(v *View) []int {
+	var starts []int
+	for _, h := range d.hunks {
+		if d.side(v) == 0 {
+			starts = append(starts, h.OldStart)
+		} else {
+			starts = append(starts, h.Start)
+		}
+	}
+	return starts
+}
+
+// hunkAt returns the hunk which covers the given line of the view's side
+func (d *DiffPair) hunkAt(v *View, line int) (GitHunk, bool) {
+	d.update()
+	s := d.side(v)
+	for _, h := range d.hunks {
+		start, n := h.Start, len(h.New)
+		if s == 0 {
+			start, n = h.OldStart, len(h.Old)
+		}
+		if line >= start && line < start+n || n == 0 && line == start {
+			return h, true
+		}
+	}
+	return GitHunk{}, false
+}
+
+// lineStyle returns the style of a line of the view and whether the line changed
+func (d *DiffPair) lineStyle(v *View, line int) (tcell.Style, bool) {
+	kind, ok := d.kinds[d.side(v)][line]
+	if !ok {
+		return defStyle, false
+	}
+	group, fallback := "diff-modified", tcell.ColorDarkBlue
+	if kind == HunkAdded {
+		group, fallback = "diff-added", tcell.ColorDarkGreen
+	} else if kind == HunkDeleted {
+		group, fallback = "diff-deleted", tcell.ColorMaroon
+	}
+	if style, ok := colorscheme[group]; ok {
+		fg, _, _ := style.Decompose()
+		return defStyle.Background(fg), true
+	}
+	return defStyle.Background(fallback), true
+}
+
+// cellStyle applies the diff highlighting to the style of a rune in the view
+func (d *DiffPair) cellStyle(v *View, line, col int, style tcell.Style) tcell.Style {
+	lineStyle, ok := d.lineStyle(v, line)
+	if !ok {
+		return style
+	}
+	_, bg, _ := lineStyle.Decompose()
+	for _, r := range d.changed[d.side(v)][line] {
+		if col >= r[0] && col < r[1] {
+			if s, ok := colorscheme["diff-text"]; ok {
+				fg, _, _ := s.Decompose()
+				return style.Background(fg)
+			}
+			return style.Reverse(true)
+		}
+	}
+	return style.Background(bg)
+}
+
+// drawFiller draws a filler row at the given screen row of the view
+func (d *DiffPair) drawFiller(v *View, y int) {
+	style := defStyle.Foreground(tcell.ColorGray)
+	if s, ok := colorscheme["diff-filler"]; ok {
+		style = s
+	}
+	x := v.x
+	if v.x != 0 {
+		v.drawCell(x, y, '|', nil, defStyle.Reverse(true))
+		x++
+	}
+	for ; x < v.x+v.Width; x++ {
+		ch := ' '
+		if x >= v.x+v.lineNumOffset {
+			ch = '-'
+		}
+		v.drawCell(x, y, ch, nil, style)
+	}
+}
+
+// replaceLines replaces n lines of the buffer starting at line start with text
+func replaceLines(b *Buffer, start, n int, text string) {
+	from := Loc{0, start}
+	to := Loc{0, start + n}
+	if start >= b.NumLines {
+		from = b.End()
+	}
+	if start+n >= b.NumLines {
+		to = b.End()
+	}
+	b.Replace(from, to, text)
+}
+
+// copyHunk copies the hunk under the cursor of the view from one side to the other
+func (d *DiffPair) copyHunk(v *View, fromSide int) bool {
+	h, ok := d.hunkAt(v, v.Cursor.Y)
+	if !ok {
+		messenger.Error("No difference on this line")
+		return false
+	}
+	to := d.views[1-fromSide]
+	if to.Buf.Settings["readonly"].(bool) {
+		messenger.Error(to.Buf.GetName(), " is read-only")
+		return false
+	}
+	if fromSide == 0 {
+		replaceLines(to.Buf, h.Start, len(h.New), strings.Join(h.Old, ""))
+	} else {
+		replaceLines(to.Buf, h.OldStart, len(h.Old), strings.Join(h.New, ""))
+	}
+	to.Cursor.Relocate()
+	d.sync(v)
+	return true
+}
+
+// DiffGet replaces the hunk under the cursor with the text from the other side of the diff
+func (v *View) DiffGet(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("DiffGet", v) {
+		return false
+	}
+
+	if v.diff == nil {
+		messenger.Error("Not in diff mode")
+		return false
+	}
+	v.diff.copyHunk(v, 1-v.diff.side(v))
+
+	if usePlugin {
+		return PostActionCall("DiffGet", v)
+	}
+	return true
+}
+
+// DiffPut copies the hunk under the cursor to the other side of the diff
+func (v *View) DiffPut(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("DiffPut", v) {
+		return false
+	}
+
+	if v.diff == nil {
+		messenger.Error("Not in diff mode")
+		return false
+	}
+	v.diff.copyHunk(v, v.diff.side(v))
+
+	if usePlugin {
+		return PostActionCall("DiffPut", v)
+	}
+	return true
+}
+
+// openDiff opens buf next to the current view and compares it to the current buffer
+func openDiff(buf *Buffer) {
+	v := CurView()
+	if v.diff != nil {
+		v.diff.Close()
+	}
+	v.VSplit(buf)
+	other := CurView()
+	if buf.Settings["readonly"].(bool) {
+		other.Type = vtScratch
+	}
+	NewDiffPair(other, v)
+	tabs[curTab].CurView = v.Num
+}
+
+// openFileBuffer returns a buffer for the file at path, which must exist
+func openFileBuffer(path string) (*Buffer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewBuffer(file, path), nil
+}
+
+// Diff compares the current buffer to a file
+// With no arguments it compares the buffer to the file on disk
+// With two arguments it compares the two files in a new tab
+func Diff(args []string) {
+	home, _ := homedir.Dir()
+	for i := range args {
+		args[i] = strings.Replace(args[i], "~", home, 1)
+	}
+
+	switch len(args) {
+	case 0:
+		v := CurView()
+		if v.Buf.Path == "" {
+			messenger.Error("This buffer has no file")
+			return
+		}
+		data, err := ioutil.ReadFile(v.Buf.Path)
+		if err != nil {
+			messenger.Error(err.Error())
+			return
+		}
+		openDiff(NewScratchBuffer(string(data), v.Buf.GetName()+" (disk)", v.Buf.FileType()))
+	case 1:
+		buf, err := openFileBuffer(args[0])
+		if err != nil {
+			messenger.Error(err.Error())
+			return
+		}
+		openDiff(buf)
+	default:
+		var bufs [2]*Buffer
+		for i := range bufs {
+			var err error
+			if bufs[i], err = openFileBuffer(args[i]); err != nil {
+				messenger.Error(err.Error())
+				return
+			}
+		}
+		AddTabWithBuffer(bufs[0])
+		old := CurView()
+		old.VSplitIndex(bufs[1], 1)
+		NewDiffPair(old, CurView())
+	}
+}
+
+// DiffHead compares the current buffer to the version of its file in git's HEAD
+func DiffHead(args []string) {
+	v := CurView()
+	root, path, ok := gitFileOf(v)
+	if !ok {
+		return
+	}
+	base, err := GitHeadBlob(root, path)
+	if err != nil {
+		messenger.Error("git show: ", err.Error())
+		return
+	}
+	openDiff(NewScratchBuffer(base, "HEAD:"+path, v.Buf.FileType()))
+}
+
+// DiffOff stops comparing the current view
+func DiffOff(args []string) {
+	if v := CurView(); v.diff != nil {
+		v.diff.Close()
+	}
+}
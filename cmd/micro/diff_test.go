@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffChangedRunes(t *testing.T) {
+	var tests = []struct {
+		old, new         string
+		wantOld, wantNew [][2]int
+	}{
+		{"abc\n", "abc\n", nil, nil},
+		{"foo(a)\n", "foo(b)\n", [][2]int{{4, 5}}, [][2]int{{4, 5}}},
+		{"x := 1", "x := 10", nil, [][2]int{{6, 7}}},
+		{"∆ƒ bar", "∆ƒ", [][2]int{{2, 6}}, nil},
+	}
+	for _, test := range tests {
+		old, new := DiffChangedRunes(test.old, test.new)
+		if !reflect.DeepEqual(old, test.wantOld) || !reflect.DeepEqual(new, test.wantNew) {
+			t.Errorf("DiffChangedRunes(%q, %q) = %v, %v", test.old, test.new, old, new)
+		}
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	// Three filler rows before line 5 and one before line 8
+	d := new(DiffPair)
+	d.fillers[0] = map[int]int{5: 3, 8: 1}
+	d.fillerLines[0] = []int{5, 8}
+
+	var rows = []struct {
+		line, row int
+	}{
+		{0, 0}, {4, 4}, {5, 8}, {6, 9}, {7, 10}, {8, 12}, {9, 13},
+	}
+	for _, r := range rows {
+		if got := d.rowOf(0, r.line); got != r.row {
+			t.Errorf("rowOf(%d) = %d, want %d", r.line, got, r.row)
+		}
+		if got := d.lineAt(0, r.row); got != r.line {
+			t.Errorf("lineAt(%d) = %d, want %d", r.row, got, r.line)
+		}
+	}
+
+	// Rows inside a block of fillers belong to the line below
+	for row, line := range map[int]int{5: 5, 6: 5, 7: 5, 11: 8} {
+		if got := d.lineAt(0, row); got != line {
+			t.Errorf("lineAt(%d) = %d, want %d", row, got, line)
+		}
+	}
+}
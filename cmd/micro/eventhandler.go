@@ -82,7 +82,7 @@ func (eh *EventHandler) ApplyDiff(new string) {
 // Insert creates an insert text event and executes it
 func (eh *EventHandler) Insert(start Loc, text string) {
 	e := &TextEvent{
-		C:         eh.buf.Cursor,
+		C:         *eh.buf.Cursor,
 		EventType: TextEventInsert,
 		Text:      text,
 		Start:     start,
@@ -95,7 +95,7 @@ func (eh *EventHandler) Insert(start Loc, text string) {
 // Remove creates a remove text event and executes it
 func (eh *EventHandler) Remove(start, end Loc) {
 	e := &TextEvent{
-		C:         eh.buf.Cursor,
+		C:         *eh.buf.Cursor,
 		EventType: TextEventRemove,
 		Start:     start,
 		End:       end,
@@ -112,6 +112,10 @@ func (eh *EventHandler) Replace(start, end Loc, replace string) {
 
 // Execute a textevent and add it to the undo stack
 func (eh *EventHandler) Execute(t *TextEvent) {
+	if eh.buf.Settings["readonly"].(bool) {
+		messenger.Error("This buffer is read-only")
+		return
+	}
 	if eh.RedoStack.Len() > 0 {
 		eh.RedoStack = new(Stack)
 	}
@@ -171,7 +175,7 @@ func (eh *EventHandler) UndoOneEvent() {
 
 	// Set the cursor in the right place
 	teCursor := t.C
-	t.C = eh.buf.Cursor
+	t.C = *eh.buf.Cursor
 	eh.buf.Cursor.Goto(teCursor)
 
 	// Push it to the redo stack
@@ -214,7 +218,7 @@ func (eh *EventHandler) RedoOneEvent() {
 	UndoTextEvent(t, eh.buf)
 
 	teCursor := t.C
-	t.C = eh.buf.Cursor
+	t.C = *eh.buf.Cursor
 	eh.buf.Cursor.Goto(teCursor)
 
 	eh.UndoStack.Push(t)
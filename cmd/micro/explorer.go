@@ -0,0 +1,577 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zyedidia/tcell"
+)
+
+// An explorerRow is a file or a directory listed by the file explorer
+type explorerRow struct {
+	path  string
+	depth int
+	dir   bool
+	// The git status letter of the file, 0 if it is unchanged
+	status byte
+}
+
+// explorerState holds the directory tree listed by a file explorer view
+type explorerState struct {
+	// The view files are opened in
+	source *View
+	// The working directory the tree starts from
+	root     string
+	expanded map[string]bool
+	rows     []explorerRow
+	// The git status of the changed and ignored paths, relative to the root
+	status map[string]byte
+	// Whether the git status is being read, and whether it must be read
+	// again once it is
+	statusRunning, statusStale bool
+	// Whether the files ignored by git are listed
+	showIgnored bool
+}
+
+// parseGitStatus reads the output of git status --porcelain -z --ignored and
+// returns the status letter of each path under the directory prefix, relative
+// to that directory
+// The letters are M for modified, A for added, D for deleted, R for renamed,
+// U for conflicts, ? for untracked and ! for ignored paths, and directories
+// holding changes are modified
+func parseGitStatus(out, prefix string) map[string]byte {
+	status := make(map[string]byte)
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if len(f) < 4 {
+			continue
+		}
+		xy, p := f[:2], strings.TrimSuffix(f[3:], "/")
+		if xy[0] == 'R' || xy[0] == 'C' {
+			// The path the file was renamed or copied from comes next
+			i++
+		}
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		p = strings.TrimPrefix(p, prefix)
+		var letter byte
+		switch {
+		case xy == "??":
+			letter = '?'
+		case xy == "!!":
+			letter = '!'
+		case xy[0] == 'U' || xy[1] == 'U' || xy == "AA" || xy == "DD":
+			letter = 'U'
+		default:
+			letter = xy[1]
+			if letter == ' ' {
+				letter = xy[0]
+			}
+			switch letter {
+			case 'C':
+				letter = 'A'
+			case 'T':
+				letter = 'M'
+			}
+		}
+		status[p] = letter
+		if letter == '!' {
+			continue
+		}
+		for d := path.Dir(p); d != "."; d = path.Dir(d) {
+			if _, ok := status[d]; !ok {
+				status[d] = 'M'
+			}
+		}
+	}
+	return status
+}
+
+// gitStatus returns the git status of the paths under a directory, or nil if
+// it isn't in a repository
+func gitStatus(dir string) map[string]byte {
+	prefix, err := gitCommand(dir, "", "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil
+	}
+	out, err := gitCommand(dir, "", "status", "--porcelain", "-z", "--ignored")
+	if err != nil {
+		return nil
+	}
+	return parseGitStatus(out, strings.TrimSpace(prefix))
+}
+
+// pathStatus returns the git status letter of a path relative to the root
+// The files in untracked and ignored directories take the status of the
+// directory
+func pathStatus(status map[string]byte, rel string) byte {
+	if s, ok := status[rel]; ok {
+		return s
+	}
+	for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+		if s := status[d]; s == '?' || s == '!' {
+			return s
+		}
+	}
+	return 0
+}
+
+// explorerRows returns the rows of the tree of a directory, with the
+// directories first
+// Only the contents of the expanded directories are read
+func explorerRows(root string, expanded map[string]bool, status map[string]byte, showIgnored bool) []explorerRow {
+	rows := []explorerRow{{path: root, dir: true}}
+	var add func(dir string, depth int)
+	add = func(dir string, depth int) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].IsDir() && !files[j].IsDir()
+		})
+		for _, f := range files {
+			p := filepath.Join(dir, f.Name())
+			rel, _ := filepath.Rel(root, p)
+			s := pathStatus(status, filepath.ToSlash(rel))
+			if f.Name() == ".git" || s == '!' && !showIgnored {
+				continue
+			}
+			isDir := f.IsDir()
+			if !isDir && f.Mode()&os.ModeSymlink != 0 {
+				if info, err := os.Stat(p); err == nil {
+					isDir = info.IsDir()
+				}
+			}
+			rows = append(rows, explorerRow{path: p, depth: depth, dir: isDir, status: s})
+			if isDir && expanded[p] {
+				add(p, depth+1)
+			}
+		}
+	}
+	add(root, 1)
+	return rows
+}
+
+// explorerText returns the lines of the explorer tree
+func explorerText(rows []explorerRow, expanded map[string]bool) string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		name := filepath.Base(row.path)
+		marker := "  "
+		if row.dir {
+			name += "/"
+			marker = "▸ "
+			if expanded[row.path] {
+				marker = "▾ "
+			}
+		}
+		if i == 0 {
+			marker = ""
+		}
+		if row.status != 0 {
+			name += "  " + string(row.status)
+		}
+		lines[i] = strings.Repeat("  ", Max(row.depth-1, 0)) + marker + name
+	}
+	return strings.Join(lines, "\n")
+}
+
+// render refreshes the tree of an explorer view and keeps the cursor on the
+// same line
+// With reload the git status is read again in the background, and the tree
+// is rendered again with it once it is known
+func (e *explorerState) render(v *View, reload bool) {
+	if reload {
+		e.loadStatus(v)
+	}
+	e.rows = explorerRows(e.root, e.expanded, e.status, e.showIgnored)
+	y := v.Cursor.Y
+	v.Buf.SetText(explorerText(e.rows, e.expanded))
+	v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, Min(y, v.Buf.NumLines-1), 0
+	v.Relocate()
+}
+
+// loadStatus reads the git status of the tree of an explorer view in the
+// background, or once more after the read which is running
+// The status is handed back to the main loop through the jobs channel
+func (e *explorerState) loadStatus(v *View) {
+	if e.statusRunning {
+		e.statusStale = true
+		return
+	}
+	e.statusRunning = true
+	root := e.root
+	go func() {
+		status := gitStatus(root)
+		jobs <- JobFunction{func(string, ...string) {
+			e.statusRunning = false
+			if v.explorer != e {
+				return
+			}
+			if e.statusStale || e.root != root {
+				e.statusStale = false
+				e.loadStatus(v)
+				return
+			}
+			e.status = status
+			e.render(v, false)
+		}, "", nil}
+	}()
+}
+
+// selectPath moves the cursor of an explorer view to the row of a path
+func (e *explorerState) selectPath(v *View, p string) {
+	for i, row := range e.rows {
+		if row.path == p {
+			v.Cursor.Y = i
+			v.Relocate()
+			return
+		}
+	}
+}
+
+// explorerLineStyle returns the color of a row of an explorer view from its
+// git status
+func (v *View) explorerLineStyle(line int) (tcell.Style, bool) {
+	if v.explorer == nil || line >= len(v.explorer.rows) {
+		return defStyle, false
+	}
+	switch v.explorer.rows[line].status {
+	case 'A', '?':
+		return gitKindStyle(HunkAdded), true
+	case 'M', 'R':
+		return gitKindStyle(HunkModified), true
+	case 'D', 'U':
+		return gitKindStyle(HunkDeleted), true
+	case '!':
+		if style, ok := colorscheme["comment"]; ok {
+			return style, true
+		}
+		return defStyle.Foreground(tcell.ColorGray), true
+	}
+	return defStyle, false
+}
+
+// refreshExplorers renders the explorer views again, for instance after a
+// file was saved
+func refreshExplorers() {
+	for _, t := range tabs {
+		for _, v := range t.views {
+			if v.explorer != nil {
+				v.explorer.render(v, true)
+			}
+		}
+	}
+}
+
+// chdirExplorers shows the tree of a new working directory in the explorer
+// views
+func chdirExplorers(wd string) {
+	for _, t := range tabs {
+		for _, v := range t.views {
+			if v.explorer != nil {
+				v.explorer.root = wd
+				v.explorer.render(v, true)
+			}
+		}
+	}
+}
+
+// Explorer opens a split on the left which lists the files of the working
+// directory as a tree, or closes it if it is already open
+func Explorer(args []string) {
+	for _, view := range tabs[curTab].views {
+		if view.explorer != nil {
+			view.Quit(false)
+			return
+		}
+	}
+	v := CurView()
+	wd, err := os.Getwd()
+	if err != nil {
+		messenger.Error(err.Error())
+		return
+	}
+	ev := v.openTreeSplit("Explorer", explorerEnter, explorerToggle)
+	for name, action := range explorerActions {
+		ev.treeActions[name] = action
+	}
+	ev.explorer = &explorerState{source: v, root: wd, expanded: make(map[string]bool)}
+	ev.explorer.render(ev, true)
+	if p := v.Buf.AbsPath; v.Buf.Path != "" && strings.HasPrefix(p, wd+string(filepath.Separator)) {
+		// Show the file of the view
+		for d := filepath.Dir(p); d != wd && len(d) > len(wd); d = filepath.Dir(d) {
+			ev.explorer.expanded[d] = true
+		}
+		ev.explorer.render(ev, false)
+		ev.explorer.selectPath(ev, p)
+	}
+}
+
+// explorerTarget returns the view the explorer opens files in: the view it
+// was opened from, or another view of the tab
+// If the explorer is alone in its tab a split is opened next to it
+func (e *explorerState) target(ev *View) *View {
+	if viewIsOpen(e.source) && e.source.TabNum == ev.TabNum {
+		return e.source
+	}
+	for _, view := range tabs[ev.TabNum].views {
+		if view.explorer == nil && view.Type == vtDefault {
+			e.source = view
+			return view
+		}
+	}
+	ev.VSplitIndex(NewBuffer(strings.NewReader(""), ""), ev.Num+1)
+	e.source = CurView()
+	return e.source
+}
+
+// explorerOpen opens the file of a row in the current split, a new vertical
+// or horizontal split, or a new tab
+func explorerOpen(ev *View, line int, how rune) {
+	e := ev.explorer
+	if line >= len(e.rows) || e.rows[line].dir {
+		return
+	}
+	p := workingDirPath(e.rows[line].path)
+	target := e.target(ev)
+	tabs[curTab].CurView = target.Num
+	switch how {
+	case 'v':
+		VSplit([]string{p})
+		e.source = CurView()
+	case 's':
+		HSplit([]string{p})
+		e.source = CurView()
+	case 't':
+		NewTab([]string{p})
+	default:
+		if target.CanClose() {
+			target.Open(p)
+		}
+	}
+}
+
+// explorerEnter opens the file of the row under the cursor in the current
+// split, or expands or collapses its directory
+func explorerEnter(ev *View, line int) {
+	if line < len(ev.explorer.rows) && ev.explorer.rows[line].dir {
+		explorerToggle(ev, line)
+		return
+	}
+	explorerOpen(ev, line, 0)
+}
+
+// explorerToggle expands or collapses the directory of the row under the
+// cursor, or the directory of the file
+func explorerToggle(ev *View, line int) {
+	e := ev.explorer
+	if line == 0 || line >= len(e.rows) {
+		return
+	}
+	row := e.rows[line]
+	if !row.dir {
+		// Collapse the directory of the file
+		dir := filepath.Dir(row.path)
+		if dir == e.root {
+			return
+		}
+		delete(e.expanded, dir)
+		e.render(ev, false)
+		e.selectPath(ev, dir)
+		return
+	}
+	if e.expanded[row.path] {
+		delete(e.expanded, row.path)
+	} else {
+		e.expanded[row.path] = true
+	}
+	e.render(ev, false)
+}
+
+// explorerDir returns the directory new files are created in from a row: the
+// directory of the row itself, or the one of its file
+func (e *explorerState) explorerDir(line int) string {
+	if line >= len(e.rows) {
+		return e.root
+	}
+	if row := e.rows[line]; row.dir {
+		return row.path
+	}
+	return filepath.Dir(e.rows[line].path)
+}
+
+// inPath returns whether an absolute path is the one of dir or of a file in
+// the directory dir
+func inPath(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// renameOpenBuffers points the buffers, the marks and the bookmarks of a file
+// or of the files in a directory to their new path after a rename
+func renameOpenBuffers(from, to string) {
+	for _, b := range bufferList {
+		if b.Path != "" && inPath(b.AbsPath, from) {
+			b.AbsPath = to + b.AbsPath[len(from):]
+			b.Path = workingDirPath(b.AbsPath)
+		}
+	}
+	renameMarks(from, to)
+	renameBookmarks(from, to)
+}
+
+// explorerCreate asks for the name of a new file or directory and creates it
+// next to the row under the cursor
+func explorerCreate(ev *View, line int) {
+	e := ev.explorer
+	dir := e.explorerDir(line)
+	name, canceled := messenger.Prompt("New file in "+workingDirPath(dir)+" (end with / for a directory): ", "", "Explorer", NoCompletion)
+	if canceled || strings.TrimSpace(name) == "" {
+		return
+	}
+	p := filepath.Join(dir, name)
+	if _, err := os.Stat(p); err == nil {
+		messenger.Error(workingDirPath(p), " already exists")
+		return
+	}
+	var err error
+	if strings.HasSuffix(name, "/") {
+		err = os.MkdirAll(p, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(p), 0755); err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		messenger.Error(err.Error())
+		return
+	}
+	for d := filepath.Dir(p); len(d) > len(e.root); d = filepath.Dir(d) {
+		e.expanded[d] = true
+	}
+	e.render(ev, true)
+	e.selectPath(ev, p)
+}
+
+// explorerMove renames the file or directory of the row under the cursor
+// With rename only its name is asked, otherwise its new path, and moving it
+// onto a directory moves it into that directory
+func explorerMove(ev *View, line int, rename bool) {
+	e := ev.explorer
+	if line == 0 || line >= len(e.rows) {
+		return
+	}
+	from := e.rows[line].path
+	var to string
+	if rename {
+		name, canceled := messenger.Prompt("Rename to: ", filepath.Base(from), "Explorer", NoCompletion)
+		if canceled || strings.TrimSpace(name) == "" || strings.ContainsRune(name, filepath.Separator) {
+			return
+		}
+		to = filepath.Join(filepath.Dir(from), name)
+	} else {
+		dest, canceled := messenger.Prompt("Move to: ", workingDirPath(from), "Explorer", FileCompletion)
+		if canceled || strings.TrimSpace(dest) == "" {
+			return
+		}
+		var err error
+		if to, err = filepath.Abs(dest); err != nil {
+			messenger.Error(err.Error())
+			return
+		}
+		if info, err := os.Stat(to); err == nil && info.IsDir() {
+			to = filepath.Join(to, filepath.Base(from))
+		}
+	}
+	if to == from {
+		return
+	}
+	if _, err := os.Stat(to); err == nil {
+		messenger.Error(workingDirPath(to), " already exists")
+		return
+	}
+	if !rename {
+		if yes, canceled := messenger.YesNoPrompt("Move " + workingDirPath(from) + " to " + workingDirPath(to) + "? (y,n)"); canceled || !yes {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			messenger.Error(err.Error())
+			return
+		}
+	}
+	if err := os.Rename(from, to); err != nil {
+		messenger.Error(err.Error())
+		return
+	}
+	renameOpenBuffers(from, to)
+	var moved []string
+	for p := range e.expanded {
+		if inPath(p, from) {
+			moved = append(moved, p)
+		}
+	}
+	for _, p := range moved {
+		delete(e.expanded, p)
+		e.expanded[to+p[len(from):]] = true
+	}
+	for d := filepath.Dir(to); len(d) > len(e.root); d = filepath.Dir(d) {
+		e.expanded[d] = true
+	}
+	e.render(ev, true)
+	e.selectPath(ev, to)
+}
+
+// explorerDelete deletes the file or directory of the row under the cursor
+// once confirmed
+func explorerDelete(ev *View, line int) {
+	e := ev.explorer
+	if line == 0 || line >= len(e.rows) {
+		return
+	}
+	row := e.rows[line]
+	prompt := "Delete " + workingDirPath(row.path) + "? (y,n)"
+	if row.dir {
+		prompt = "Delete " + workingDirPath(row.path) + " and all its files? (y,n)"
+	}
+	if yes, canceled := messenger.YesNoPrompt(prompt); canceled || !yes {
+		return
+	}
+	if err := os.RemoveAll(row.path); err != nil {
+		messenger.Error(err.Error())
+		return
+	}
+	removeBookmarks(row.path)
+	removeMarks(row.path)
+	for p := range e.expanded {
+		if inPath(p, row.path) {
+			delete(e.expanded, p)
+		}
+	}
+	e.render(ev, true)
+	messenger.Message("Deleted ", workingDirPath(row.path))
+}
+
+// explorerActions are the actions of the explorer keymap context, run on the
+// row under the cursor
+var explorerActions = map[string]func(ev *View, line int){
+	"OpenVSplit": func(ev *View, line int) { explorerOpen(ev, line, 'v') },
+	"OpenHSplit": func(ev *View, line int) { explorerOpen(ev, line, 's') },
+	"OpenTab":    func(ev *View, line int) { explorerOpen(ev, line, 't') },
+	"NewFile":    explorerCreate,
+	"Rename":     func(ev *View, line int) { explorerMove(ev, line, true) },
+	"Move":       func(ev *View, line int) { explorerMove(ev, line, false) },
+	"Delete":     explorerDelete,
+	"ToggleIgnored": func(ev *View, line int) {
+		ev.explorer.showIgnored = !ev.explorer.showIgnored
+		ev.explorer.render(ev, false)
+	},
+	"Refresh": func(ev *View, line int) { ev.explorer.render(ev, true) },
+}
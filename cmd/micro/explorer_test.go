@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	out := " M sub/a.go\x00A  sub/new.go\x00R  sub/b.go\x00sub/old.go\x00?? sub/tmp/\x00!! sub/build/\x00UU sub/c.go\x00 D other/d.go\x00"
+	status := parseGitStatus(out, "sub/")
+	want := map[string]byte{
+		"a.go":   'M',
+		"new.go": 'A',
+		"b.go":   'R',
+		"tmp":    '?',
+		"build":  '!',
+		"c.go":   'U',
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("parseGitStatus: got %q, want %q", status, want)
+	}
+
+	status = parseGitStatus(" M a/b/c.go\x00", "")
+	if status["a"] != 'M' || status["a/b"] != 'M' {
+		t.Errorf("parseGitStatus: the directories of a change are %q", status)
+	}
+	if s := pathStatus(map[string]byte{"tmp": '?'}, "tmp/x/y.go"); s != '?' {
+		t.Errorf("pathStatus in an untracked directory: got %q", s)
+	}
+}
+
+func TestExplorerRows(t *testing.T) {
+	root, err := ioutil.TempDir("", "explorer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, p := range []string{"b.go", "a/x.go", "build/out", ".git/HEAD"} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(p)), 0755)
+		ioutil.WriteFile(filepath.Join(root, p), nil, 0644)
+	}
+	status := map[string]byte{"b.go": 'M', "build": '!'}
+
+	names := func(rows []explorerRow) []string {
+		var names []string
+		for _, row := range rows[1:] {
+			rel, _ := filepath.Rel(root, row.path)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return names
+	}
+	rows := explorerRows(root, map[string]bool{}, status, false)
+	if got, want := names(rows), []string{"a", "b.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("explorerRows: got %v, want %v", got, want)
+	}
+	if rows[2].status != 'M' || !rows[1].dir {
+		t.Errorf("explorerRows: wrong rows %v", rows)
+	}
+
+	rows = explorerRows(root, map[string]bool{filepath.Join(root, "a"): true}, status, true)
+	if got, want := names(rows), []string{"a", "a/x.go", "build", "b.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("explorerRows expanded: got %v, want %v", got, want)
+	}
+	if rows[2].depth != 2 {
+		t.Errorf("explorerRows: a/x.go has depth %d", rows[2].depth)
+	}
+}
+
+func TestRenameBookmarks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "bookmarks")
+	defer os.RemoveAll(dir)
+	defer func(d string) { configDir = d }(configDir)
+	configDir = dir
+
+	AddBookmark("a", "/d/x.go", Loc{1, 2})
+	AddBookmark("B", "/d/x.go", Loc{0, 4})
+	AddBookmark("a", "/y.go", Loc{0, 1})
+	jump := NewMark("/d/sub/z.go", Loc{2, 3})
+	defer jump.Delete()
+
+	renameOpenBuffers("/d", "/e")
+	want := []Bookmark{
+		{"B", "/e/x.go", Loc{0, 4}},
+		{"a", "/e/x.go", Loc{1, 2}},
+		{"a", "/y.go", Loc{0, 1}},
+	}
+	if list := ListBookmarks(); !reflect.DeepEqual(list, want) {
+		t.Errorf("ListBookmarks after rename: got %v, want %v", list, want)
+	}
+	if m := GetBookmark("a", "/e/x.go"); m == nil || m.Loc != (Loc{1, 2}) {
+		t.Errorf("GetBookmark a of /e/x.go: got %v", m)
+	}
+	if jump.Path != "/e/sub/z.go" || !fileMarks["/e/sub/z.go"][jump] {
+		t.Errorf("jump mark not renamed: %v", jump)
+	}
+	b := &Buffer{AbsPath: "/e/x.go"}
+	b.moveMarks(func(loc Loc) Loc { return locAfterInsert(loc, Loc{0, 0}, Loc{0, 1}) })
+	if lines := b.bookmarkLines(); !reflect.DeepEqual(lines, map[int]rune{3: 'a', 5: 'B'}) {
+		t.Errorf("bookmarkLines after rename: got %v", lines)
+	}
+
+	removeBookmarks("/e")
+	removeMarks("/e")
+	want = []Bookmark{{"a", "/y.go", Loc{0, 1}}}
+	if list := ListBookmarks(); !reflect.DeepEqual(list, want) {
+		t.Errorf("ListBookmarks after delete: got %v, want %v", list, want)
+	}
+	removeBookmark('a', "/y.go")
+	if len(fileMarks) != 0 {
+		t.Errorf("marks of deleted files are still moved: %v", fileMarks)
+	}
+}
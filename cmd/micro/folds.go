@@ -0,0 +1,491 @@
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zyedidia/tcell"
+)
+
+// A Fold is a range of lines which can be folded: when it is closed, the
+// lines after Start up to End are hidden under the line Start
+type Fold struct {
+	Start, End int
+}
+
+// foldState holds the closed folds of a buffer, and the ranges of lines which
+// can be folded
+type foldState struct {
+	closed []Fold
+	ranges []Fold
+	// The fold method and the buffer version the ranges were found for
+	method  string
+	version int
+}
+
+// validateFoldMethod checks the value of the foldmethod option
+func validateFoldMethod(option string, value interface{}) error {
+	switch value {
+	case "auto", "indent", "syntax":
+		return nil
+	}
+	return errors.New(option + " must be auto, indent or syntax")
+}
+
+// sortFolds sorts folds by their first line, outer folds first, and removes
+// the duplicates
+func sortFolds(folds []Fold) []Fold {
+	sort.Slice(folds, func(i, j int) bool {
+		if folds[i].Start != folds[j].Start {
+			return folds[i].Start < folds[j].Start
+		}
+		return folds[i].End > folds[j].End
+	})
+	var sorted []Fold
+	for i, f := range folds {
+		if i == 0 || f != folds[i-1] {
+			sorted = append(sorted, f)
+		}
+	}
+	return sorted
+}
+
+// indentFoldRanges returns the ranges of the lines which are indented more
+// than the line above them, ignoring blank lines
+func indentFoldRanges(lines []string, tabsize int) []Fold {
+	type open struct{ line, indent int }
+	var ranges []Fold
+	var stack []open
+	last := -1
+	for i := 0; i <= len(lines); i++ {
+		// Everything is closed after the last line
+		indent := -1
+		if i < len(lines) {
+			if strings.TrimSpace(lines[i]) == "" {
+				continue
+			}
+			indent = StringWidth(GetLeadingWhitespace(lines[i]), tabsize)
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if last > top.line {
+				ranges = append(ranges, Fold{top.line, last})
+			}
+		}
+		stack = append(stack, open{i, indent})
+		last = i
+	}
+	return ranges
+}
+
+// syntaxFoldRanges returns the ranges of the start and end regions of the
+// syntax rules which span several lines, such as block comments
+func syntaxFoldRanges(rules []SyntaxRule, lines []string) []Fold {
+	text := strings.Join(lines, "\n")
+	starts := make([]int, len(lines))
+	for i, offset := 1, 0; i < len(lines); i++ {
+		offset += len(lines[i-1]) + 1
+		starts[i] = offset
+	}
+	lineOf := func(offset int) int {
+		return sort.SearchInts(starts, offset+1) - 1
+	}
+	var ranges []Fold
+	for _, rule := range rules {
+		if !rule.startend {
+			continue
+		}
+		for _, m := range rule.regex.FindAllStringIndex(text, -1) {
+			if start, end := lineOf(m[0]), lineOf(m[1]-1); end > start {
+				ranges = append(ranges, Fold{start, end})
+			}
+		}
+	}
+	return ranges
+}
+
+// goFoldRanges returns the ranges of the blocks, composite literals,
+// parenthesized declarations, field lists, calls, case clauses and comments
+// of Go source
+// The line of a closing brace or parenthesis is left out, so it stays visible
+func goFoldRanges(src string) ([]Fold, bool) {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if f == nil {
+		return nil, false
+	}
+	var ranges []Fold
+	add := func(start, end token.Pos, closing bool) {
+		ranges = appendLineRange(ranges, fset, start, end, closing)
+	}
+	for _, c := range f.Comments {
+		add(c.Pos(), c.End(), false)
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			add(n.Lbrace, n.Rbrace, true)
+		case *ast.CompositeLit:
+			add(n.Lbrace, n.Rbrace, true)
+		case *ast.GenDecl:
+			add(n.Lparen, n.Rparen, true)
+		case *ast.FieldList:
+			add(n.Opening, n.Closing, true)
+		case *ast.CallExpr:
+			add(n.Lparen, n.Rparen, true)
+		case *ast.CaseClause:
+			add(n.Case, n.End(), false)
+		case *ast.CommClause:
+			add(n.Case, n.End(), false)
+		}
+		return true
+	})
+	return ranges, true
+}
+
+// appendLineRange appends the lines from the one of start to the one of end
+// to ranges if they are more than one, leaving out the line of end if it only
+// holds a closing brace or parenthesis
+func appendLineRange(ranges []Fold, fset *token.FileSet, start, end token.Pos, closing bool) []Fold {
+	if !start.IsValid() || !end.IsValid() {
+		return ranges
+	}
+	s, e := fset.Position(start).Line-1, fset.Position(end).Line-1
+	if closing {
+		e--
+	}
+	if e > s {
+		ranges = append(ranges, Fold{s, e})
+	}
+	return ranges
+}
+
+// foldRanges returns the ranges of lines which can be folded in the buffer,
+// sorted by their first line, outer ranges first
+// With the auto fold method Go files are folded by their syntax tree, and
+// other files by indentation and by the regions of their syntax file
+func (b *Buffer) foldRanges() []Fold {
+	method := b.Settings["foldmethod"].(string)
+	if b.folds.method == method && b.folds.version == b.version {
+		return b.folds.ranges
+	}
+	lines := b.Lines(0, b.NumLines)
+	tabsize := int(b.Settings["tabsize"].(float64))
+	var ranges []Fold
+	switch method {
+	case "indent":
+		ranges = indentFoldRanges(lines, tabsize)
+	case "syntax":
+		ranges = syntaxFoldRanges(b.rules, lines)
+	default:
+		var ok bool
+		if b.FileType() == "go" {
+			ranges, ok = goFoldRanges(strings.Join(lines, "\n"))
+		}
+		if !ok {
+			ranges = append(indentFoldRanges(lines, tabsize), syntaxFoldRanges(b.rules, lines)...)
+		}
+	}
+	b.folds.ranges = sortFolds(ranges)
+	b.folds.method, b.folds.version = method, b.version
+	return b.folds.ranges
+}
+
+// move moves the closed folds after an edit, dropping those which don't hide
+// any line anymore
+func (f *foldState) move(move func(loc Loc) Loc) {
+	closed := f.closed[:0]
+	for _, c := range f.closed {
+		c.Start, c.End = move(Loc{0, c.Start}).Y, move(Loc{0, c.End}).Y
+		if c.End > c.Start {
+			closed = append(closed, c)
+		}
+	}
+	f.closed = closed
+}
+
+// hiddenLines returns the ranges of the lines hidden by the closed folds,
+// sorted and merged, as the first and the last hidden line of each
+func (b *Buffer) hiddenLines() []Fold {
+	var hidden []Fold
+	for _, f := range b.folds.closed {
+		if end := Min(f.End, b.NumLines-1); end > f.Start {
+			hidden = append(hidden, Fold{f.Start + 1, end})
+		}
+	}
+	sort.Slice(hidden, func(i, j int) bool {
+		return hidden[i].Start < hidden[j].Start
+	})
+	var merged []Fold
+	for _, h := range hidden {
+		if n := len(merged); n > 0 && h.Start <= merged[n-1].End+1 {
+			merged[n-1].End = Max(merged[n-1].End, h.End)
+		} else {
+			merged = append(merged, h)
+		}
+	}
+	return merged
+}
+
+// visibleIndex returns the index of a line among the lines which aren't
+// hidden by folds; a hidden line has the index of the next visible line
+func (b *Buffer) visibleIndex(line int) int {
+	i := line
+	for _, h := range b.hiddenLines() {
+		if h.Start >= line {
+			break
+		}
+		i -= Min(h.End, line-1) - h.Start + 1
+	}
+	return i
+}
+
+// visibleLine returns the line with the given index among the lines which
+// aren't hidden by folds
+func (b *Buffer) visibleLine(i int) int {
+	line := i
+	for _, h := range b.hiddenLines() {
+		if h.Start > line {
+			break
+		}
+		line += h.End - h.Start + 1
+	}
+	return line
+}
+
+// visibleLines returns the number of lines which aren't hidden by folds
+func (b *Buffer) visibleLines() int {
+	n := b.NumLines
+	for _, h := range b.hiddenLines() {
+		n -= h.End - h.Start + 1
+	}
+	return n
+}
+
+// nextVisible returns the first line after a line which isn't hidden by
+// folds, which is past the end of the buffer after the last visible line
+func (b *Buffer) nextVisible(line int) int {
+	return b.visibleLine(b.visibleIndex(line) + 1)
+}
+
+// moveVisible returns the line n visible lines below a line, or above it if
+// n is negative, staying in the buffer
+func (b *Buffer) moveVisible(line, n int) int {
+	i := Max(0, Min(b.visibleIndex(line)+n, b.visibleLines()-1))
+	return b.visibleLine(i)
+}
+
+// foldHeader returns the line a hidden line is folded under, or the line
+// itself if it is visible
+func (b *Buffer) foldHeader(line int) int {
+	for {
+		header := line
+		for _, f := range b.folds.closed {
+			if f.Start < line && line <= f.End && f.Start < header {
+				header = f.Start
+			}
+		}
+		if header == line {
+			return line
+		}
+		line = header
+	}
+}
+
+// closedFold returns the outermost closed fold which starts on a line
+func (b *Buffer) closedFold(line int) (Fold, bool) {
+	fold, ok := Fold{}, false
+	for _, f := range b.folds.closed {
+		if f.Start == line && (!ok || f.End > fold.End) {
+			fold, ok = f, true
+		}
+	}
+	return fold, ok
+}
+
+// openFoldsAt opens the closed folds which hide a line
+func (b *Buffer) openFoldsAt(line int) {
+	closed := b.folds.closed[:0]
+	for _, f := range b.folds.closed {
+		if f.Start >= line || line > f.End {
+			closed = append(closed, f)
+		}
+	}
+	b.folds.closed = closed
+}
+
+// foldToClose returns the innermost fold range around a line which can still
+// be closed
+func (b *Buffer) foldToClose(line int) (Fold, bool) {
+	fold, ok := Fold{}, false
+	for _, r := range b.foldRanges() {
+		if r.Start > line || line > r.End {
+			continue
+		}
+		if c, closed := b.closedFold(r.Start); closed && c.End >= r.End {
+			// Closing it would not hide anything more
+			continue
+		}
+		if !ok || r.Start > fold.Start || r.Start == fold.Start && r.End < fold.End {
+			fold, ok = r, true
+		}
+	}
+	return fold, ok
+}
+
+// foldMarkers returns the fold marker of the lines where a fold range starts:
+// ▸ if the fold is closed, ▾ if it is open
+func (b *Buffer) foldMarkers() map[int]rune {
+	markers := make(map[int]rune)
+	for _, r := range b.foldRanges() {
+		markers[r.Start] = '▾'
+	}
+	for _, f := range b.folds.closed {
+		markers[f.Start] = '▸'
+	}
+	return markers
+}
+
+// foldStyle returns the style of the fold markers and of the number of lines
+// shown after a closed fold
+func foldStyle() tcell.Style {
+	if style, ok := colorscheme["fold"]; ok {
+		return style
+	}
+	if style, ok := colorscheme["comment"]; ok {
+		return style
+	}
+	return defStyle
+}
+
+// foldSummary returns the text shown after the first line of a closed fold
+func foldSummary(f Fold) string {
+	if f.End-f.Start == 1 {
+		return " ⋯ 1 line"
+	}
+	return " ⋯ " + strconv.Itoa(f.End-f.Start) + " lines"
+}
+
+// lineAtRow returns the line of the buffer shown on a row of the view,
+// counting from the top line without soft wrapping
+func (v *View) lineAtRow(row int) int {
+	return v.Buf.visibleLine(v.Buf.visibleIndex(v.Topline) + row)
+}
+
+// canFold returns whether folds can be used in the view, showing an error if
+// they can't
+func (v *View) canFold() bool {
+	if v.diff != nil {
+		messenger.Error("Folds are not available in diff mode")
+		return false
+	}
+	return true
+}
+
+// foldCursor moves the cursor out of the folds which hide it, to the line
+// they are folded under
+func (v *View) foldCursor() {
+	if y := v.Buf.foldHeader(v.Cursor.Y); y != v.Cursor.Y {
+		v.Cursor.ResetSelection()
+		v.Cursor.Y = y
+		v.Cursor.X = Min(v.Cursor.X, Count(v.Buf.Line(y)))
+		v.Cursor.LastVisualX = v.Cursor.GetVisualX()
+	}
+}
+
+// Fold closes the innermost fold around the cursor which is open
+func (v *View) Fold(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("Fold", v) {
+		return false
+	}
+
+	if v.canFold() {
+		if f, ok := v.Buf.foldToClose(v.Cursor.Y); ok {
+			v.Buf.folds.closed = append(v.Buf.folds.closed, f)
+			v.foldCursor()
+		} else {
+			messenger.Error("Nothing to fold here")
+		}
+	}
+
+	if usePlugin {
+		return PostActionCall("Fold", v)
+	}
+	return true
+}
+
+// Unfold opens the closed folds on the line of the cursor
+func (v *View) Unfold(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("Unfold", v) {
+		return false
+	}
+
+	if v.canFold() {
+		if _, ok := v.Buf.closedFold(v.Cursor.Y); ok {
+			v.Buf.openFoldsAt(v.Cursor.Y + 1)
+		} else {
+			messenger.Error("No closed fold on this line")
+		}
+	}
+
+	if usePlugin {
+		return PostActionCall("Unfold", v)
+	}
+	return true
+}
+
+// ToggleFold opens the closed folds on the line of the cursor, or closes the
+// innermost fold around it
+func (v *View) ToggleFold(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ToggleFold", v) {
+		return false
+	}
+
+	if _, ok := v.Buf.closedFold(v.Cursor.Y); ok {
+		v.Unfold(false)
+	} else {
+		v.Fold(false)
+	}
+
+	if usePlugin {
+		return PostActionCall("ToggleFold", v)
+	}
+	return true
+}
+
+// FoldAll closes all the folds of the buffer
+func (v *View) FoldAll(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("FoldAll", v) {
+		return false
+	}
+
+	if v.canFold() {
+		v.Buf.folds.closed = append([]Fold(nil), v.Buf.foldRanges()...)
+		v.foldCursor()
+	}
+
+	if usePlugin {
+		return PostActionCall("FoldAll", v)
+	}
+	return true
+}
+
+// UnfoldAll opens all the folds of the buffer
+func (v *View) UnfoldAll(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("UnfoldAll", v) {
+		return false
+	}
+
+	v.Buf.folds.closed = nil
+
+	if usePlugin {
+		return PostActionCall("UnfoldAll", v)
+	}
+	return true
+}
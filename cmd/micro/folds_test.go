@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFoldRanges(t *testing.T) {
+	lines := []string{
+		"func a() {",
+		"\tx",
+		"\tif y {",
+		"\t\tz",
+		"",
+		"\t}",
+		"}",
+	}
+	if got, want := indentFoldRanges(lines, 4), []Fold{{2, 3}, {0, 5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("indentFoldRanges: got %v, want %v", got, want)
+	}
+
+	lines = []string{
+		"a /* one",
+		"two */ b /* three */",
+		"/*",
+		"",
+		"*/",
+	}
+	rules := []SyntaxRule{{regex: regexp.MustCompile(`(?s)/\*.*?\*/`), startend: true}}
+	if got, want := syntaxFoldRanges(rules, lines), []Fold{{0, 1}, {2, 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("syntaxFoldRanges: got %v, want %v", got, want)
+	}
+
+	src := strings.Join([]string{
+		"package p",
+		"",
+		"import (",
+		"\t\"a\"",
+		"\t\"b\"",
+		")",
+		"",
+		"/* long",
+		"comment */",
+		"func f() {",
+		"\tswitch {",
+		"\tcase true:",
+		"\t\tg(1,",
+		"\t\t\t2)",
+		"\t}",
+		"}",
+	}, "\n")
+	ranges, ok := goFoldRanges(src)
+	want := []Fold{{2, 4}, {7, 8}, {9, 14}, {10, 13}, {11, 13}}
+	if got := sortFolds(ranges); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("goFoldRanges: got %v %v, want %v", got, ok, want)
+	}
+}
+
+func TestVisibleLines(t *testing.T) {
+	b := &Buffer{NumLines: 10}
+	b.folds.closed = []Fold{{1, 3}, {2, 5}, {7, 8}}
+
+	if n := b.visibleLines(); n != 5 {
+		t.Errorf("visibleLines: got %d, want 5", n)
+	}
+	for line, want := range map[int]int{0: 0, 1: 1, 2: 2, 5: 2, 6: 2, 7: 3, 8: 4, 9: 4} {
+		if i := b.visibleIndex(line); i != want {
+			t.Errorf("visibleIndex(%d): got %d, want %d", line, i, want)
+		}
+	}
+	for i, want := range []int{0, 1, 6, 7, 9, 10} {
+		if line := b.visibleLine(i); line != want {
+			t.Errorf("visibleLine(%d): got %d, want %d", i, line, want)
+		}
+	}
+	tests := []struct{ line, n, want int }{
+		{1, 1, 6},
+		{9, -2, 6},
+		{9, 5, 9},
+		{0, -1, 0},
+	}
+	for _, test := range tests {
+		if line := b.moveVisible(test.line, test.n); line != test.want {
+			t.Errorf("moveVisible(%d, %d): got %d, want %d", test.line, test.n, line, test.want)
+		}
+	}
+	if line := b.nextVisible(9); line != 10 {
+		t.Errorf("nextVisible(9): got %d, want 10", line)
+	}
+	for line, want := range map[int]int{4: 1, 8: 7, 6: 6} {
+		if header := b.foldHeader(line); header != want {
+			t.Errorf("foldHeader(%d): got %d, want %d", line, header, want)
+		}
+	}
+
+	b.openFoldsAt(4)
+	if want := []Fold{{1, 3}, {7, 8}}; !reflect.DeepEqual(b.folds.closed, want) {
+		t.Errorf("openFoldsAt(4): got %v, want %v", b.folds.closed, want)
+	}
+
+	// Removing lines 3 to 5 shortens the first fold, drops the second one and
+	// moves the last one up
+	f := foldState{closed: []Fold{{2, 4}, {4, 5}, {6, 7}}}
+	f.move(func(loc Loc) Loc { return locAfterRemove(loc, Loc{0, 3}, Loc{0, 6}) })
+	if want := []Fold{{2, 3}, {3, 4}}; !reflect.DeepEqual(f.closed, want) {
+		t.Errorf("foldState.move: got %v, want %v", f.closed, want)
+	}
+}
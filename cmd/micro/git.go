@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/zyedidia/tcell"
+)
+
+// These are the different kinds of git hunks
+const (
+	// HunkAdded represents lines which are not in HEAD
+	HunkAdded = iota
+	// HunkModified represents lines which differ from HEAD
+	HunkModified
+	// HunkDeleted represents lines which were removed since HEAD
+	HunkDeleted
+)
+
+// A GitHunk is a group of consecutive lines which differ between
+// a buffer and the version of its file in HEAD
+type GitHunk struct {
+	Kind int
+
+	// The first line of the hunk in the buffer
+	Start int
+	// The first line of the hunk in HEAD
+	OldStart int
+
+	// The lines of the hunk in HEAD and in the buffer
+	// Each line keeps its newline unless it is the last line of a file
+	// which has no newline at the end
+	Old []string
+	New []string
+}
+
+// End returns the line after the last buffer line covered by the hunk
+func (h GitHunk) End() int {
+	return h.Start + len(h.New)
+}
+
+// gitState holds the information about a buffer that is needed
+// to display the git gutter
+type gitState struct {
+	// Whether the file is tracked by git
+	tracked bool
+	// The root of the repository and the file's path relative to it
+	root, path string
+	// The contents of the file in HEAD
+	base string
+	// The hunks from the last diff
+	hunks []GitHunk
+
+	// Set when the buffer changed since the last diff
+	dirty bool
+	// Set when the contents of HEAD need to be fetched again
+	stale bool
+	// Set while a diff is running in the background
+	running bool
+}
+
+// gitCommand runs git in the given directory and returns its output
+// If git fails the error contains whatever git printed on stderr
+func gitCommand(dir string, stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// GitRepoFile returns the root of the repository containing the given file
+// and the path of the file relative to that root
+func GitRepoFile(absPath string) (string, string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", "", err
+	}
+	if p, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = p
+	}
+	out, err := gitCommand(filepath.Dir(absPath), "", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", err
+	}
+	root := strings.TrimSpace(out)
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil {
+		return "", "", err
+	}
+	return root, filepath.ToSlash(rel), nil
+}
+
+// GitHeadBlob returns the contents of the file at path in HEAD
+func GitHeadBlob(root, path string) (string, error) {
+	return gitCommand(root, "", "show", "HEAD:"+path)
+}
+
+// splitLines splits text into lines, keeping the newline at the end of each line
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// GitDiffHunks compares the text of a buffer to the text of its file in HEAD
+// and returns the hunks where they differ
+func GitDiffHunks(base, text string) []GitHunk {
+	differ := dmp.New()
+	a, b, lineArray := differ.DiffLinesToChars(base, text)
+	diffs := differ.DiffCharsToLines(differ.DiffMain(a, b, false), lineArray)
+
+	var hunks []GitHunk
+	var cur *GitHunk
+	oldLine, newLine := 0, 0
+	for _, d := range diffs {
+		lines := splitLines(d.Text)
+		if d.Type == dmp.DiffEqual {
+			if cur != nil {
+				hunks = append(hunks, *cur)
+				cur = nil
+			}
+			oldLine += len(lines)
+			newLine += len(lines)
+			continue
+		}
+		if cur == nil {
+			cur = &GitHunk{Start: newLine, OldStart: oldLine}
+		}
+		if d.Type == dmp.DiffDelete {
+			cur.Old = append(cur.Old, lines...)
+			oldLine += len(lines)
+		} else {
+			cur.New = append(cur.New, lines...)
+			newLine += len(lines)
+		}
+	}
+	if cur != nil {
+		hunks = append(hunks, *cur)
+	}
+
+	for i := range hunks {
+		h := &hunks[i]
+		if len(h.Old) == 0 {
+			h.Kind = HunkAdded
+		} else if len(h.New) == 0 {
+			h.Kind = HunkDeleted
+		} else {
+			h.Kind = HunkModified
+		}
+	}
+	return hunks
+}
+
+// hunkRange returns the range of a hunk in the format used by unified diff headers
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// GitHunkPatch returns a patch which applies only the given hunk to the file in HEAD
+// The patch has no context lines so it must be applied with --unidiff-zero
+func GitHunkPatch(path string, h GitHunk) string {
+	var patch bytes.Buffer
+	fmt.Fprintf(&patch, "diff --git a/%s b/%s\n", path, path)
+	fmt.Fprintf(&patch, "--- a/%s\n+++ b/%s\n", path, path)
+	fmt.Fprintf(&patch, "@@ -%s +%s @@\n", hunkRange(h.OldStart, len(h.Old)), hunkRange(h.OldStart, len(h.New)))
+	writeLines := func(prefix string, lines []string) {
+		for _, l := range lines {
+			patch.WriteString(prefix + l)
+			if !strings.HasSuffix(l, "\n") {
+				patch.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	writeLines("-", h.Old)
+	writeLines("+", h.New)
+	return patch.String()
+}
+
+// GitStageHunk adds the given hunk of the file at path to the index
+// The hunk is moved by the lines the hunks staged above it added or removed,
+// since the patch has no context to find its place in the index
+func GitStageHunk(root, path string, h GitHunk) error {
+	h.OldStart += indexLineShift(root, path, h.OldStart)
+	_, err := gitCommand(root, GitHunkPatch(path, h), "apply", "--cached", "--unidiff-zero", "-")
+	return err
+}
+
+// indexLineShift returns the number of lines the changes staged in the index
+// add above a line of the file in HEAD, which is negative if they remove lines
+func indexLineShift(root, path string, line int) int {
+	head, err := GitHeadBlob(root, path)
+	if err != nil {
+		return 0
+	}
+	index, err := gitCommand(root, "", "show", ":"+path)
+	if err != nil {
+		return 0
+	}
+	shift := 0
+	for _, h := range GitDiffHunks(head, index) {
+		if h.OldStart+len(h.Old) <= line {
+			shift += len(h.New) - len(h.Old)
+		}
+	}
+	return shift
+}
+
+// UpdateGitDiff starts diffing the buffer against HEAD in the background
+// if the buffer changed since the last diff
+// The result is handed back to the main loop through the jobs channel
+func (b *Buffer) UpdateGitDiff() {
+	if !b.Settings["gitgutter"].(bool) || b.Path == "" || !b.git.dirty || b.git.running {
+		return
+	}
+	b.git.dirty = false
+	b.git.running = true
+
+	stale := b.git.stale
+	b.git.stale = false
+	root, path, base := b.git.root, b.git.path, b.git.base
+	absPath, text := b.AbsPath, b.String()
+	go func() {
+		tracked := true
+		if stale {
+			var err error
+			root, path, err = GitRepoFile(absPath)
+			if err == nil {
+				base, err = GitHeadBlob(root, path)
+			}
+			tracked = err == nil
+		} else if root == "" {
+			tracked = false
+		}
+		var hunks []GitHunk
+		if tracked {
+			hunks = GitDiffHunks(base, text)
+		}
+		jobs <- JobFunction{func(string, ...string) {
+			b.git.running = false
+			b.git.tracked = tracked
+			b.git.root, b.git.path, b.git.base = root, path, base
+			b.git.hunks = hunks
+		}, "", nil}
+	}()
+}
+
+// RefreshGitBase marks the contents of HEAD as outdated so they are fetched
+// again on the next diff
+func (b *Buffer) RefreshGitBase() {
+	b.git.stale = true
+	b.git.dirty = true
+}
+
+// GitHunkAt returns the index of the hunk which covers the given line, or -1
+// Deletion markers are drawn on the line after the deleted lines
+func (b *Buffer) GitHunkAt(line int) int {
+	for i, h := range b.git.hunks {
+		if h.Kind == HunkDeleted {
+			if h.Start == line || h.Start >= b.NumLines && line == b.NumLines-1 {
+				return i
+			}
+		} else if line >= h.Start && line < h.End() {
+			return i
+		}
+	}
+	return -1
+}
+
+// gitKindStyle returns the style of the markers of a kind of hunk
+func gitKindStyle(kind int) tcell.Style {
+	group, color := "diff-deleted", tcell.ColorRed
+	switch kind {
+	case HunkAdded:
+		group, color = "diff-added", tcell.ColorGreen
+	case HunkModified:
+		group, color = "diff-modified", tcell.ColorYellow
+	}
+	if style, ok := colorscheme[group]; ok {
+		return style
+	}
+	return defStyle.Foreground(color)
+}
+
+// gitGutterCell returns the rune and style used to draw the git marker on a line
+func (v *View) gitGutterCell(line int) (rune, tcell.Style) {
+	i := v.Buf.GitHunkAt(line)
+	if i < 0 {
+		return ' ', defStyle
+	}
+	kind := v.Buf.git.hunks[i].Kind
+	switch kind {
+	case HunkAdded:
+		return '+', gitKindStyle(kind)
+	case HunkModified:
+		return '~', gitKindStyle(kind)
+	default:
+		return '_', gitKindStyle(kind)
+	}
+}
+
+// hasGitGutter returns whether the git gutter column should be displayed
+func (v *View) hasGitGutter() bool {
+	return v.Buf.Settings["gitgutter"].(bool) && v.Buf.git.tracked
+}
+
+// currentHunk returns the hunk under the cursor, displaying an error if there is none
+func (v *View) currentHunk() (GitHunk, bool) {
+	if !v.Buf.git.tracked {
+		messenger.Error("This file is not tracked by git")
+		return GitHunk{}, false
+	}
+	i := v.Buf.GitHunkAt(v.Cursor.Y)
+	if i < 0 {
+		messenger.Error("No git hunk on this line")
+		return GitHunk{}, false
+	}
+	return v.Buf.git.hunks[i], true
+}
+
+// hunkStarts returns the first line of each hunk in the view
+// In diff mode these are the differences with the other side, otherwise the git hunks
+func (v *View) hunkStarts() []int {
+	if v.diff != nil {
+		return v.diff.hunkStarts(v)
+	}
+	var starts []int
+	for _, h := range v.Buf.git.hunks {
+		starts = append(starts, h.Start)
+	}
+	return starts
+}
+
+// jumpToHunk moves the cursor to the start of the hunk with the given index
+func (v *View) jumpToHunk(starts []int, i int) {
+	y := starts[i]
+	if y >= v.Buf.NumLines {
+		y = v.Buf.NumLines - 1
+	}
+	v.Cursor.ResetSelection()
+	v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, y, 0
+	messenger.Message(fmt.Sprintf("Hunk %d of %d", i+1, len(starts)))
+}
+
+// NextHunk moves the cursor to the next hunk
+func (v *View) NextHunk(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("NextHunk", v) {
+		return false
+	}
+
+	starts := v.hunkStarts()
+	if len(starts) == 0 {
+		messenger.Message("No hunks")
+		return false
+	}
+	next := 0
+	for i, start := range starts {
+		if start > v.Cursor.Y {
+			next = i
+			break
+		}
+	}
+	v.jumpToHunk(starts, next)
+
+	if usePlugin {
+		return PostActionCall("NextHunk", v)
+	}
+	return true
+}
+
+// PreviousHunk moves the cursor to the previous hunk
+func (v *View) PreviousHunk(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("PreviousHunk", v) {
+		return false
+	}
+
+	starts := v.hunkStarts()
+	if len(starts) == 0 {
+		messenger.Message("No hunks")
+		return false
+	}
+	prev := len(starts) - 1
+	for i := len(starts) - 1; i >= 0; i-- {
+		if starts[i] < v.Cursor.Y {
+			prev = i
+			break
+		}
+	}
+	v.jumpToHunk(starts, prev)
+
+	if usePlugin {
+		return PostActionCall("PreviousHunk", v)
+	}
+	return true
+}
+
+// PreviewHunk opens the text in HEAD of the hunk under the cursor in a split
+func (v *View) PreviewHunk(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("PreviewHunk", v) {
+		return false
+	}
+
+	h, ok := v.currentHunk()
+	if !ok {
+		return false
+	}
+	if len(h.Old) == 0 {
+		messenger.Message("These lines are not in HEAD")
+		return false
+	}
+	name := fmt.Sprintf("HEAD:%s (%d)", v.Buf.git.path, h.OldStart+1)
+	v.HSplit(NewScratchBuffer(strings.Join(h.Old, ""), name, v.Buf.FileType()))
+	CurView().Type = vtScratch
+
+	if usePlugin {
+		return PostActionCall("PreviewHunk", v)
+	}
+	return true
+}
+
+// RevertHunk replaces the hunk under the cursor with its text in HEAD
+func (v *View) RevertHunk(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("RevertHunk", v) {
+		return false
+	}
+
+	h, ok := v.currentHunk()
+	if !ok {
+		return false
+	}
+	v.Cursor.ResetSelection()
+	replaceLines(v.Buf, h.Start, len(h.New), strings.Join(h.Old, ""))
+	v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, h.Start, 0
+	v.Cursor.Relocate()
+	messenger.Message("Reverted hunk")
+
+	if usePlugin {
+		return PostActionCall("RevertHunk", v)
+	}
+	return true
+}
+
+// StageHunk adds the hunk under the cursor to the git index
+func (v *View) StageHunk(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("StageHunk", v) {
+		return false
+	}
+
+	h, ok := v.currentHunk()
+	if !ok {
+		return false
+	}
+	if err := GitStageHunk(v.Buf.git.root, v.Buf.git.path, h); err != nil {
+		messenger.Error("Could not stage hunk: ", err.Error())
+		return false
+	}
+	messenger.Message("Staged hunk")
+
+	if usePlugin {
+		return PostActionCall("StageHunk", v)
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newTestRepo creates a temporary git repository with a single committed file
+func newTestRepo(t *testing.T, name, content string) (string, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "microgit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", name},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if _, err := gitCommand(dir, "", args...); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	return dir, cleanup
+}
+
+func TestGitDiffHunks(t *testing.T) {
+	var tests = []struct {
+		base, text string
+		want       []GitHunk
+	}{
+		{"a\nb\n", "a\nb\n", nil},
+		{"a\nb\n", "a\nx\nb\n", []GitHunk{{HunkAdded, 1, 1, nil, []string{"x\n"}}}},
+		{"a\nb\nc\n", "a\nc\n", []GitHunk{{HunkDeleted, 1, 1, []string{"b\n"}, nil}}},
+		{"a\nb\nc\n", "a\nB\nc\n", []GitHunk{{HunkModified, 1, 1, []string{"b\n"}, []string{"B\n"}}}},
+		{"a\nb\n", "a\nb", []GitHunk{{HunkModified, 1, 1, []string{"b\n"}, []string{"b"}}}},
+		{"a\nb\nc\nd\n", "x\nb\nc\n", []GitHunk{
+			{HunkModified, 0, 0, []string{"a\n"}, []string{"x\n"}},
+			{HunkDeleted, 3, 3, []string{"d\n"}, nil},
+		}},
+	}
+	for _, test := range tests {
+		if got := GitDiffHunks(test.base, test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("GitDiffHunks(%q, %q) = %v, want %v", test.base, test.text, got, test.want)
+		}
+	}
+}
+
+func TestGitRepoFile(t *testing.T) {
+	dir, cleanup := newTestRepo(t, "file.txt", "a\n")
+	defer cleanup()
+
+	root, path, err := GitRepoFile(filepath.Join(dir, "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if real, _ := filepath.EvalSymlinks(dir); root != real {
+		t.Errorf("GitRepoFile root = %s, want %s", root, real)
+	}
+	if path != "file.txt" {
+		t.Errorf("GitRepoFile path = %s, want file.txt", path)
+	}
+	if base, err := GitHeadBlob(root, path); err != nil || base != "a\n" {
+		t.Errorf("GitHeadBlob = %q, %v", base, err)
+	}
+}
+
+func TestGitStageHunk(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\n"
+	dir, cleanup := newTestRepo(t, "file.txt", base)
+	defer cleanup()
+
+	hunks := GitDiffHunks(base, "zero\none\nTWO\nthree\n")
+	if len(hunks) != 3 {
+		t.Fatalf("expected 3 hunks, got %v", hunks)
+	}
+
+	// Stage only the modification in the middle of the file
+	if err := GitStageHunk(dir, "file.txt", hunks[1]); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := gitCommand(dir, "", "show", ":file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "one\nTWO\nthree\nfour\n"; staged != want {
+		t.Errorf("staged file = %q, want %q", staged, want)
+	}
+
+	// The deletion at the end of the file still applies on top of it
+	if err := GitStageHunk(dir, "file.txt", hunks[2]); err != nil {
+		t.Fatal(err)
+	}
+	staged, _ = gitCommand(dir, "", "show", ":file.txt")
+	if want := "one\nTWO\nthree\n"; staged != want {
+		t.Errorf("staged file = %q, want %q", staged, want)
+	}
+
+	// Hunks below a staged insertion are moved down by its lines
+	base = "a\nb\nc\n"
+	dir2, cleanup2 := newTestRepo(t, "file.txt", base)
+	defer cleanup2()
+	hunks = GitDiffHunks(base, "x\na\nb\ny\nc\n")
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %v", hunks)
+	}
+	for i, want := range []string{"x\na\nb\nc\n", "x\na\nb\ny\nc\n"} {
+		if err := GitStageHunk(dir2, "file.txt", hunks[i]); err != nil {
+			t.Fatal(err)
+		}
+		if staged, _ = gitCommand(dir2, "", "show", ":file.txt"); staged != want {
+			t.Errorf("staged file after hunk %d = %q, want %q", i, staged, want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goGraph holds what the type checker found out about the Go packages of the
+// workspace: the calls between functions and the relations between types
+type goGraph struct {
+	calls *callGraph
+	types *typeGraph
+}
+
+// A graphRef is a range of a file which names or declares a function or a type
+type graphRef struct {
+	start, end int
+	// The key of the function or type in its graph
+	key string
+	// Whether the range is the whole declaration rather than a name
+	decl bool
+}
+
+// refAt returns the key named at a byte offset, or else the key whose
+// declaration contains it
+func refAt(refs []graphRef, offset int) string {
+	key := ""
+	for _, ref := range refs {
+		if offset < ref.start || offset > ref.end {
+			continue
+		}
+		if !ref.decl {
+			return ref.key
+		}
+		key = ref.key
+	}
+	return key
+}
+
+// modulePath returns the module path declared in the go.mod file of a directory
+func modulePath(dir string) string {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// workspaceImportPath returns the import path of a package of the workspace
+func workspaceImportPath(root, module, dir string, pkg *build.Package) string {
+	if module == "" {
+		if pkg.ImportPath != "" && pkg.ImportPath != "." {
+			return pkg.ImportPath
+		}
+		module = filepath.Base(root)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return module
+	}
+	return module + "/" + filepath.ToSlash(rel)
+}
+
+// workspaceImporter type checks the packages of the workspace from source,
+// using the text of the open files, and imports the others with the source
+// importer of go/importer
+// A package of the workspace imported by another one is the package which was
+// checked for itself, so that its types are identical in both
+type workspaceImporter struct {
+	fset  *token.FileSet
+	src   types.ImporterFrom
+	texts map[string]string
+	// The directory and the files of each package of the workspace
+	dirs  map[string]string
+	files map[string][]string
+	pkgs  map[string]*types.Package
+	// Called with each package once it is checked
+	visit func(pkg *types.Package, files []*ast.File, info *types.Info)
+}
+
+// Import imports a package from its import path
+func (w *workspaceImporter) Import(path string) (*types.Package, error) {
+	return w.ImportFrom(path, "", 0)
+}
+
+// ImportFrom imports a package, checking it first if it is in the workspace
+func (w *workspaceImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if _, ok := w.dirs[path]; ok {
+		return w.check(path)
+	}
+	return w.src.ImportFrom(path, dir, mode)
+}
+
+// check parses and type checks a package of the workspace, once
+func (w *workspaceImporter) check(importPath string) (*types.Package, error) {
+	if pkg, ok := w.pkgs[importPath]; ok {
+		if pkg == nil {
+			return nil, errors.New("import cycle through " + importPath)
+		}
+		return pkg, nil
+	}
+	w.pkgs[importPath] = nil
+	var files []*ast.File
+	for _, name := range w.files[importPath] {
+		path := filepath.Join(w.dirs[importPath], name)
+		var src interface{}
+		if text, ok := w.texts[path]; ok {
+			src = text
+		}
+		if f, _ := parser.ParseFile(w.fset, path, src, 0); f != nil {
+			files = append(files, f)
+		}
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object), Uses: make(map[*ast.Ident]types.Object)}
+	conf := types.Config{Importer: w, Error: func(error) {}, FakeImportC: true}
+	pkg, _ := conf.Check(strings.TrimSuffix(importPath, " [test]"), w.fset, files, info)
+	w.pkgs[importPath] = pkg
+	if len(files) > 0 {
+		w.visit(pkg, files, info)
+	}
+	return pkg, nil
+}
+
+// checkWorkspace type checks the Go packages under root, with their tests,
+// and calls visit with each of them
+// Files which are open in the editor are read from texts, which is keyed by
+// absolute path; the imports of the packages are type checked from source
+func checkWorkspace(root string, texts map[string]string, fset *token.FileSet, visit func(pkg *types.Package, files []*ast.File, info *types.Info)) {
+	src, _ := importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)
+	w := &workspaceImporter{
+		fset:  fset,
+		src:   src,
+		texts: texts,
+		dirs:  make(map[string]string),
+		files: make(map[string][]string),
+		pkgs:  make(map[string]*types.Package),
+		visit: visit,
+	}
+	module := modulePath(root)
+
+	filepath.Walk(root, func(dir string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return nil
+		}
+		if dir != root && skipIndexDir(fi.Name()) {
+			return filepath.SkipDir
+		}
+		pkg, err := build.ImportDir(dir, 0)
+		if err != nil {
+			return nil
+		}
+		importPath := workspaceImportPath(root, module, dir, pkg)
+		w.dirs[importPath] = dir
+		w.files[importPath] = append(append([]string{}, pkg.GoFiles...), pkg.TestGoFiles...)
+		if len(pkg.XTestGoFiles) > 0 {
+			// The external tests can't be imported, so their key isn't an
+			// import path
+			w.dirs[importPath+"_test [test]"] = dir
+			w.files[importPath+"_test [test]"] = pkg.XTestGoFiles
+		}
+		return nil
+	})
+
+	paths := make([]string, 0, len(w.dirs))
+	for path := range w.dirs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		w.check(path)
+	}
+}
+
+// buildGoGraph type checks the Go packages of the workspace and returns their
+// call graph and type graph
+func buildGoGraph(root string, texts map[string]string) *goGraph {
+	g := &goGraph{calls: newCallGraph(), types: newTypeGraph()}
+	fset := token.NewFileSet()
+	checkWorkspace(root, texts, fset, func(pkg *types.Package, files []*ast.File, info *types.Info) {
+		g.calls.addPackage(fset, files, info)
+		g.types.addPackage(fset, pkg, files, info)
+	})
+	g.types.finish()
+	return g
+}
+
+// goGraphs builds the graph of the workspace in the background and keeps it
+// until a Go file is saved
+var goGraphs struct {
+	graph    *goGraph
+	building bool
+	stale    bool
+	// Called with the graph when it is built
+	waiting []func(g *goGraph)
+}
+
+// loadGoGraph calls then with the graph of the workspace, once it is built if
+// it isn't up to date
+func loadGoGraph(then func(g *goGraph)) {
+	if goGraphs.graph != nil && !goGraphs.stale {
+		then(goGraphs.graph)
+		return
+	}
+	goGraphs.waiting = append(goGraphs.waiting, then)
+	if goGraphs.building {
+		return
+	}
+	goGraphs.building = true
+	goGraphs.stale = false
+	// The text of the open files is taken on the main goroutine
+	texts := make(map[string]string)
+	for _, t := range tabs {
+		for _, view := range t.views {
+			if view.Buf.FileType() == "go" && view.Buf.IsModified {
+				texts[view.Buf.AbsPath] = view.Buf.String()
+			}
+		}
+	}
+	go func() {
+		g := buildGoGraph(workspaceRoot(), texts)
+		jobs <- JobFunction{func(string, ...string) {
+			goGraphs.graph, goGraphs.building = g, false
+			waiting := goGraphs.waiting
+			goGraphs.waiting = nil
+			if goGraphs.stale {
+				// A file was saved while the graph was built
+				loadGoGraph(refreshGoTrees)
+			}
+			for _, f := range waiting {
+				f(g)
+			}
+		}, "", nil}
+	}()
+}
+
+// goGraphSaved marks the graph out of date after a Go file was saved, and
+// rebuilds it in the background if a call or type hierarchy is open
+func goGraphSaved() {
+	if goGraphs.graph == nil && !goGraphs.building {
+		return
+	}
+	goGraphs.stale = true
+	if goGraphs.building {
+		return
+	}
+	for _, t := range tabs {
+		for _, view := range t.views {
+			if view.callTree != nil || view.typeTree != nil {
+				loadGoGraph(refreshGoTrees)
+				return
+			}
+		}
+	}
+}
+
+// refreshGoTrees renders the open call and type hierarchy views again from a
+// new graph
+func refreshGoTrees(g *goGraph) {
+	for _, t := range tabs {
+		for _, view := range t.views {
+			if view.callTree != nil {
+				view.callTree.render(view, g.calls)
+			}
+			if view.typeTree != nil {
+				view.typeTree.render(view, g.types)
+			}
+		}
+	}
+}
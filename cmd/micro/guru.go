@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"golang.org/x/tools/cmd/guru/serial"
 	"os/exec"
@@ -35,30 +36,35 @@ func getWhat(v *View) serial.What {
 
 func getDescription(v *View) serial.Describe {
 	v.Cursor.Relocate()
-	offset := ByteOffset(v.Cursor.Loc, v.Buf)
+	desc, err := guruDescribe(v.Buf.Path, v.Buf.GetName(), v.Buf.String(), ByteOffset(v.Cursor.Loc, v.Buf))
+	if err != nil {
+		messenger.Message(err.Error())
+	}
+	return desc
+}
+
+// guruDescribe runs guru describe at a byte offset of the text of a file
+// It doesn't use the view so it can be called in the background
+func guruDescribe(path, name, text string, offset int) (serial.Describe, error) {
 	_, err := exec.LookPath("guru")
 	if err != nil {
 		_, _ = exec.Command("go", "get", "-u", "golang.org/x/tools/cmd/...").CombinedOutput()
 	}
-	cmd := exec.Command("guru", "-modified", "-json", "describe", fmt.Sprintf("%s:#%d", v.Buf.Path, offset))
+	cmd := exec.Command("guru", "-modified", "-json", "describe", fmt.Sprintf("%s:#%d", path, offset))
 	in, _ := cmd.StdinPipe()
-	fmt.Fprint(in, v.Buf.GetName()+"\n")
-	fmt.Fprintf(in, "%d\n", len(v.Buf.String()))
-	fmt.Fprint(in, v.Buf.String())
+	fmt.Fprint(in, name+"\n")
+	fmt.Fprintf(in, "%d\n", len(text))
+	fmt.Fprint(in, text)
 	in.Close()
 	data, err := cmd.CombinedOutput()
-	if err != nil {
-		messenger.Message(fmt.Sprintf("%s %s", data, err))
-		return serial.Describe{}
-	}
 	desc := serial.Describe{}
-	err = json.Unmarshal(data, &desc)
 	if err != nil {
-		messenger.Message(string(data))
+		return desc, fmt.Errorf("%s %s", data, err)
 	}
-
-	err = json.Unmarshal(data, &desc)
-	return desc
+	if err = json.Unmarshal(data, &desc); err != nil {
+		return desc, errors.New(string(data))
+	}
+	return desc, nil
 }
 
 func getImplements(v *View) serial.Implements {
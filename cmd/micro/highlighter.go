@@ -26,6 +26,13 @@ type SyntaxRule struct {
 	style tcell.Style
 }
 
+// SymbolRule is a regex which finds the declarations of one kind for the outline
+type SymbolRule struct {
+	// What is declared, such as function or class
+	kind  string
+	regex *regexp.Regexp
+}
+
 var syntaxKeys [][2]*regexp.Regexp
 var syntaxFiles map[[2]*regexp.Regexp]FileTypeRules
 
@@ -150,7 +157,8 @@ func LoadRulesFromFile(text, filename string) []SyntaxRule {
 		if strings.TrimSpace(line) == "" ||
 			strings.TrimSpace(line)[0] == '#' ||
 			strings.HasPrefix(line, "syntax") ||
-			strings.HasPrefix(line, "header") {
+			strings.HasPrefix(line, "header") ||
+			strings.HasPrefix(line, "symbol") {
 			// Ignore this line
 			continue
 		}
@@ -287,6 +295,43 @@ func GetRules(buf *Buffer) []SyntaxRule {
 	return nil
 }
 
+// LoadSymbolRulesFromFile loads the symbol statements of a syntax file
+// Example: symbol function "^\s*def\s+(\w+)"
+// The first group of the regex is the name of the symbol, or the whole match
+// if there is no group
+func LoadSymbolRulesFromFile(text, filename string) []SymbolRule {
+	symbolParser := regexp.MustCompile(`^symbol\s+(\S+)\s+"(.*)"`)
+
+	var rules []SymbolRule
+	for lineNum, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(line, "symbol") {
+			continue
+		}
+		submatch := symbolParser.FindStringSubmatch(line)
+		if submatch == nil {
+			TermError(filename, lineNum, "Symbol statement is not valid: "+line)
+			continue
+		}
+		regex, err := regexp.Compile(submatch[2])
+		if err != nil {
+			TermError(filename, lineNum, err.Error())
+			continue
+		}
+		rules = append(rules, SymbolRule{submatch[1], regex})
+	}
+	return rules
+}
+
+// GetSymbolRules finds the symbol rules of the buffer's filetype
+func GetSymbolRules(buf *Buffer) []SymbolRule {
+	for _, r := range syntaxKeys {
+		if syntaxFiles[r].filetype == buf.FileType() {
+			return LoadSymbolRulesFromFile(syntaxFiles[r].text, syntaxFiles[r].filename)
+		}
+	}
+	return nil
+}
+
 // SyntaxMatches is an alias to a map from character numbers to styles,
 // so map[3] represents the style of the third character
 type SyntaxMatches [][]tcell.Style
@@ -298,7 +343,8 @@ func Match(v *View) SyntaxMatches {
 	rules := v.Buf.rules
 
 	viewStart := v.Topline
-	viewEnd := v.Topline + v.Height
+	// The lines hidden by folds are matched too
+	viewEnd := v.lineAtRow(v.Height)
 	if viewEnd > buf.NumLines {
 		viewEnd = buf.NumLines
 	}
@@ -315,7 +361,7 @@ func Match(v *View) SyntaxMatches {
 
 	// We don't actually check the entire buffer, just from synLinesUp to synLinesDown
 	totalStart := v.Topline - synLinesUp
-	totalEnd := v.Topline + v.Height + synLinesDown
+	totalEnd := viewEnd + synLinesDown
 	if totalStart < 0 {
 		totalStart = 0
 	}
@@ -343,7 +389,7 @@ func Match(v *View) SyntaxMatches {
 							continue
 						}
 						lineNum -= viewStart
-						if lineNum >= 0 && lineNum < v.Height {
+						if lineNum >= 0 && lineNum < len(matches) {
 							matches[lineNum][colNum] = rule.style
 						}
 					}
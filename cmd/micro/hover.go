@@ -0,0 +1,608 @@
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/zyedidia/tcell"
+	"golang.org/x/tools/cmd/guru/serial"
+)
+
+// The largest size of the hover box, borders included
+const (
+	maxHoverWidth  = 80
+	maxHoverHeight = 12
+)
+
+// The kinds of lines in the hover box
+const (
+	docText = iota
+	docCode
+	docHeading
+	docSignature
+	docLocation
+)
+
+// A docLine is a line of documentation rendered for the hover box
+type docLine struct {
+	text string
+	kind int
+}
+
+// hoverInfo is what the hover box shows about an identifier
+type hoverInfo struct {
+	signature string
+	doc       string
+	// The place of the declaration, as path:line
+	location string
+}
+
+// HoverBox shows the signature, the documentation and the declaration of an
+// identifier in a scrollable popup next to it
+type HoverBox struct {
+	open bool
+	// Whether the box was opened by hovering with the mouse
+	mouse bool
+	lines []docLine
+	// The first line shown
+	scroll int
+	width  int
+	// The screen cell the box is shown next to
+	x, y int
+	// Where the box was last drawn, used for mouse events
+	top, left, height int
+}
+
+// Open opens the hover box next to the screen cell x, y
+func (h *HoverBox) Open(info hoverInfo, x, y int, mouse bool) {
+	w, _ := screen.Size()
+	wrap := Min(maxHoverWidth, w) - 2
+
+	var lines []docLine
+	for _, line := range strings.Split(info.signature, "\n") {
+		lines = append(lines, docLine{strings.Replace(line, "\t", "    ", -1), docSignature})
+	}
+	if doc := renderDoc(info.doc, wrap); len(doc) > 0 {
+		lines = append(lines, docLine{"", docText})
+		lines = append(lines, doc...)
+	}
+	if info.location != "" {
+		lines = append(lines, docLine{"", docText}, docLine{info.location, docLocation})
+	}
+
+	h.width = 0
+	for _, line := range lines {
+		h.width = Max(h.width, runewidth.StringWidth(line.text)+2)
+	}
+	h.width = Min(h.width, wrap+2)
+	h.lines, h.scroll, h.x, h.y, h.mouse = lines, 0, x, y, mouse
+	h.open = true
+}
+
+// Reset closes the hover box
+func (h *HoverBox) Reset() {
+	h.open = false
+	h.mouse = false
+	h.lines = nil
+	h.scroll = 0
+}
+
+// style returns the style of a kind of line
+// The box uses the hover group of the colorscheme, or reversed colors
+func (h *HoverBox) style(kind int) tcell.Style {
+	style := defStyle.Reverse(true)
+	if s, ok := colorscheme["hover"]; ok {
+		style = s
+	}
+	switch kind {
+	case docSignature:
+		return style.Bold(true)
+	case docHeading:
+		return style.Bold(true).Underline(true)
+	case docLocation:
+		return style.Underline(true)
+	}
+	return style
+}
+
+// Display draws the hover box below the cell it belongs to, or above it if
+// there is no room below
+func (h *HoverBox) Display() {
+	if !h.open {
+		return
+	}
+	w, sh := screen.Size()
+	// The last line of the screen is the infobar
+	sh--
+	height := Min(len(h.lines), maxHoverHeight)
+	y := h.y + 1
+	if y+height > sh && h.y-height >= 0 {
+		y = h.y - height
+	}
+	height = Max(0, Min(height, sh-y))
+	h.scroll = Max(0, Min(h.scroll, len(h.lines)-height))
+	x := Max(0, Min(h.x, w-h.width))
+	h.top, h.left, h.height = y, x, height
+
+	for i := 0; i < height; i++ {
+		line := h.lines[h.scroll+i]
+		style := h.style(line.kind)
+		col := 1
+		screen.SetContent(x, y+i, ' ', nil, style)
+		for _, r := range line.text {
+			rw := runewidth.RuneWidth(r)
+			if col+rw > h.width-1 {
+				break
+			}
+			screen.SetContent(x+col, y+i, r, nil, style)
+			col += rw
+		}
+		for ; col < h.width; col++ {
+			screen.SetContent(x+col, y+i, ' ', nil, style)
+		}
+	}
+	// Arrows in the right column show that the box scrolls
+	if h.scroll > 0 {
+		screen.SetContent(x+h.width-1, y, '▲', nil, h.style(docText))
+	}
+	if h.scroll+height < len(h.lines) {
+		screen.SetContent(x+h.width-1, y+height-1, '▼', nil, h.style(docText))
+	}
+}
+
+// scrollBy scrolls the hover box by a number of lines
+func (h *HoverBox) scrollBy(n int) {
+	h.scroll = Max(0, Min(h.scroll+n, len(h.lines)-h.height))
+}
+
+// contains returns whether a screen cell is inside of the box
+func (h *HoverBox) contains(x, y int) bool {
+	return x >= h.left && x < h.left+h.width && y >= h.top && y < h.top+h.height
+}
+
+// hoverActions are the actions of the hover keymap context
+var hoverActions = map[string]func(h *HoverBox){
+	"CursorUp": func(h *HoverBox) {
+		h.scrollBy(-1)
+	},
+	"CursorDown": func(h *HoverBox) {
+		h.scrollBy(1)
+	},
+	"CursorPageUp": func(h *HoverBox) {
+		h.scrollBy(-Max(1, h.height-1))
+	},
+	"CursorPageDown": func(h *HoverBox) {
+		h.scrollBy(Max(1, h.height-1))
+	},
+	"Cancel": (*HoverBox).Reset,
+}
+
+// HandleEvent scrolls the hover box with the keys of the hover context and
+// the mouse wheel, and closes it on other keys and clicks
+// It returns whether the event was used by the box
+func (h *HoverBox) HandleEvent(event tcell.Event) bool {
+	switch e := event.(type) {
+	case *tcell.EventKey:
+		if names := contextActions("hover", e); names != nil {
+			for _, name := range names {
+				if action, ok := hoverActions[name]; ok {
+					action(h)
+				}
+			}
+			return true
+		}
+		h.Reset()
+	case *tcell.EventMouse:
+		x, y := e.Position()
+		switch e.Buttons() {
+		case tcell.WheelUp, tcell.WheelDown:
+			if h.contains(x, y) {
+				if e.Buttons() == tcell.WheelUp {
+					h.scrollBy(-1)
+				} else {
+					h.scrollBy(1)
+				}
+				return true
+			}
+		case tcell.ButtonNone:
+		default:
+			if h.contains(x, y) {
+				return true
+			}
+			h.Reset()
+		}
+	case *tcell.EventResize:
+		h.Reset()
+	}
+	return false
+}
+
+var (
+	mdHeading   = regexp.MustCompile(`^#{1,6}\s+`)
+	mdListItem  = regexp.MustCompile(`^([-*+]|\d+[.)])\s+`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	mdEmphasis  = regexp.MustCompile("\\*\\*|__|`")
+	godocLinkRe = regexp.MustCompile(`\[([\pL_][\pL\pN_.*]*)\]`)
+	// One level of indentation of a code block
+	codeIndent = regexp.MustCompile(`^(\t| {1,4})`)
+)
+
+// renderDoc renders a doc comment, written in godoc or Markdown format, as
+// lines of at most width columns
+// Paragraphs and list items are wrapped, indented blocks and fenced blocks
+// are code and kept as they are, and headings are set apart
+func renderDoc(text string, width int) []docLine {
+	var lines []docLine
+	var para []string
+	indent := ""
+	blank := func() {
+		if len(lines) > 0 && lines[len(lines)-1].text != "" {
+			lines = append(lines, docLine{"", docText})
+		}
+	}
+	flush := func() {
+		if len(para) > 0 {
+			for i, l := range wrapWords(inlineMarkdown(strings.Join(para, " ")), width-len(indent)) {
+				if i > 0 {
+					l = indent + l
+				}
+				lines = append(lines, docLine{l, docText})
+			}
+		}
+		para, indent = nil, ""
+	}
+
+	src := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	fence := false
+	for i, line := range src {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			if !fence {
+				blank()
+			}
+			fence = !fence
+		case fence:
+			lines = append(lines, docLine{"  " + strings.Replace(line, "\t", "    ", -1), docCode})
+		case trimmed == "":
+			flush()
+			blank()
+		case line[0] == ' ' || line[0] == '\t':
+			flush()
+			lines = append(lines, docLine{"  " + strings.Replace(strings.TrimRight(codeIndent.ReplaceAllString(line, ""), " \t"), "\t", "    ", -1), docCode})
+		case mdHeading.MatchString(line) || isGodocHeading(src, i):
+			flush()
+			blank()
+			lines = append(lines, docLine{inlineMarkdown(mdHeading.ReplaceAllString(trimmed, "")), docHeading})
+		case mdListItem.MatchString(line):
+			flush()
+			m := mdListItem.FindString(line)
+			para = []string{strings.TrimSpace(m) + " " + line[len(m):]}
+			indent = strings.Repeat(" ", len(strings.TrimSpace(m))+1)
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	for len(lines) > 0 && lines[len(lines)-1].text == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// isGodocHeading returns whether a line of a doc comment is a heading in the
+// old godoc style: a single capitalized line without punctuation, between
+// blank lines and followed by a paragraph
+func isGodocHeading(lines []string, i int) bool {
+	line := lines[i]
+	if i == 0 || i+2 >= len(lines) || strings.TrimSpace(lines[i-1]) != "" ||
+		strings.TrimSpace(lines[i+1]) != "" || strings.TrimSpace(lines[i+2]) == "" {
+		return false
+	}
+	first, _ := utf8.DecodeRuneInString(line)
+	last, _ := utf8.DecodeLastRuneInString(line)
+	if !unicode.IsUpper(first) || !(unicode.IsLetter(last) || unicode.IsDigit(last)) {
+		return false
+	}
+	for _, r := range line {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" ,()'", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineMarkdown removes the Markdown markup inside of a line of text
+func inlineMarkdown(s string) string {
+	s = mdLink.ReplaceAllString(s, "$1 ($2)")
+	s = godocLinkRe.ReplaceAllString(s, "$1")
+	return mdEmphasis.ReplaceAllString(s, "")
+}
+
+// wrapWords splits text into lines of at most width columns at spaces
+// Words longer than a line are put on a line of their own
+func wrapWords(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && runewidth.StringWidth(line)+1+runewidth.StringWidth(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitPos splits a position printed by guru as file:line:column
+func splitPos(pos string) (file string, line, col int, ok bool) {
+	i := strings.LastIndex(pos, ":")
+	if i < 0 {
+		return
+	}
+	j := strings.LastIndex(pos[:i], ":")
+	if j < 0 {
+		return
+	}
+	line, err1 := strconv.Atoi(pos[j+1 : i])
+	col, err2 := strconv.Atoi(pos[i+1:])
+	return pos[:j], line, col, err1 == nil && err2 == nil
+}
+
+// nodeString prints a node of the syntax tree as Go source
+func nodeString(fset *token.FileSet, node interface{}) string {
+	var b strings.Builder
+	printer.Fprint(&b, fset, node)
+	return b.String()
+}
+
+// declarationDoc finds the declaration of the name at a position printed by
+// guru, and returns its signature without a body and its doc comment
+// The text of files which are modified in the editor is taken from texts,
+// which is keyed by absolute path
+func declarationDoc(pos string, texts map[string]string) (signature, doc string, ok bool) {
+	file, line, col, ok := splitPos(pos)
+	if !ok {
+		return "", "", false
+	}
+	var src interface{}
+	if abs, err := filepath.Abs(file); err == nil {
+		if text, found := texts[abs]; found {
+			src = text
+		}
+	}
+	if src == nil {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", "", false
+		}
+		src = data
+	}
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if f == nil {
+		return "", "", false
+	}
+	at := func(id *ast.Ident) bool {
+		p := fset.Position(id.Pos())
+		return p.Line == line && p.Column == col
+	}
+
+	found := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch d := n.(type) {
+		case *ast.FuncDecl:
+			if at(d.Name) {
+				fn := *d
+				fn.Body, fn.Doc = nil, nil
+				signature, doc, found = nodeString(fset, &fn), d.Doc.Text(), true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				var names []*ast.Ident
+				var specDoc, comment *ast.CommentGroup
+				var s ast.Spec
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					c := *sp
+					c.Doc, c.Comment = nil, nil
+					names, specDoc, comment, s = []*ast.Ident{sp.Name}, sp.Doc, sp.Comment, &c
+				case *ast.ValueSpec:
+					c := *sp
+					c.Doc, c.Comment = nil, nil
+					names, specDoc, comment, s = sp.Names, sp.Doc, sp.Comment, &c
+				}
+				for _, name := range names {
+					if !at(name) {
+						continue
+					}
+					signature, doc, found = d.Tok.String()+" "+nodeString(fset, s), specDoc.Text(), true
+					if doc == "" {
+						doc = comment.Text()
+					}
+					if doc == "" && len(d.Specs) == 1 {
+						doc = d.Doc.Text()
+					}
+				}
+			}
+		case *ast.Field:
+			for _, name := range d.Names {
+				if !at(name) {
+					continue
+				}
+				if fn, isFunc := d.Type.(*ast.FuncType); isFunc {
+					signature = name.Name + strings.TrimPrefix(nodeString(fset, fn), "func")
+				} else {
+					signature = name.Name + " " + nodeString(fset, d.Type)
+				}
+				doc, found = d.Doc.Text(), true
+				if doc == "" {
+					doc = d.Comment.Text()
+				}
+			}
+		}
+		return !found
+	})
+	return signature, doc, found
+}
+
+// packageDoc returns the doc comment and the directory of an imported package
+func packageDoc(path, srcDir string) (doc, dir string) {
+	pkg, err := build.Import(path, srcDir, 0)
+	if err != nil {
+		return "", ""
+	}
+	for _, name := range pkg.GoFiles {
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(pkg.Dir, name), nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err == nil && f.Doc != nil {
+			return f.Doc.Text(), pkg.Dir
+		}
+	}
+	return "", pkg.Dir
+}
+
+// describeHover builds what the hover box shows from the result of guru
+// describe, reading the declaration from the source
+func describeHover(desc serial.Describe, texts map[string]string, srcDir string) hoverInfo {
+	info := hoverInfo{signature: strings.TrimSpace(desc.Desc)}
+	pos := ""
+	switch {
+	case desc.Detail == "value" && desc.Value != nil:
+		if !strings.HasSuffix(info.signature, desc.Value.Type) {
+			info.signature += " " + desc.Value.Type
+		}
+		pos = desc.Value.ObjPos
+	case desc.Detail == "type" && desc.Type != nil:
+		info.signature = "type " + desc.Type.Type
+		pos = desc.Type.NamePos
+	case desc.Detail == "package" && desc.Package != nil:
+		info.signature = "package " + desc.Package.Path
+		var dir string
+		info.doc, dir = packageDoc(desc.Package.Path, srcDir)
+		if dir != "" {
+			info.location = workingDirPath(dir)
+		}
+		return info
+	}
+	if pos == "" {
+		return info
+	}
+	if signature, doc, ok := declarationDoc(pos, texts); ok {
+		info.signature, info.doc = signature, doc
+	}
+	if file, line, _, ok := splitPos(pos); ok {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		info.location = workingDirPath(file) + ":" + strconv.Itoa(line)
+	}
+	return info
+}
+
+// showHover describes the Go identifier at a location of the buffer in the
+// background and opens the hover box next to the screen cell x, y
+// Errors are only reported when the box wasn't asked for with the mouse
+func (v *View) showHover(loc Loc, x, y int, mouse bool) {
+	buf := v.Buf
+	path, name, text, version := buf.Path, buf.GetName(), buf.String(), buf.version
+	offset := ByteOffset(loc, buf)
+	texts := map[string]string{buf.AbsPath: text}
+	dir := filepath.Dir(buf.AbsPath)
+	go func() {
+		desc, err := guruDescribe(path, name, text, offset)
+		var info hoverInfo
+		if err == nil {
+			info = describeHover(desc, texts, dir)
+		}
+		jobs <- JobFunction{func(string, ...string) {
+			if !viewIsOpen(v) || v.Buf != buf || buf.version != version {
+				return
+			}
+			if err != nil || info.signature == "" {
+				if mouse {
+					if hover.mouse {
+						hover.Reset()
+					}
+				} else if err != nil {
+					messenger.Error(err)
+				} else {
+					messenger.Message("Nothing to describe here")
+				}
+				return
+			}
+			if !mouse {
+				// The view may have scrolled to the cursor since it was asked for
+				x, y = cursorGX, cursorGY
+			}
+			hover.Open(info, x, y, mouse)
+		}, "", nil}
+	}()
+}
+
+// mouseLoc returns the location of the buffer under a screen cell, and
+// whether there is a word character there
+func (v *View) mouseLoc(sx, sy int) (Loc, bool) {
+	if sx < v.x+v.lineNumOffset || sx >= v.x+v.Width || sy < v.y || sy >= v.y+v.Height {
+		return Loc{}, false
+	}
+	x := sx - v.lineNumOffset + v.leftCol - v.x
+	y := sy - v.y + v.Topline
+	if v.diff != nil {
+		y = v.diff.lineAtScreenRow(v, sy-v.y)
+	}
+	if y < 0 || y >= v.Buf.NumLines {
+		return Loc{}, false
+	}
+	x, y = v.GetSoftWrapLocation(x, y)
+	line := []rune(v.Buf.Line(y))
+	if x >= len(line) || !IsWordChar(string(line[x])) {
+		return Loc{}, false
+	}
+	return Loc{x, y}, true
+}
+
+// mouseHover opens the hover box for the identifier under the mouse once it
+// stayed still for the number of milliseconds of the hoverdelay option
+func (v *View) mouseHover(sx, sy int) {
+	v.hoverMoves++
+	delay := globalSettings["hoverdelay"].(float64)
+	if delay <= 0 || v.Type != vtDefault || v.Buf.FileType() != "go" {
+		return
+	}
+	moves := v.hoverMoves
+	time.AfterFunc(time.Duration(delay)*time.Millisecond, func() {
+		jobs <- JobFunction{func(string, ...string) {
+			if moves != v.hoverMoves || CurView() != v || hover.open && !hover.mouse ||
+				hover.open && hover.contains(sx, sy) {
+				return
+			}
+			if loc, ok := v.mouseLoc(sx, sy); ok {
+				v.showHover(loc, sx, sy, true)
+			} else if hover.mouse {
+				hover.Reset()
+			}
+		}, "", nil}
+	})
+}
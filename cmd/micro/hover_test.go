@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRenderDoc(t *testing.T) {
+	tests := []struct {
+		doc   string
+		width int
+		want  []docLine
+	}{
+		{
+			"Open opens the file and\nreturns it.\n", 80,
+			[]docLine{{"Open opens the file and returns it.", docText}},
+		},
+		{
+			"Parse a file.\n\nExample\n\nUse it like this:\n\n\tf := Parse(src)\n", 80,
+			[]docLine{
+				{"Parse a file.", docText},
+				{"", docText},
+				{"Example", docHeading},
+				{"", docText},
+				{"Use it like this:", docText},
+				{"", docText},
+				{"  f := Parse(src)", docCode},
+			},
+		},
+		{
+			"# Usage\nCall **Run** with `args`, see [docs](http://x).\n```\nRun()\n```\n", 80,
+			[]docLine{
+				{"Usage", docHeading},
+				{"Call Run with args, see docs (http://x).", docText},
+				{"", docText},
+				{"  Run()", docCode},
+			},
+		},
+		{
+			"- first item which is long\n- second\n", 14,
+			[]docLine{
+				{"- first item", docText},
+				{"  which is", docText},
+				{"  long", docText},
+				{"- second", docText},
+			},
+		},
+	}
+	for i, test := range tests {
+		if got := renderDoc(test.doc, test.width); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("renderDoc %d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestDeclarationDoc(t *testing.T) {
+	src := `package p
+
+// Greet returns a greeting.
+func Greet(name string) string {
+	return "hi " + name
+}
+
+// Limits of the greeting
+const (
+	// MaxLen is the longest name
+	MaxLen = 10
+)
+
+// A Person has a name.
+type Person struct {
+	// Name is the first name
+	Name string
+	Age  int // in years
+}
+`
+	dir, _ := ioutil.TempDir("", "hover")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "p.go")
+	ioutil.WriteFile(path, []byte("package p\n"), 0644)
+	texts := map[string]string{path: src}
+
+	tests := []struct {
+		pos, signature, doc string
+	}{
+		{path + ":4:6", "func Greet(name string) string", "Greet returns a greeting.\n"},
+		{path + ":11:2", "const MaxLen = 10", "MaxLen is the longest name\n"},
+		{path + ":15:6", "type Person struct {\n\t// Name is the first name\n\tName\tstring\n\tAge\tint\t// in years\n}", "A Person has a name.\n"},
+		{path + ":17:2", "Name string", "Name is the first name\n"},
+		{path + ":18:2", "Age int", "in years\n"},
+	}
+	for _, test := range tests {
+		signature, doc, ok := declarationDoc(test.pos, texts)
+		if !ok || signature != test.signature || doc != test.doc {
+			t.Errorf("declarationDoc %s: got %q %q %v, want %q %q", test.pos, signature, doc, ok, test.signature, test.doc)
+		}
+	}
+	if _, _, ok := declarationDoc(path+":5:2", texts); ok {
+		t.Errorf("declarationDoc found a declaration in a function body")
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The number of locations kept in the jump list of a view and in the change
+// list of a buffer
+const (
+	maxJumps   = 100
+	maxChanges = 100
+)
+
+// A Mark is a location in a file which moves with the edits made to the
+// buffer of the file while it is open
+type Mark struct {
+	// The absolute path of the file, empty for a buffer without a file
+	Path string
+	Loc  Loc
+}
+
+// The marks which follow the edits of each file, by absolute path
+var fileMarks = make(map[string]map[*Mark]bool)
+
+// NewMark returns a mark at a location of a file
+func NewMark(path string, loc Loc) *Mark {
+	m := &Mark{Path: path, Loc: loc}
+	if path != "" {
+		if fileMarks[path] == nil {
+			fileMarks[path] = make(map[*Mark]bool)
+		}
+		fileMarks[path][m] = true
+	}
+	return m
+}
+
+// Delete stops the mark from following the edits of its file
+func (m *Mark) Delete() {
+	delete(fileMarks[m.Path], m)
+	if len(fileMarks[m.Path]) == 0 {
+		delete(fileMarks, m.Path)
+	}
+}
+
+// renameMarks points the marks of a file or of the files in a directory to
+// their new path after a rename
+func renameMarks(from, to string) {
+	var paths []string
+	for path := range fileMarks {
+		if inPath(path, from) {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range paths {
+		marks := fileMarks[path]
+		delete(fileMarks, path)
+		for m := range marks {
+			m.Path = to + path[len(from):]
+			if fileMarks[m.Path] == nil {
+				fileMarks[m.Path] = make(map[*Mark]bool)
+			}
+			fileMarks[m.Path][m] = true
+		}
+	}
+}
+
+// removeMarks stops the marks of a deleted file or of the files in a deleted
+// directory from following edits
+func removeMarks(dir string) {
+	for path := range fileMarks {
+		if inPath(path, dir) {
+			delete(fileMarks, path)
+		}
+	}
+}
+
+// insertEnd returns the location of the end of a text inserted at start
+func insertEnd(start Loc, text []byte) Loc {
+	n := bytes.Count(text, []byte{'\n'})
+	if n == 0 {
+		return Loc{start.X + utf8.RuneCount(text), start.Y}
+	}
+	return Loc{utf8.RuneCount(text[bytes.LastIndexByte(text, '\n')+1:]), start.Y + n}
+}
+
+// locAfterInsert returns where a location moves when the text from start to
+// end is inserted
+func locAfterInsert(loc, start, end Loc) Loc {
+	switch {
+	case loc.LessThan(start):
+		return loc
+	case loc.Y == start.Y:
+		return Loc{end.X + loc.X - start.X, end.Y}
+	}
+	return Loc{loc.X, loc.Y + end.Y - start.Y}
+}
+
+// locAfterRemove returns where a location moves when the text from start to
+// end is removed; the locations in the text move to its start
+func locAfterRemove(loc, start, end Loc) Loc {
+	switch {
+	case loc.LessThan(start):
+		return loc
+	case loc.LessEqual(end):
+		return start
+	case loc.Y == end.Y:
+		return Loc{start.X + loc.X - end.X, start.Y}
+	}
+	return Loc{loc.X, loc.Y - (end.Y - start.Y)}
+}
+
+// moveMarks moves the marks of the file of the buffer, its change list and
+// its folds after an edit
+func (b *Buffer) moveMarks(move func(loc Loc) Loc) {
+	if b.AbsPath != "" {
+		for m := range fileMarks[b.AbsPath] {
+			m.Loc = move(m.Loc)
+		}
+	}
+	for i, loc := range b.changes {
+		b.changes[i] = move(loc)
+	}
+	b.folds.move(move)
+}
+
+// addChange records the location of an edit in the change list of the
+// buffer; edits on the line of the last change replace it
+func (b *Buffer) addChange(loc Loc) {
+	if n := len(b.changes); n > 0 && b.changes[n-1].Y == loc.Y {
+		b.changes[n-1] = loc
+	} else {
+		b.changes = append(b.changes, loc)
+		if len(b.changes) > maxChanges {
+			b.changes = b.changes[1:]
+		}
+	}
+	b.changeIndex = len(b.changes)
+}
+
+// A JumpList holds the locations a view jumped from, oldest first
+// Pos is the index of the location the view is at after going back, or the
+// length of the list when it didn't go back
+type JumpList struct {
+	Jumps []*Mark
+	Pos   int
+}
+
+// truncate drops the jumps from index i on
+func (j *JumpList) truncate(i int) {
+	for _, m := range j.Jumps[i:] {
+		m.Delete()
+	}
+	j.Jumps = j.Jumps[:i]
+	j.Pos = Min(j.Pos, i)
+}
+
+// Push records a location jumped from, dropping the jumps which were gone
+// back over and an older jump to the same line
+func (j *JumpList) Push(path string, loc Loc) {
+	j.truncate(j.Pos)
+	for i, m := range j.Jumps {
+		if m.Path == path && m.Loc.Y == loc.Y {
+			m.Delete()
+			j.Jumps = append(j.Jumps[:i], j.Jumps[i+1:]...)
+			break
+		}
+	}
+	j.Jumps = append(j.Jumps, NewMark(path, loc))
+	if len(j.Jumps) > maxJumps {
+		j.Jumps[0].Delete()
+		j.Jumps = j.Jumps[1:]
+	}
+	j.Pos = len(j.Jumps)
+}
+
+// Back returns the location before the current one, recording the current
+// location first if the view didn't go back yet, so that Forward returns to it
+func (j *JumpList) Back(path string, loc Loc) (*Mark, bool) {
+	if j.Pos == len(j.Jumps) {
+		j.Push(path, loc)
+		j.Pos = len(j.Jumps) - 1
+	}
+	if j.Pos == 0 {
+		return nil, false
+	}
+	j.Pos--
+	return j.Jumps[j.Pos], true
+}
+
+// Forward returns the location after the current one, after going back
+func (j *JumpList) Forward() (*Mark, bool) {
+	if j.Pos+1 >= len(j.Jumps) {
+		return nil, false
+	}
+	j.Pos++
+	return j.Jumps[j.Pos], true
+}
+
+// Pick makes a jump of the list the current location and returns the mark to
+// go to, recording the current location first if the view didn't go back yet
+// Recording it drops an older jump to the same line, which may be the one
+// picked, so that jump is then found again by its path and line
+func (j *JumpList) Pick(m *Mark, path string, loc Loc) *Mark {
+	if j.Pos == len(j.Jumps) {
+		j.Push(path, loc)
+	}
+	for i, jm := range j.Jumps {
+		if jm == m {
+			j.Pos = i
+			return m
+		}
+	}
+	for i, jm := range j.Jumps {
+		if jm.Path == m.Path && jm.Loc.Y == m.Loc.Y {
+			j.Pos = i
+			return jm
+		}
+	}
+	return m
+}
+
+// Copy returns a copy of the jump list with its own marks
+func (j *JumpList) Copy() JumpList {
+	c := JumpList{Pos: j.Pos}
+	for _, m := range j.Jumps {
+		c.Jumps = append(c.Jumps, NewMark(m.Path, m.Loc))
+	}
+	return c
+}
+
+// Serialize returns the jump list as plain marks, which don't follow edits
+func (j *JumpList) Serialize() []Mark {
+	marks := make([]Mark, len(j.Jumps))
+	for i, m := range j.Jumps {
+		marks[i] = *m
+	}
+	return marks
+}
+
+// restoreJumpList returns a jump list with the serialized marks
+func restoreJumpList(marks []Mark, pos int) JumpList {
+	var j JumpList
+	for _, m := range marks {
+		j.Jumps = append(j.Jumps, NewMark(m.Path, m.Loc))
+	}
+	j.Pos = Max(0, Min(pos, len(j.Jumps)))
+	return j
+}
+
+// recordJump adds the location of the cursor to the jump list of the view,
+// before the cursor jumps away
+func (v *View) recordJump() {
+	v.jumps.Push(v.Buf.AbsPath, v.Cursor.Loc)
+}
+
+// gotoMark moves the cursor to a mark, opening its file if needed
+func (v *View) gotoMark(m *Mark) {
+	if m.Path != "" && m.Path != v.Buf.AbsPath && !v.openFile(m.Path) {
+		return
+	}
+	loc := m.Loc
+	loc.Y = Max(0, Min(loc.Y, v.Buf.NumLines-1))
+	loc.X = Max(0, Min(loc.X, utf8.RuneCountInString(v.Buf.Line(loc.Y))))
+	v.gotoSymbolLoc(loc)
+}
+
+// markPreview returns the line of a mark, trimmed, reading the files which
+// aren't open from the disk once
+func markPreview(m *Mark, files map[string][]string) string {
+	lines, ok := files[m.Path]
+	if !ok {
+		for _, t := range tabs {
+			for _, view := range t.views {
+				if view.Buf.AbsPath == m.Path {
+					lines = view.Buf.Lines(0, view.Buf.NumLines)
+				}
+			}
+		}
+		if lines == nil && m.Path != "" {
+			if data, err := ioutil.ReadFile(m.Path); err == nil {
+				lines = strings.Split(string(data), "\n")
+			}
+		}
+		files[m.Path] = lines
+	}
+	if m.Loc.Y < len(lines) {
+		return strings.TrimSpace(lines[m.Loc.Y])
+	}
+	return ""
+}
+
+// openMarkPicker opens a fuzzy finder of marks, each with its label and the
+// line it is on
+func (v *View) openMarkPicker(marks []*Mark, labels []string, pick func(i int)) {
+	files := make(map[string][]string)
+	autocomplete.Open(func(v *View) (messages Messages) {
+		for i, m := range marks {
+			name := "[No Name]"
+			if m.Path != "" {
+				name = workingDirPath(m.Path)
+			}
+			place := fmt.Sprintf("%s:%d", name, m.Loc.Y+1)
+			preview := markPreview(m, files)
+			messages = append(messages, Message{
+				Searchable:       place + " " + preview,
+				MessageToDisplay: fmt.Sprintf("%s%s  %s", labels[i], place, preview),
+				Value2:           []byte(strconv.Itoa(i)),
+			})
+		}
+		return messages
+	}, func(message Message) {
+		i, err := strconv.Atoi(string(message.Value2))
+		if err != nil || i >= len(marks) {
+			return
+		}
+		pick(i)
+	}, nil, v)
+}
+
+// openHistoryPicker opens a fuzzy finder of a jump or change list, newest
+// first; the mark at index cur is shown with a marker
+func (v *View) openHistoryPicker(marks []*Mark, cur int, pick func(i int)) {
+	n := len(marks)
+	newest := make([]*Mark, n)
+	labels := make([]string, n)
+	for i, m := range marks {
+		newest[n-1-i] = m
+		labels[n-1-i] = "  "
+		if i == cur {
+			labels[n-1-i] = "> "
+		}
+	}
+	v.openMarkPicker(newest, labels, func(i int) {
+		pick(n - 1 - i)
+	})
+}
+
+// jumpListPath returns the file the jump list of the last view is kept in
+func jumpListPath() string {
+	return filepath.Join(configDir, "buffers", "jumplist")
+}
+
+// saveJumpList stores the jump list of the current view for the next time
+// micro starts, if the savecursor option is on
+func saveJumpList() {
+	if !globalSettings["savecursor"].(bool) || len(tabs) == 0 {
+		return
+	}
+	v := CurView()
+	file, err := os.Create(jumpListPath())
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	gob.NewEncoder(file).Encode(v.jumps.Serialize())
+}
+
+// loadJumpList gives the jump list stored when micro last quit to the current
+// view, if the savecursor option is on
+func loadJumpList() {
+	if !globalSettings["savecursor"].(bool) {
+		return
+	}
+	file, err := os.Open(jumpListPath())
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	var marks []Mark
+	if gob.NewDecoder(file).Decode(&marks) == nil {
+		CurView().jumps = restoreJumpList(marks, len(marks))
+	}
+}
+
+// NextLoc goes forward in the jump list of the view, after PrevLoc
+func (v *View) NextLoc(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("NextLoc", v) {
+		return false
+	}
+
+	if m, ok := v.jumps.Forward(); ok {
+		v.gotoMark(m)
+	}
+
+	if usePlugin {
+		return PostActionCall("NextLoc", v)
+	}
+	return true
+}
+
+// PrevLoc goes back to the location the view last jumped from
+func (v *View) PrevLoc(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("PrevLoc", v) {
+		return false
+	}
+
+	if m, ok := v.jumps.Back(v.Buf.AbsPath, v.Cursor.Loc); ok {
+		v.gotoMark(m)
+	}
+
+	if usePlugin {
+		return PostActionCall("PrevLoc", v)
+	}
+	return true
+}
+
+// JumpList opens a fuzzy finder of the jump list of the view, with the line of
+// each jump
+func (v *View) JumpList(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("JumpList", v) {
+		return false
+	}
+
+	if len(v.jumps.Jumps) == 0 {
+		messenger.Message("The jump list is empty")
+	} else {
+		jumps := v.jumps.Jumps
+		v.openHistoryPicker(jumps, v.jumps.Pos, func(i int) {
+			v.gotoMark(v.jumps.Pick(jumps[i], v.Buf.AbsPath, v.Cursor.Loc))
+		})
+	}
+
+	if usePlugin {
+		return PostActionCall("JumpList", v)
+	}
+	return true
+}
+
+// changeMarks returns the change list of the buffer as marks
+func (b *Buffer) changeMarks() []*Mark {
+	marks := make([]*Mark, len(b.changes))
+	for i, loc := range b.changes {
+		marks[i] = &Mark{Path: b.AbsPath, Loc: loc}
+	}
+	return marks
+}
+
+// PrevChange moves the cursor to the previous location in the change list of
+// the buffer, starting from the last edit
+func (v *View) PrevChange(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("PrevChange", v) {
+		return false
+	}
+
+	b := v.Buf
+	i := Min(b.changeIndex, len(b.changes)) - 1
+	if i == len(b.changes)-1 && i > 0 && b.changes[i].Y == v.Cursor.Y {
+		// The cursor is at the last edit already
+		i--
+	}
+	if i < 0 {
+		messenger.Message("No older change")
+	} else {
+		b.changeIndex = i
+		v.gotoSymbolLoc(b.changes[i])
+	}
+
+	if usePlugin {
+		return PostActionCall("PrevChange", v)
+	}
+	return true
+}
+
+// NextChange moves the cursor to the next location in the change list of the
+// buffer, after PrevChange
+func (v *View) NextChange(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("NextChange", v) {
+		return false
+	}
+
+	b := v.Buf
+	if b.changeIndex+1 >= len(b.changes) {
+		messenger.Message("No newer change")
+	} else {
+		b.changeIndex++
+		v.gotoSymbolLoc(b.changes[b.changeIndex])
+	}
+
+	if usePlugin {
+		return PostActionCall("NextChange", v)
+	}
+	return true
+}
+
+// ChangeList opens a fuzzy finder of the change list of the buffer, with the
+// line of each change
+func (v *View) ChangeList(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ChangeList", v) {
+		return false
+	}
+
+	if len(v.Buf.changes) == 0 {
+		messenger.Message("The change list is empty")
+	} else {
+		b := v.Buf
+		v.openHistoryPicker(b.changeMarks(), b.changeIndex, func(i int) {
+			if v.Buf == b && i < len(b.changes) {
+				b.changeIndex = i
+				v.gotoSymbolLoc(b.changes[i])
+			}
+		})
+	}
+
+	if usePlugin {
+		return PostActionCall("ChangeList", v)
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLocAfterEdit(t *testing.T) {
+	tests := []struct {
+		loc, start, end Loc
+		insert          bool
+		want            Loc
+	}{
+		{Loc{5, 2}, Loc{3, 4}, Loc{0, 5}, true, Loc{5, 2}},
+		{Loc{5, 2}, Loc{3, 2}, Loc{6, 2}, true, Loc{8, 2}},
+		{Loc{5, 2}, Loc{3, 2}, Loc{1, 4}, true, Loc{3, 4}},
+		{Loc{5, 3}, Loc{3, 2}, Loc{1, 4}, true, Loc{5, 5}},
+		{Loc{3, 2}, Loc{3, 2}, Loc{4, 2}, true, Loc{4, 2}},
+		{Loc{5, 2}, Loc{6, 2}, Loc{0, 3}, false, Loc{5, 2}},
+		{Loc{5, 2}, Loc{3, 2}, Loc{7, 2}, false, Loc{3, 2}},
+		{Loc{9, 2}, Loc{3, 2}, Loc{7, 2}, false, Loc{5, 2}},
+		{Loc{6, 4}, Loc{3, 2}, Loc{2, 4}, false, Loc{7, 2}},
+		{Loc{1, 8}, Loc{3, 2}, Loc{2, 4}, false, Loc{1, 6}},
+	}
+	for _, test := range tests {
+		var got Loc
+		if test.insert {
+			got = locAfterInsert(test.loc, test.start, test.end)
+		} else {
+			got = locAfterRemove(test.loc, test.start, test.end)
+		}
+		if got != test.want {
+			t.Errorf("edit %v-%v (insert %v) moves %v to %v, want %v", test.start, test.end, test.insert, test.loc, got, test.want)
+		}
+	}
+	if end := insertEnd(Loc{2, 1}, []byte("ab\ncdé")); end != (Loc{3, 2}) {
+		t.Errorf("insertEnd: got %v", end)
+	}
+}
+
+func TestJumpList(t *testing.T) {
+	var j JumpList
+	j.Push("/a", Loc{0, 1})
+	j.Push("/a", Loc{0, 10})
+	// The first PrevLoc moves back from where the view jumped to
+	if m, ok := j.Back("/b", Loc{0, 3}); !ok || m.Path != "/a" || m.Loc.Y != 10 {
+		t.Errorf("Back: got %v %v", m, ok)
+	}
+	if m, ok := j.Back("/a", Loc{0, 10}); !ok || m.Loc.Y != 1 {
+		t.Errorf("second Back: got %v %v", m, ok)
+	}
+	if _, ok := j.Back("/a", Loc{0, 1}); ok {
+		t.Errorf("Back went past the oldest jump")
+	}
+	if m, ok := j.Forward(); !ok || m.Loc.Y != 10 {
+		t.Errorf("Forward: got %v %v", m, ok)
+	}
+	if m, ok := j.Forward(); !ok || m.Path != "/b" {
+		t.Errorf("Forward to the current location: got %v %v", m, ok)
+	}
+	if _, ok := j.Forward(); ok {
+		t.Errorf("Forward went past the newest jump")
+	}
+
+	// A jump after going back drops the newer jumps, and an older jump to the
+	// same line is replaced
+	j.Back("/b", Loc{0, 3})
+	j.Push("/a", Loc{4, 1})
+	if len(j.Jumps) != 1 || j.Jumps[0].Loc != (Loc{4, 1}) || j.Pos != 1 {
+		t.Errorf("Push after Back: got %v at %d", j.Serialize(), j.Pos)
+	}
+
+	// The marks of the jumps follow the edits of their file
+	b := &Buffer{AbsPath: "/a"}
+	b.moveMarks(func(loc Loc) Loc { return locAfterInsert(loc, Loc{0, 0}, Loc{0, 2}) })
+	c := j.Copy()
+	if j.Jumps[0].Loc != (Loc{4, 3}) || c.Jumps[0].Loc != (Loc{4, 3}) {
+		t.Errorf("moveMarks: got %v", j.Jumps[0].Loc)
+	}
+	j.truncate(0)
+	c.truncate(0)
+
+	// Picking a jump to the line of the current location goes to the jump
+	// which replaced it
+	j.Push("/a", Loc{0, 1})
+	j.Push("/a", Loc{0, 7})
+	if m := j.Pick(j.Jumps[0], "/a", Loc{2, 1}); len(j.Jumps) != 2 || m != j.Jumps[1] || j.Pos != 1 || m.Loc != (Loc{2, 1}) {
+		t.Errorf("Pick of a replaced jump: got %v at %d", j.Serialize(), j.Pos)
+	}
+	if m := j.Pick(j.Jumps[0], "/a", Loc{2, 1}); m.Loc.Y != 7 || j.Pos != 0 || len(j.Jumps) != 2 {
+		t.Errorf("Pick after going back: got %v at %d", j.Serialize(), j.Pos)
+	}
+	j.truncate(0)
+	if len(fileMarks) != 0 {
+		t.Errorf("marks of dropped jumps are still moved: %v", fileMarks)
+	}
+}
+
+func TestChangeList(t *testing.T) {
+	b := new(Buffer)
+	for _, loc := range []Loc{{1, 0}, {4, 0}, {0, 5}, {2, 9}} {
+		b.addChange(loc)
+	}
+	b.moveMarks(func(loc Loc) Loc { return locAfterRemove(loc, Loc{0, 1}, Loc{0, 3}) })
+	want := []Loc{{4, 0}, {0, 3}, {2, 7}}
+	if len(b.changes) != len(want) || b.changeIndex != len(want) {
+		t.Fatalf("addChange: got %v at %d", b.changes, b.changeIndex)
+	}
+	for i := range want {
+		if b.changes[i] != want[i] {
+			t.Errorf("change %d: got %v, want %v", i, b.changes[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/zyedidia/clipboard"
+	"github.com/zyedidia/tcell"
+)
+
+// The keymap contexts used while one of the boxes or the prompt has the focus
+// Their bindings are given in bindings.json in objects named context:name
+const contextPrefix = "context:"
+
+var contextKeyMaps map[string]*keyMap
+
+// DefaultContextBindings returns the default keybindings of the contexts
+func DefaultContextBindings() map[string]map[string]string {
+	return map[string]map[string]string{
+		// The autocompletion box and the pickers which use it
+		"completion": {
+			"Enter": "Accept",
+			"Tab":   "Complete",
+			"Esc":   "Cancel",
+			"Up":    "CursorUp",
+			"Down":  "CursorDown",
+		},
+		// The fields of a snippet being filled in
+		"snippet": {
+			"Tab": "NextField",
+			"Esc": "Cancel",
+		},
+		// The command prompt and the other questions asked in the infobar
+		"prompt": {
+			"Enter": "Accept",
+			"Tab":   "Complete",
+			"Esc":   "Cancel",
+			"CtrlQ": "Cancel",
+			"CtrlC": "Cancel",
+		},
+		// The documentation shown by Describe or by hovering with the mouse
+		"hover": {
+			"Up":       "CursorUp",
+			"Down":     "CursorDown",
+			"PageUp":   "CursorPageUp",
+			"PageDown": "CursorPageDown",
+			"Esc":      "Cancel",
+		},
+		// The parameters shown while typing the arguments of a call
+		"signature": {
+			"Esc": "Cancel",
+		},
+		// The rows of the explorer and of the call and type hierarchies
+		"tree": {
+			"Enter": "Open",
+			"Tab":   "Toggle",
+		},
+		// The keys of the explorer besides those of the tree context
+		"explorer": {
+			"v": "OpenVSplit",
+			"s": "OpenHSplit",
+			"t": "OpenTab",
+			"a": "NewFile",
+			"r": "Rename",
+			"m": "Move",
+			"d": "Delete",
+			"i": "ToggleIgnored",
+			"R": "Refresh",
+		},
+		// The prompt of an incremental search
+		"search": {
+			"Enter": "Accept",
+			"CtrlQ": "Accept",
+			"CtrlC": "Accept",
+			"Esc":   "Cancel",
+		},
+	}
+}
+
+// initContextBindings binds the default keys of the contexts
+func initContextBindings() {
+	contextKeyMaps = make(map[string]*keyMap)
+	for name, userBindings := range DefaultContextBindings() {
+		contextKeyMaps[name] = newKeyMap()
+		bindContextKeys(name, userBindings)
+	}
+}
+
+// bindContextKeys binds keys in a context
+// Contexts only have single keys, not key sequences
+func bindContextKeys(name string, userBindings map[string]string) []string {
+	m, ok := contextKeyMaps[name]
+	if !ok {
+		return []string{"Unknown keymap context " + name}
+	}
+	errs := duplicateKeys(userBindings)
+	for k, v := range userBindings {
+		seq, err := parseKeySequence(k)
+		if err == nil && len(seq) > 1 {
+			err = errors.New(contextPrefix + name + ": key sequences can't be used in contexts: " + k)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		m.bind(seq, strings.Split(v, ","))
+	}
+	return errs
+}
+
+// eventKey returns the binding key of a key event
+func eventKey(e *tcell.EventKey) Key {
+	k := Key{keyCode: e.Key(), modifiers: e.Modifiers()}
+	if e.Key() == tcell.KeyRune {
+		k.r = e.Rune()
+	}
+	return k
+}
+
+// contextActions returns the names of the actions bound to the key in the context
+func contextActions(context string, e *tcell.EventKey) []string {
+	if n := contextKeyMaps[context].find([]Key{eventKey(e)}); n != nil {
+		return n.names
+	}
+	return nil
+}
+
+// globalActions returns the names of the actions bound to the key in the views
+// The boxes use them to edit the text typed into them in the same way
+func globalActions(e *tcell.EventKey) []string {
+	if e.Key() == tcell.KeyRune && e.Modifiers() == 0 {
+		return nil
+	}
+	if n := globalKeyMap.find([]Key{eventKey(e)}); n != nil {
+		return n.names
+	}
+	return nil
+}
+
+// textActions are the actions of views which edit the line of text typed in
+// the prompt or one of the boxes
+var textActions = map[string]func(text *string, cursorx *int){
+	"CursorLeft": func(text *string, cursorx *int) {
+		if *cursorx > 0 {
+			*cursorx--
+		}
+	},
+	"CursorRight": func(text *string, cursorx *int) {
+		if *cursorx < Count(*text) {
+			*cursorx++
+		}
+	},
+	"CursorStart": func(text *string, cursorx *int) {
+		*cursorx = 0
+	},
+	"StartOfLine": func(text *string, cursorx *int) {
+		*cursorx = 0
+	},
+	"CursorEnd": func(text *string, cursorx *int) {
+		*cursorx = Count(*text)
+	},
+	"EndOfLine": func(text *string, cursorx *int) {
+		*cursorx = Count(*text)
+	},
+	"Backspace": func(text *string, cursorx *int) {
+		if *cursorx > 0 {
+			*text = string([]rune(*text)[:*cursorx-1]) + string([]rune(*text)[*cursorx:])
+			*cursorx--
+		}
+	},
+	"Delete": func(text *string, cursorx *int) {
+		if *cursorx < Count(*text) {
+			*text = string([]rune(*text)[:*cursorx]) + string([]rune(*text)[*cursorx+1:])
+		}
+	},
+	"Paste": func(text *string, cursorx *int) {
+		clip, _ := clipboard.ReadAll("clipboard")
+		*text = Insert(*text, *cursorx, clip)
+		*cursorx += Count(clip)
+	},
+}
+
+// editText runs the text actions among the names on the text and returns
+// whether there were any
+func editText(names []string, text *string, cursorx *int) bool {
+	edited := false
+	for _, name := range names {
+		if action, ok := textActions[name]; ok {
+			action(text, cursorx)
+			edited = true
+		}
+	}
+	return edited
+}
+
+// hasAction returns whether the name is among the names
+func hasAction(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zyedidia/tcell"
+)
+
+func TestEditText(t *testing.T) {
+	var tests = []struct {
+		names   []string
+		text    string
+		cursorx int
+		want    string
+		wantX   int
+	}{
+		{[]string{"CursorLeft"}, "abc", 2, "abc", 1},
+		{[]string{"CursorLeft"}, "abc", 0, "abc", 0},
+		{[]string{"CursorRight"}, "abc", 3, "abc", 3},
+		{[]string{"EndOfLine"}, "äbc", 0, "äbc", 3},
+		{[]string{"Backspace"}, "äbc", 1, "bc", 0},
+		{[]string{"Delete"}, "abc", 1, "ac", 1},
+		{[]string{"Delete"}, "abc", 3, "abc", 3},
+		{[]string{"CursorStart", "Delete"}, "abc", 2, "bc", 0},
+	}
+	for _, test := range tests {
+		text, x := test.text, test.cursorx
+		if !editText(test.names, &text, &x) {
+			t.Errorf("editText(%v) should edit the text", test.names)
+		}
+		if text != test.want || x != test.wantX {
+			t.Errorf("editText(%v, %q, %d) = %q, %d, want %q, %d", test.names, test.text, test.cursorx, text, x, test.want, test.wantX)
+		}
+	}
+	text, x := "abc", 1
+	if editText([]string{"Accept"}, &text, &x) {
+		t.Errorf("Accept is not a text action")
+	}
+}
+
+func TestExplorerContext(t *testing.T) {
+	globalSettings = DefaultGlobalSettings()
+	initContextBindings()
+	defer initContextBindings()
+
+	for k, name := range DefaultContextBindings()["explorer"] {
+		if _, ok := explorerActions[name]; !ok {
+			t.Errorf("%s is bound to %s, which is not an explorer action", k, name)
+		}
+	}
+
+	if errs := bindContextKeys("explorer", map[string]string{"x": "Delete", "d": "UnbindKey"}); len(errs) > 0 {
+		t.Fatalf("bindContextKeys: %v", errs)
+	}
+	var tests = []struct {
+		context string
+		key     *tcell.EventKey
+		want    []string
+	}{
+		{"explorer", tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone), []string{"Delete"}},
+		{"explorer", tcell.NewEventKey(tcell.KeyRune, 'd', tcell.ModNone), []string{}},
+		{"explorer", tcell.NewEventKey(tcell.KeyRune, 'R', tcell.ModNone), []string{"Refresh"}},
+		{"explorer", tcell.NewEventKey(tcell.KeyRune, 'R', tcell.ModAlt), nil},
+		{"tree", tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), []string{"Open"}},
+		{"tree", tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone), []string{"Toggle"}},
+	}
+	for _, test := range tests {
+		if names := contextActions(test.context, test.key); !reflect.DeepEqual(names, test.want) {
+			t.Errorf("contextActions(%s, %v) = %v, want %v", test.context, test.key.Name(), names, test.want)
+		}
+	}
+}
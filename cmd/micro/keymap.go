@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zyedidia/tcell"
+)
+
+// A keyMap is a trie of key sequences bound to actions
+// Single keys are the children of the root, and a node which has children
+// starts a chord such as `CtrlK CtrlC`
+type keyMap struct {
+	actions  []func(*View, bool) bool
+	names    []string
+	children map[Key]*keyMap
+}
+
+var (
+	// The bindings of all views
+	globalKeyMap *keyMap
+	// Bindings which only apply to buffers of a filetype
+	filetypeKeyMaps map[string]*keyMap
+	// Bindings which only apply to a type of view
+	viewTypeKeyMaps map[string]*keyMap
+)
+
+// viewTypeNames are the names used for view types in bindings.json
+var viewTypeNames = map[ViewType]string{
+	vtDefault: "default",
+	vtHelp:    "help",
+	vtLog:     "log",
+	vtScratch: "scratch",
+}
+
+// The prefixes of the scoped maps in bindings.json
+const (
+	filetypePrefix = "filetype:"
+	viewTypePrefix = "viewtype:"
+)
+
+func newKeyMap() *keyMap {
+	return &keyMap{children: make(map[Key]*keyMap)}
+}
+
+// find returns the node of the key sequence, or nil if nothing starts with it
+func (m *keyMap) find(seq []Key) *keyMap {
+	for _, k := range seq {
+		if m = m.children[k]; m == nil {
+			return nil
+		}
+	}
+	return m
+}
+
+// bind binds the key sequence to the actions with the given names
+// If the first name is UnbindKey the old binding is removed first
+func (m *keyMap) bind(seq []Key, names []string) {
+	for _, k := range seq {
+		child, ok := m.children[k]
+		if !ok {
+			child = newKeyMap()
+			m.children[k] = child
+		}
+		m = child
+	}
+	if len(names) > 0 && names[0] == "UnbindKey" {
+		m.actions, m.names = nil, nil
+		names = names[1:]
+	}
+	m.names = names
+	m.actions = make([]func(*View, bool) bool, 0, len(names))
+	for _, name := range names {
+		m.actions = append(m.actions, findAction(name))
+	}
+}
+
+// bound returns whether the node runs actions
+func (m *keyMap) bound() bool {
+	return m != nil && len(m.actions) > 0
+}
+
+// conflicts returns the key sequences which are bound to actions and also start
+// longer sequences, either in this map or in the global map which it extends
+func (m *keyMap) conflicts(prefix []Key, global *keyMap) []string {
+	var list []string
+	seq := keySequenceString(prefix)
+	if len(prefix) > 0 {
+		var g *keyMap
+		if global != nil {
+			g = global.find(prefix)
+		}
+		switch {
+		case m.bound() && len(m.children) > 0:
+			list = append(list, seq+" is bound to "+strings.Join(m.names, ",")+" and starts longer key sequences")
+		case m.bound() && g != nil && len(g.children) > 0:
+			list = append(list, seq+" is bound to "+strings.Join(m.names, ",")+" and starts global key sequences")
+		case len(m.children) > 0 && g.bound():
+			list = append(list, seq+" starts key sequences and is bound to "+strings.Join(g.names, ",")+" globally")
+		}
+	}
+	for k, child := range m.children {
+		list = append(list, child.conflicts(append(prefix[:len(prefix):len(prefix)], k), global)...)
+	}
+	return list
+}
+
+// keyName returns the name of a tcell key code as used in bindings.json
+// Some keys have two names, such as Tab and CtrlI, and the one starting with
+// Ctrl is only used if ctrl is set
+func keyName(code tcell.Key, ctrl bool) string {
+	best := ""
+	for name, c := range bindingKeys {
+		if c != code || name == "PgUp" || name == "PgDown" {
+			continue
+		}
+		if best == "" {
+			best = name
+			continue
+		}
+		bestCtrl, nameCtrl := strings.HasPrefix(best, "Ctrl"), strings.HasPrefix(name, "Ctrl")
+		if nameCtrl != bestCtrl {
+			if nameCtrl == ctrl {
+				best = name
+			}
+		} else if len(name) < len(best) || len(name) == len(best) && name < best {
+			best = name
+		}
+	}
+	return best
+}
+
+// String returns the name of the key as used in bindings.json
+func (k Key) String() string {
+	name := string(k.r)
+	if k.keyCode != tcell.KeyRune {
+		name = keyName(k.keyCode, k.modifiers&tcell.ModCtrl != 0)
+	}
+	mods := ""
+	if k.modifiers&tcell.ModCtrl != 0 && !strings.HasPrefix(name, "Ctrl") {
+		mods += "Ctrl"
+	}
+	if k.modifiers&tcell.ModAlt != 0 {
+		mods += "Alt"
+	}
+	if k.modifiers&tcell.ModShift != 0 {
+		mods += "Shift"
+	}
+	return mods + name
+}
+
+// keySequenceString returns the names of the keys separated by spaces
+func keySequenceString(seq []Key) string {
+	names := make([]string, len(seq))
+	for i, k := range seq {
+		names[i] = k.String()
+	}
+	return strings.Join(names, " ")
+}
+
+// parseKeySequence parses keys separated by spaces such as `CtrlK CtrlC`
+// The name Leader stands for the key in the leader option
+func parseKeySequence(s string) ([]Key, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, errors.New("Unknown keybinding: " + s)
+	}
+	seq := make([]Key, 0, len(fields))
+	for _, f := range fields {
+		name := f
+		if f == "Leader" {
+			name = globalSettings["leader"].(string)
+		}
+		key, ok := findKey(name)
+		if !ok {
+			return nil, errors.New("Unknown keybinding: " + s)
+		}
+		seq = append(seq, key)
+	}
+	return seq, nil
+}
+
+// duplicateKeys returns the keys of a map in bindings.json which are written
+// in different ways but are the same key sequence bound to different actions
+func duplicateKeys(userBindings map[string]string) []string {
+	var errs []string
+	seen := make(map[string]string)
+	for k, v := range userBindings {
+		seq, err := parseKeySequence(k)
+		if err != nil {
+			continue
+		}
+		name := keySequenceString(seq)
+		if other, ok := seen[name]; ok && userBindings[other] != v {
+			errs = append(errs, k+" and "+other+" are the same keys")
+		}
+		seen[name] = k
+	}
+	return errs
+}
+
+// parseKeyMap binds the bindings of a filetype or view type map
+func parseKeyMap(m *keyMap, userBindings map[string]string) []string {
+	errs := duplicateKeys(userBindings)
+	for k, v := range userBindings {
+		seq, err := parseKeySequence(k)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		m.bind(seq, strings.Split(v, ","))
+	}
+	return errs
+}
+
+// parseScopedBindings reads the filetype, view type and context maps in bindings.json
+func parseScopedBindings(parsed map[string]interface{}) []string {
+	var errs []string
+	for scope, value := range parsed {
+		if !strings.HasPrefix(scope, filetypePrefix) && !strings.HasPrefix(scope, viewTypePrefix) &&
+			!strings.HasPrefix(scope, contextPrefix) {
+			continue
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			errs = append(errs, scope+" must be an object of keybindings")
+			continue
+		}
+		userBindings := make(map[string]string)
+		for k, v := range obj {
+			if s, ok := v.(string); ok {
+				userBindings[k] = s
+			} else {
+				errs = append(errs, scope+": the action of "+k+" must be a string")
+			}
+		}
+
+		var maps map[string]*keyMap
+		var name string
+		switch {
+		case strings.HasPrefix(scope, filetypePrefix):
+			maps, name = filetypeKeyMaps, strings.TrimPrefix(scope, filetypePrefix)
+		case strings.HasPrefix(scope, viewTypePrefix):
+			maps, name = viewTypeKeyMaps, strings.TrimPrefix(scope, viewTypePrefix)
+		default:
+			errs = append(errs, bindContextKeys(strings.TrimPrefix(scope, contextPrefix), userBindings)...)
+			continue
+		}
+		if maps[name] == nil {
+			maps[name] = newKeyMap()
+		}
+		errs = append(errs, parseKeyMap(maps[name], userBindings)...)
+	}
+	return errs
+}
+
+// bindingConflicts returns the conflicts between all loaded keybindings
+func bindingConflicts() []string {
+	list := globalKeyMap.conflicts(nil, nil)
+	for _, maps := range []map[string]*keyMap{filetypeKeyMaps, viewTypeKeyMaps} {
+		for name, m := range maps {
+			for _, c := range m.conflicts(nil, globalKeyMap) {
+				list = append(list, name+": "+c)
+			}
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// keyMaps returns the maps which apply to the view, most specific first
+func (v *View) keyMaps() []*keyMap {
+	var maps []*keyMap
+	if m, ok := viewTypeKeyMaps[viewTypeNames[v.Type]]; ok {
+		maps = append(maps, m)
+	}
+	if m, ok := filetypeKeyMaps[v.Buf.FileType()]; ok {
+		maps = append(maps, m)
+	}
+	return append(maps, globalKeyMap)
+}
+
+// findBinding looks up a key sequence in the maps
+// It returns the first node which runs actions, and whether any map has
+// longer sequences starting with it
+func findBinding(maps []*keyMap, seq []Key) (*keyMap, bool) {
+	var found *keyMap
+	pending := false
+	for _, m := range maps {
+		n := m.find(seq)
+		if n == nil {
+			continue
+		}
+		if found == nil && n.bound() {
+			found = n
+		}
+		pending = pending || len(n.children) > 0
+	}
+	return found, pending
+}
+
+// runBinding runs the actions of a binding and returns whether the view should relocate
+func (v *View) runBinding(b *keyMap) bool {
+	relocate := false
+	for i, action := range b.actions {
+		relocate = action(v, true) || relocate
+		name := b.names[i]
+		if recordingMacro && name != "ToggleMacro" && name != "PlayMacro" {
+			curMacro.addAction(name)
+		}
+	}
+	return relocate
+}
+
+// isPlainKey returns whether the key types a character
+func isPlainKey(k Key) bool {
+	return k.keyCode == tcell.KeyRune && k.modifiers == 0
+}
+
+// insertChordText types the characters of a chord which turned out not to be bound
+func (v *View) insertChordText(seq []Key) {
+	for _, k := range seq {
+		if v.Cursor.HasSelection() {
+			v.Cursor.DeleteSelection()
+			v.Cursor.ResetSelection()
+		}
+		v.Buf.Insert(v.Cursor.Loc, string(k.r))
+		v.Cursor.Right()
+	}
+}
+
+// startChordTimer ends the pending chord after the chordtimeout option
+func (v *View) startChordTimer() {
+	v.chordID++
+	timeout := globalSettings["chordtimeout"].(float64)
+	if timeout <= 0 {
+		return
+	}
+	id := v.chordID
+	time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
+		jobs <- JobFunction{func(string, ...string) { v.chordTimeout(id) }, "", nil}
+	})
+}
+
+// chordTimeout runs the binding of the pending chord when no other key was
+// pressed in time, or types it if it is made of characters
+func (v *View) chordTimeout(id int) {
+	if id != v.chordID || len(v.chord) == 0 {
+		return
+	}
+	seq := v.chord
+	v.chord = nil
+	if b, _ := findBinding(v.keyMaps(), seq); b != nil {
+		if v.runBinding(b) {
+			v.Relocate()
+		}
+	} else if isPlainKey(seq[0]) {
+		v.insertChordText(seq)
+		v.Relocate()
+	}
+}
+
+// PendingChord returns the keys of the chord being typed in the view
+func (v *View) PendingChord() string {
+	return keySequenceString(v.chord)
+}
+
+// handleBindingKey runs the binding of a key event, following chords
+// It returns whether the key was used by a binding and whether the view should relocate
+func (v *View) handleBindingKey(e *tcell.EventKey) (bool, bool) {
+	k := eventKey(e)
+	prev := v.chord
+	seq := append(prev[:len(prev):len(prev)], k)
+	maps := v.keyMaps()
+	b, pending := findBinding(maps, seq)
+
+	if len(prev) == 0 && isPlainKey(k) && !pending {
+		// Characters are only bound as part of chords
+		return false, false
+	}
+	if pending {
+		v.chord = seq
+		v.startChordTimer()
+		return true, false
+	}
+	v.chord = nil
+	if b != nil {
+		return true, v.runBinding(b)
+	}
+	if len(prev) == 0 {
+		return false, false
+	}
+
+	// The chord is not bound, so end it and handle the key on its own
+	relocate := false
+	if prevBinding, _ := findBinding(maps, prev); prevBinding != nil {
+		relocate = v.runBinding(prevBinding)
+	} else if isPlainKey(prev[0]) {
+		v.insertChordText(prev)
+		relocate = true
+	} else {
+		messenger.Error(keySequenceString(seq), " is not bound")
+		return true, false
+	}
+	used, rel := v.handleBindingKey(e)
+	return used, relocate || rel
+}
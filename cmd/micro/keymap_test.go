@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zyedidia/tcell"
+)
+
+func TestParseKeySequence(t *testing.T) {
+	globalSettings = DefaultGlobalSettings()
+	globalSettings["leader"] = ","
+
+	var tests = []struct {
+		keys string
+		want string
+	}{
+		{"CtrlK CtrlC", "CtrlK CtrlC"},
+		{"Ctrl-K  Ctrl-C", "CtrlK CtrlC"},
+		{"Alt-ShiftLeft", "AltShiftLeft"},
+		{"Leader s", ", s"},
+		{"CtrlI", "CtrlI"},
+		{"Tab", "Tab"},
+		{"F5 Enter", "F5 Enter"},
+		{"CtrlK Nope", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		seq, err := parseKeySequence(test.keys)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("parseKeySequence(%q) should fail", test.keys)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseKeySequence(%q): %v", test.keys, err)
+			continue
+		}
+		if got := keySequenceString(seq); got != test.want {
+			t.Errorf("parseKeySequence(%q) = %q, want %q", test.keys, got, test.want)
+		}
+	}
+}
+
+func TestKeyMap(t *testing.T) {
+	ctrlK := Key{tcell.KeyCtrlK, tcell.ModCtrl, 0}
+	ctrlC := Key{tcell.KeyCtrlC, tcell.ModCtrl, 0}
+	m := newKeyMap()
+	m.bind([]Key{ctrlK, ctrlC}, []string{"Copy"})
+	if n := m.find([]Key{ctrlK}); n == nil || n.bound() || len(n.children) != 1 {
+		t.Errorf("CtrlK should start a chord")
+	}
+	if n := m.find([]Key{ctrlK, ctrlC}); !n.bound() || !reflect.DeepEqual(n.names, []string{"Copy"}) {
+		t.Errorf("CtrlK CtrlC should be bound to Copy")
+	}
+	if len(m.conflicts(nil, nil)) != 0 {
+		t.Errorf("unexpected conflicts %v", m.conflicts(nil, nil))
+	}
+
+	m.bind([]Key{ctrlK}, []string{"CutLine"})
+	if c := m.conflicts(nil, nil); len(c) != 1 {
+		t.Errorf("conflicts = %v, want CtrlK", c)
+	}
+	m.bind([]Key{ctrlK}, []string{"UnbindKey"})
+	if c := m.conflicts(nil, nil); len(c) != 0 {
+		t.Errorf("conflicts after unbinding = %v", c)
+	}
+
+	// A filetype map which starts a chord with a key bound globally
+	global := newKeyMap()
+	global.bind([]Key{ctrlK}, []string{"CutLine"})
+	if c := m.conflicts(nil, global); len(c) != 1 {
+		t.Errorf("conflicts with the global map = %v", c)
+	}
+	b, pending := findBinding([]*keyMap{m, global}, []Key{ctrlK})
+	if !pending || b == nil || b.names[0] != "CutLine" {
+		t.Errorf("findBinding(CtrlK) = %v, %v", b, pending)
+	}
+}
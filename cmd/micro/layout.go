@@ -0,0 +1,322 @@
+package main
+
+import (
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// A lineLayout places the characters of a line of the buffer on the rows of
+// the screen, taking tabs, wide and combining characters and soft wrapping
+// into account
+// The position after the last character, where the cursor goes at the end of
+// the line, is laid out like a character of one cell
+type lineLayout struct {
+	// rowStart holds the index of the first character of each row
+	rowStart []int
+	// row, col and width hold the row, the column in that row and the number
+	// of cells of each character
+	// Combining characters take no cells and are drawn in the cell of the
+	// character before them, so their column is the one after that cell
+	row, col, width []int
+}
+
+// layoutLine lays out a line on rows of the given number of columns, or on a
+// single row if width is 0
+// With indent the rows after the first are indented like the line, and with
+// words rows are broken after a space where possible instead of in a word
+func layoutLine(line []rune, tabsize, width int, indent, words bool) *lineLayout {
+	n := len(line)
+	l := &lineLayout{
+		rowStart: []int{0},
+		row:      make([]int, n+1),
+		col:      make([]int, n+1),
+		width:    make([]int, n+1),
+	}
+
+	// The width of a character doesn't depend on the row it is on: tabs stop
+	// at the same columns as without wrapping
+	visualX := 0
+	for i, r := range line {
+		w := 0
+		if r == '\t' {
+			w = tabsize - visualX%tabsize
+		} else {
+			w = runewidth.RuneWidth(r)
+		}
+		if w == 0 && i == 0 {
+			// There is nothing to combine the character with
+			w = 1
+		}
+		l.width[i] = w
+		visualX += w
+	}
+	l.width[n] = 1
+
+	if width <= 0 {
+		for i := 1; i <= n; i++ {
+			l.col[i] = l.col[i-1] + l.width[i-1]
+		}
+		return l
+	}
+
+	// The leading whitespace isn't broken, and is the indentation of the
+	// other rows with indent unless it leaves too little room for the text
+	lead, wrapCol := 0, 0
+	for lead < n && (line[lead] == ' ' || line[lead] == '\t') {
+		wrapCol += l.width[lead]
+		lead++
+	}
+	if !indent || wrapCol > width/2 {
+		wrapCol = 0
+	}
+
+	row, col, start := 0, 0, 0
+	for i := 0; i <= n; {
+		w := l.width[i]
+		if w > 0 && i > start && col+w > width {
+			// The character doesn't fit on the row: the next row starts
+			// with it, or with the word it is in or ends, so that spaces
+			// don't start rows
+			next := i
+			if words && i < n {
+				for j := i; j > start && j > lead; j-- {
+					if unicode.IsSpace(line[j-1]) {
+						next = j
+						break
+					}
+				}
+			}
+			row++
+			col, start = wrapCol, next
+			l.rowStart = append(l.rowStart, next)
+			i = next
+			continue
+		}
+		l.row[i] = row
+		l.col[i] = col
+		col += w
+		i++
+	}
+	return l
+}
+
+// rows returns the number of rows of the line
+func (l *lineLayout) rows() int {
+	return len(l.rowStart)
+}
+
+// pos returns the row and the column of the character x of the line, which
+// is the end of the line past its last character
+func (l *lineLayout) pos(x int) (int, int) {
+	x = Max(0, Min(x, len(l.row)-1))
+	return l.row[x], l.col[x]
+}
+
+// rowBounds returns the first and the last character of a row
+// The last character of the last row is the end of the line
+func (l *lineLayout) rowBounds(row int) (int, int) {
+	row = Max(0, Min(row, l.rows()-1))
+	if row+1 < l.rows() {
+		return l.rowStart[row], l.rowStart[row+1] - 1
+	}
+	return l.rowStart[row], len(l.row) - 1
+}
+
+// charAt returns the character of the line shown at a column of a row, or
+// the closest one on the row if no character is shown there
+func (l *lineLayout) charAt(row, col int) int {
+	if row >= l.rows() {
+		return len(l.row) - 1
+	}
+	start, end := l.rowBounds(row)
+	for x := start; x < end; x++ {
+		if l.width[x] > 0 && col < l.col[x]+l.width[x] {
+			return x
+		}
+	}
+	return end
+}
+
+// layout returns the layout of a line of the buffer in the view
+func (v *View) layout(y int) *lineLayout {
+	width := 0
+	if v.Buf.Settings["softwrap"].(bool) {
+		width = Max(v.Width-v.lineNumOffset, 1)
+	}
+	tabsize := int(v.Buf.Settings["tabsize"].(float64))
+	return layoutLine([]rune(v.Buf.Line(y)), tabsize, width,
+		v.Buf.Settings["wrapindent"].(bool), v.Buf.Settings["wordwrap"].(bool))
+}
+
+// lineRows returns the number of rows a line of the buffer takes in the view
+func (v *View) lineRows(y int) int {
+	if y >= v.Buf.NumLines || !v.Buf.Settings["softwrap"].(bool) {
+		return 1
+	}
+	return v.layout(y).rows()
+}
+
+// rowLine returns the line of the buffer and the row of that line shown on a
+// row of the view, counting from the top line
+// Below the end of the buffer it returns the last row of the last line
+func (v *View) rowLine(row int) (int, int) {
+	if !v.Buf.Settings["softwrap"].(bool) {
+		return Min(v.lineAtRow(row), v.Buf.NumLines-1), 0
+	}
+	y := v.Topline
+	row = Max(row, 0)
+	for {
+		rows := v.lineRows(y)
+		if row < rows {
+			return y, row
+		}
+		next := v.Buf.nextVisible(y)
+		if next >= v.Buf.NumLines {
+			return y, rows - 1
+		}
+		row -= rows
+		y = next
+	}
+}
+
+// screenRow returns the row of the view a location of the buffer is shown
+// on, counting from the top line, which is negative above it
+func (v *View) screenRow(loc Loc) int {
+	b := v.Buf
+	row := b.visibleIndex(loc.Y) - b.visibleIndex(v.Topline)
+	if !v.Buf.Settings["softwrap"].(bool) || row < 0 || row > v.Height {
+		// Every line takes at least a row, so this is past the bottom of
+		// the view
+		return row
+	}
+	row, _ = v.layout(loc.Y).pos(loc.X)
+	for y := v.Topline; y < loc.Y; y = b.nextVisible(y) {
+		row += v.lineRows(y)
+	}
+	return row
+}
+
+// relocateWrapped moves the top line of a soft wrapped view so that the row
+// of the cursor is in view, with scrollmargin rows around it where there are
+func (v *View) relocateWrapped() bool {
+	b := v.Buf
+	margin := Min(int(v.Buf.Settings["scrollmargin"].(float64)), (v.Height-1)/2)
+	cy := v.Cursor.Y
+	top := v.Topline
+	if b.visibleIndex(cy) < b.visibleIndex(top) {
+		top = cy
+	} else if b.visibleIndex(cy)-b.visibleIndex(top) > v.Height {
+		top = b.visibleLine(b.visibleIndex(cy) - v.Height)
+	}
+	crow, _ := v.layout(cy).pos(v.Cursor.X)
+
+	// The rows from the top of the view to the row of the cursor
+	above := crow
+	for y := top; y < cy; y = b.nextVisible(y) {
+		above += v.lineRows(y)
+	}
+	for above < margin && top > 0 {
+		top = b.moveVisible(top, -1)
+		above += v.lineRows(top)
+	}
+
+	// The rows below the row of the cursor, up to the margin
+	below := v.lineRows(cy) - 1 - crow
+	for y := b.nextVisible(cy); below < margin && y < b.NumLines; y = b.nextVisible(y) {
+		below += v.lineRows(y)
+	}
+	below = Min(below, margin)
+	for above+1+below > v.Height && top < cy {
+		above -= v.lineRows(top)
+		top = b.nextVisible(top)
+	}
+
+	ret := top != v.Topline || v.leftCol != 0
+	v.Topline = top
+	v.leftCol = 0
+	return ret
+}
+
+// moveDisplayLines moves a cursor of the view n rows down, or up if n is
+// negative, keeping its column on the screen
+// Without soft wrapping rows are lines
+func (v *View) moveDisplayLines(c *Cursor, n int) {
+	if !v.Buf.Settings["softwrap"].(bool) {
+		c.DownN(n)
+		return
+	}
+	l := v.layout(c.Y)
+	row, col := l.pos(c.X)
+	if v.wrapColLoc == c.Loc {
+		// The cursor came here from a longer row
+		col = v.wrapCol
+	}
+	y := c.Y
+	row += n
+	for row < 0 {
+		prev := v.Buf.moveVisible(y, -1)
+		if prev == y {
+			row = 0
+			break
+		}
+		y = prev
+		l = v.layout(y)
+		row += l.rows()
+	}
+	for row >= l.rows() {
+		next := v.Buf.moveVisible(y, 1)
+		if next == y {
+			row = l.rows() - 1
+			break
+		}
+		row -= l.rows()
+		y = next
+		l = v.layout(y)
+	}
+	c.Y, c.X = y, l.charAt(row, col)
+	c.LastVisualX = c.GetVisualX()
+	v.wrapCol, v.wrapColLoc = col, c.Loc
+}
+
+// displayLineBounds returns the first and the last character of the row a
+// cursor of the view is on
+func (v *View) displayLineBounds(c *Cursor) (int, int) {
+	l := v.layout(c.Y)
+	row, _ := l.pos(c.X)
+	return l.rowBounds(row)
+}
+
+// StartOfDisplayLine moves the cursor to the start of the row it is on, which
+// is the start of the line without soft wrapping
+func (v *View) StartOfDisplayLine(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("StartOfDisplayLine", v) {
+		return false
+	}
+
+	v.deselect(0)
+	v.Cursor.X, _ = v.displayLineBounds(v.Cursor)
+	v.Cursor.LastVisualX = v.Cursor.GetVisualX()
+
+	if usePlugin {
+		return PostActionCall("StartOfDisplayLine", v)
+	}
+	return true
+}
+
+// EndOfDisplayLine moves the cursor to the end of the row it is on, which is
+// the end of the line without soft wrapping
+func (v *View) EndOfDisplayLine(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("EndOfDisplayLine", v) {
+		return false
+	}
+
+	v.deselect(1)
+	_, v.Cursor.X = v.displayLineBounds(v.Cursor)
+	v.Cursor.LastVisualX = v.Cursor.GetVisualX()
+
+	if usePlugin {
+		return PostActionCall("EndOfDisplayLine", v)
+	}
+	return true
+}
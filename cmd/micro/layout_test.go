@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLayoutLine(t *testing.T) {
+	tests := []struct {
+		line          string
+		width         int
+		indent, words bool
+		rowStart      []int
+		// The row and column of every character and of the end of the line
+		pos [][2]int
+	}{
+		{"abcdef", 0, false, false, []int{0},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}, {0, 5}, {0, 6}}},
+		{"abcdef", 4, false, false, []int{0, 4},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {1, 0}, {1, 1}, {1, 2}}},
+		{"abcd", 4, false, false, []int{0, 4},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {1, 0}}},
+		{"ab cdef", 5, false, true, []int{0, 3},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}, {1, 3}, {1, 4}}},
+		{"ab cdef", 5, false, false, []int{0, 5},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 0}, {1, 1}, {1, 2}}},
+		{"ab cd ef", 5, false, true, []int{0, 3, 8},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}, {1, 3}, {1, 4}, {2, 0}}},
+		{"abcdefg", 5, false, true, []int{0, 5},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 0}, {1, 1}, {1, 2}}},
+		{"a世界b", 4, false, false, []int{0, 2},
+			[][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 2}, {1, 3}}},
+		{"\tx", 0, false, false, []int{0},
+			[][2]int{{0, 0}, {0, 4}, {0, 5}}},
+		{"x\ty", 0, false, false, []int{0},
+			[][2]int{{0, 0}, {0, 1}, {0, 4}, {0, 5}}},
+		{"e\u0301x", 0, false, false, []int{0},
+			[][2]int{{0, 0}, {0, 1}, {0, 1}, {0, 2}}},
+		{"  abcdef", 6, true, false, []int{0, 6},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}, {0, 5}, {1, 2}, {1, 3}, {1, 4}}},
+		{"    abc", 6, true, false, []int{0, 6},
+			[][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {0, 4}, {0, 5}, {1, 0}, {1, 1}}},
+	}
+	for _, test := range tests {
+		l := layoutLine([]rune(test.line), 4, test.width, test.indent, test.words)
+		if !reflect.DeepEqual(l.rowStart, test.rowStart) {
+			t.Errorf("layoutLine(%q, %d): rows start at %v, want %v", test.line, test.width, l.rowStart, test.rowStart)
+		}
+		for x, want := range test.pos {
+			if row, col := l.pos(x); row != want[0] || col != want[1] {
+				t.Errorf("layoutLine(%q, %d): character %d at %d,%d, want %d,%d", test.line, test.width, x, row, col, want[0], want[1])
+			}
+		}
+	}
+}
+
+func TestLayoutCharAt(t *testing.T) {
+	tests := []struct {
+		line     string
+		width    int
+		row, col int
+		want     int
+	}{
+		// The two cells of a wide character
+		{"a世界b", 0, 0, 1, 1},
+		{"a世界b", 0, 0, 2, 1},
+		{"a世界b", 0, 0, 3, 2},
+		// Past the end of a wrapped row is its last character
+		{"a世界b", 4, 0, 3, 1},
+		{"a世界b", 4, 1, 2, 3},
+		{"a世界b", 4, 1, 9, 4},
+		{"a世界b", 4, 5, 0, 4},
+		// The cells of a tab
+		{"x\ty", 0, 0, 3, 1},
+		{"x\ty", 0, 0, 4, 2},
+		// A combining character is skipped
+		{"e\u0301x", 0, 0, 1, 2},
+		// The indentation of a wrapped row
+		{"  abcdef", 6, 1, 0, 6},
+	}
+	for _, test := range tests {
+		l := layoutLine([]rune(test.line), 4, test.width, true, false)
+		if got := l.charAt(test.row, test.col); got != test.want {
+			t.Errorf("charAt(%d, %d) of %q: got %d, want %d", test.row, test.col, test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A MacroStep is one step of a macro: either a bound action or text which was typed
+type MacroStep struct {
+	// The name of the action as used in bindings.json
+	Action string
+	Text   string
+}
+
+// A Macro is a recorded sequence of actions and typed text
+//
+// Macros are stored in configDir/macros with one step per line. A line is either
+// the name of an action, or a quoted string of text to insert. Empty lines and
+// lines starting with # are ignored:
+//
+//	# Wrap the line in parentheses
+//	StartOfLine
+//	"("
+//	EndOfLine
+//	")"
+type Macro []MacroStep
+
+// macroPrefix is used in bindings.json to bind a key to a saved macro
+const macroPrefix = "Macro:"
+
+// addAction records an action in the macro
+func (m *Macro) addAction(name string) {
+	*m = append(*m, MacroStep{Action: name})
+}
+
+// addText records typed text in the macro, joining it with text typed just before
+func (m *Macro) addText(text string) {
+	if n := len(*m); n > 0 && (*m)[n-1].Action == "" {
+		(*m)[n-1].Text += text
+		return
+	}
+	*m = append(*m, MacroStep{Text: text})
+}
+
+// String returns the macro in its textual format
+func (m Macro) String() string {
+	var lines []string
+	for _, step := range m {
+		if step.Action != "" {
+			lines = append(lines, step.Action)
+		} else {
+			lines = append(lines, strconv.Quote(step.Text))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ParseMacro reads a macro in its textual format
+func ParseMacro(text string) (Macro, error) {
+	var m Macro
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "\""):
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, errors.New("line " + strconv.Itoa(i+1) + ": invalid text " + line)
+			}
+			m.addText(s)
+		case strings.ContainsAny(line, " \t"):
+			return nil, errors.New("line " + strconv.Itoa(i+1) + ": invalid action " + line)
+		default:
+			m.addAction(line)
+		}
+	}
+	return m, nil
+}
+
+// macroPath returns the file the macro with the given name is stored in
+func macroPath(name string) string {
+	return filepath.Join(configDir, "macros", name)
+}
+
+// LoadMacro reads the saved macro with the given name
+func LoadMacro(name string) (Macro, error) {
+	if err := checkConfigName(name); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(macroPath(name))
+	if err != nil {
+		return nil, err
+	}
+	return ParseMacro(string(data))
+}
+
+// SaveMacro saves the macro under the given name
+func SaveMacro(name string, m Macro) error {
+	if err := checkConfigName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(macroPath(name)), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(macroPath(name), []byte(m.String()), 0644)
+}
+
+// ListMacros returns the names of the saved macros
+func ListMacros() []string {
+	files, _ := ioutil.ReadDir(filepath.Join(configDir, "macros"))
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Play runs the macro once in the given view
+func (m Macro) Play(v *View) {
+	for _, step := range m {
+		if step.Action != "" {
+			findAction(step.Action)(v, true)
+			continue
+		}
+		for _, r := range step.Text {
+			// Insert a character
+			if v.Cursor.HasSelection() {
+				v.Cursor.DeleteSelection()
+				v.Cursor.ResetSelection()
+			}
+			v.Buf.Insert(v.Cursor.Loc, string(r))
+			v.Cursor.Right()
+
+			for pl := range loadedPlugins {
+				_, err := Call(pl+".onRune", string(r), v)
+				if err != nil && !strings.HasPrefix(err.Error(), "function does not exist") {
+					TermMessage(err)
+				}
+			}
+		}
+	}
+}
+
+// PlayLines runs the macro once at the start of every line of the selection
+// The lines are visited from the bottom up so lines added or removed by the
+// macro don't change which lines are left to visit
+func (m Macro) PlayLines(v *View) {
+	if !v.Cursor.HasSelection() {
+		m.Play(v)
+		return
+	}
+	start, end := v.Cursor.CurSelection[0], v.Cursor.CurSelection[1]
+	if start.GreaterThan(end) {
+		start, end = end, start
+	}
+	last := end.Y
+	if end.X == 0 && end.Y > start.Y {
+		// A selection ending at the start of a line doesn't include that line
+		last--
+	}
+	v.Cursor.ResetSelection()
+	for y := last; y >= start.Y; y-- {
+		if y >= v.Buf.NumLines {
+			continue
+		}
+		v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, y, 0
+		m.Play(v)
+	}
+}
+
+// macroBinding returns an action which plays the saved macro with the given name
+// so that it can be bound to a key
+func macroBinding(name string) func(*View, bool) bool {
+	return func(v *View, usePlugin bool) bool {
+		m, err := LoadMacro(name)
+		if err != nil {
+			messenger.Error("Could not load macro ", name, ": ", err)
+			return false
+		}
+		m.Play(v)
+		return true
+	}
+}
+
+// RunMacro plays the saved macro with the given name count times in the current view
+func RunMacro(name string, count int) error {
+	m, err := LoadMacro(name)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		m.Play(CurView())
+	}
+	return nil
+}
+
+// macroArgs returns the macro named by the first argument, or the last recorded
+// macro if there is no name, along with the remaining arguments
+func macroArgs(args []string) (Macro, []string, bool) {
+	if len(args) == 0 || isNumber(args[0]) {
+		if len(curMacro) == 0 {
+			messenger.Error("No macro has been recorded")
+			return nil, nil, false
+		}
+		return curMacro, args, true
+	}
+	m, err := LoadMacro(args[0])
+	if err != nil {
+		messenger.Error("Could not load macro ", args[0], ": ", err)
+		return nil, nil, false
+	}
+	return m, args[1:], true
+}
+
+// isNumber returns whether the string is a positive integer
+func isNumber(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0
+}
+
+// MacroCmd saves, plays, edits, deletes or lists named macros
+func MacroCmd(args []string) {
+	if len(args) == 0 {
+		messenger.Error("Not enough arguments")
+		return
+	}
+	v := CurView()
+	switch args[0] {
+	case "list":
+		names := ListMacros()
+		if len(names) == 0 {
+			messenger.Message("No saved macros")
+		} else {
+			messenger.Message("Macros: ", strings.Join(names, " "))
+		}
+	case "save":
+		if len(args) < 2 {
+			messenger.Error("Not enough arguments")
+			return
+		}
+		if len(curMacro) == 0 {
+			messenger.Error("No macro has been recorded")
+			return
+		}
+		if err := SaveMacro(args[1], curMacro); err != nil {
+			messenger.Error("Could not save macro: ", err)
+			return
+		}
+		messenger.Message("Saved macro ", args[1])
+	case "play":
+		m, rest, ok := macroArgs(args[1:])
+		if !ok {
+			return
+		}
+		count := 1
+		if len(rest) > 0 {
+			if !isNumber(rest[0]) {
+				messenger.Error("Invalid count ", rest[0])
+				return
+			}
+			count, _ = strconv.Atoi(rest[0])
+		}
+		for i := 0; i < count; i++ {
+			m.Play(v)
+		}
+	case "lines":
+		m, _, ok := macroArgs(args[1:])
+		if !ok {
+			return
+		}
+		m.PlayLines(v)
+	case "edit":
+		if len(args) < 2 {
+			messenger.Error("Not enough arguments")
+			return
+		}
+		if err := checkConfigName(args[1]); err != nil {
+			messenger.Error(err)
+			return
+		}
+		if _, err := os.Stat(macroPath(args[1])); os.IsNotExist(err) {
+			// Start the new macro from the last recorded one
+			if err := SaveMacro(args[1], curMacro); err != nil {
+				messenger.Error("Could not create macro: ", err)
+				return
+			}
+		}
+		buf, err := openFileBuffer(macroPath(args[1]))
+		if err != nil {
+			messenger.Error(err)
+			return
+		}
+		AddTabWithBuffer(buf)
+	case "delete":
+		if len(args) < 2 {
+			messenger.Error("Not enough arguments")
+			return
+		}
+		if err := checkConfigName(args[1]); err != nil {
+			messenger.Error(err)
+			return
+		}
+		if err := os.Remove(macroPath(args[1])); err != nil {
+			messenger.Error("Could not delete macro: ", err)
+			return
+		}
+		messenger.Message("Deleted macro ", args[1])
+	default:
+		messenger.Error("Unknown macro command ", args[0])
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMacro(t *testing.T) {
+	text := "# Wrap the line in parentheses\nStartOfLine\n\"(\"\n\n  EndOfLine\n\"a \\\"b\\\"\\n\"\n\"c\"\n"
+	want := Macro{{Action: "StartOfLine"}, {Text: "("}, {Action: "EndOfLine"}, {Text: "a \"b\"\nc"}}
+	m, err := ParseMacro(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("ParseMacro = %v, want %v", m, want)
+	}
+
+	// The textual format reads back the same macro
+	if again, err := ParseMacro(m.String()); err != nil || !reflect.DeepEqual(again, m) {
+		t.Errorf("ParseMacro(%q) = %v, %v", m.String(), again, err)
+	}
+
+	for _, bad := range []string{"\"unterminated\n", "Cursor Up\n"} {
+		if _, err := ParseMacro(bad); err == nil {
+			t.Errorf("ParseMacro(%q) should fail", bad)
+		}
+	}
+}
+
+func TestMacroRecord(t *testing.T) {
+	var m Macro
+	m.addText("a")
+	m.addText("b")
+	m.addAction("InsertNewline")
+	m.addText("c")
+	want := Macro{{Text: "ab"}, {Action: "InsertNewline"}, {Text: "c"}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("recorded %v, want %v", m, want)
+	}
+}
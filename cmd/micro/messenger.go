@@ -8,7 +8,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/zyedidia/clipboard"
 	"github.com/zyedidia/tcell"
 )
 
@@ -81,6 +80,7 @@ func (m *Messenger) getBuffer() *Buffer {
 	if m.log == nil {
 		m.log = NewBuffer(strings.NewReader(""), "")
 		m.log.name = "Log"
+		unlistBuffer(m.log)
 	}
 	return m.log
 }
@@ -171,7 +171,8 @@ func (m *Messenger) YesNoPrompt(prompt string) (bool, bool) {
 					m.hasPrompt = false
 					return false, false
 				}
-			case tcell.KeyCtrlC, tcell.KeyCtrlQ, tcell.KeyEscape:
+			}
+			if hasAction(contextActions("prompt", e), "Cancel") {
 				m.AddLog("\t--> (cancel)")
 				m.hasPrompt = false
 				return false, true
@@ -206,7 +207,8 @@ func (m *Messenger) LetterPrompt(prompt string, responses ...rune) (rune, bool)
 						return r, false
 					}
 				}
-			case tcell.KeyCtrlC, tcell.KeyCtrlQ, tcell.KeyEscape:
+			}
+			if hasAction(contextActions("prompt", e), "Cancel") {
 				m.AddLog("\t--> (cancel)")
 				m.Clear()
 				m.Reset()
@@ -227,6 +229,10 @@ const (
 	OptionCompletion
 	PluginCmdCompletion
 	PluginNameCompletion
+	SessionCmdCompletion
+	SessionNameCompletion
+	MacroCmdCompletion
+	MacroNameCompletion
 )
 
 // Prompt sends the user a message and waits for a response to be typed in
@@ -254,18 +260,19 @@ func (m *Messenger) Prompt(prompt, placeholder, historyType string, completionTy
 
 		switch e := event.(type) {
 		case *tcell.EventKey:
-			switch e.Key() {
-			case tcell.KeyCtrlQ, tcell.KeyCtrlC, tcell.KeyEscape:
+			names := contextActions("prompt", e)
+			switch {
+			case hasAction(names, "Cancel"):
 				// Cancel
 				m.AddLog("\t--> (cancel)")
 				m.hasPrompt = false
-			case tcell.KeyEnter:
+			case hasAction(names, "Accept"):
 				// User is done entering their response
 				m.AddLog("\t--> " + m.response)
 				m.hasPrompt = false
 				response, canceled = m.response, false
 				m.history[historyType][len(m.history[historyType])-1] = response
-			case tcell.KeyTab:
+			case hasAction(names, "Complete"):
 				args := SplitCommandArgs(m.response)
 				currentArgNum := len(args) - 1
 				currentArg := args[currentArgNum]
@@ -296,6 +303,14 @@ func (m *Messenger) Prompt(prompt, placeholder, historyType string, completionTy
 					chosen, suggestions = PluginCmdComplete(currentArg)
 				} else if completionType == PluginNameCompletion {
 					chosen, suggestions = PluginNameComplete(currentArg)
+				} else if completionType == SessionCmdCompletion {
+					chosen, suggestions = SessionCmdComplete(currentArg)
+				} else if completionType == SessionNameCompletion {
+					chosen, suggestions = SessionNameComplete(currentArg)
+				} else if completionType == MacroCmdCompletion {
+					chosen, suggestions = MacroCmdComplete(currentArg)
+				} else if completionType == MacroNameCompletion {
+					chosen, suggestions = MacroNameComplete(currentArg)
 				} else if completionType < NoCompletion {
 					chosen, suggestions = PluginComplete(completionType, currentArg)
 				}
@@ -330,63 +345,39 @@ func (m *Messenger) Prompt(prompt, placeholder, historyType string, completionTy
 	return response, canceled
 }
 
+// promptActions are the actions of the prompt keymap context which move through
+// the history, the other actions are handled by Prompt or are text actions
+var promptActions = map[string]func(m *Messenger, history []string){
+	"CursorUp": func(m *Messenger, history []string) {
+		if m.historyNum > 0 {
+			m.historyNum--
+			m.response = history[m.historyNum]
+			m.cursorx = Count(m.response)
+		}
+	},
+	"CursorDown": func(m *Messenger, history []string) {
+		if m.historyNum < len(history)-1 {
+			m.historyNum++
+			m.response = history[m.historyNum]
+			m.cursorx = Count(m.response)
+		}
+	},
+}
+
 // HandleEvent handles an event for the prompter
 func (m *Messenger) HandleEvent(event tcell.Event, history []string) {
 	switch e := event.(type) {
 	case *tcell.EventKey:
-		if e.Key() != tcell.KeyRune || e.Modifiers() != 0 {
-			for key, actions := range bindings {
-				if e.Key() == key.keyCode {
-					if e.Key() == tcell.KeyRune {
-						if e.Rune() != key.r {
-							continue
-						}
-					}
-					if e.Modifiers() == key.modifiers {
-						for _, action := range actions {
-							funcName := FuncName(action)
-							switch funcName {
-							case "main.(*View).CursorUp":
-								if m.historyNum > 0 {
-									m.historyNum--
-									m.response = history[m.historyNum]
-									m.cursorx = Count(m.response)
-								}
-							case "main.(*View).CursorDown":
-								if m.historyNum < len(history)-1 {
-									m.historyNum++
-									m.response = history[m.historyNum]
-									m.cursorx = Count(m.response)
-								}
-							case "main.(*View).CursorLeft":
-								if m.cursorx > 0 {
-									m.cursorx--
-								}
-							case "main.(*View).CursorRight":
-								if m.cursorx < Count(m.response) {
-									m.cursorx++
-								}
-							case "main.(*View).CursorStart", "main.(*View).StartOfLine":
-								m.cursorx = 0
-							case "main.(*View).CursorEnd", "main.(*View).EndOfLine":
-								m.cursorx = Count(m.response)
-							case "main.(*View).Backspace":
-								if m.cursorx > 0 {
-									m.response = string([]rune(m.response)[:m.cursorx-1]) + string([]rune(m.response)[m.cursorx:])
-									m.cursorx--
-								}
-							case "main.(*View).Paste":
-								clip, _ := clipboard.ReadAll("clipboard")
-								m.response = Insert(m.response, m.cursorx, clip)
-								m.cursorx += Count(clip)
-							}
-						}
-					}
-				}
+		names := contextActions("prompt", e)
+		if names == nil {
+			names = globalActions(e)
+		}
+		for _, name := range names {
+			if action, ok := promptActions[name]; ok {
+				action(m, history)
 			}
 		}
-		switch e.Key() {
-		case tcell.KeyRune:
+		if !editText(names, &m.response, &m.cursorx) && e.Key() == tcell.KeyRune {
 			m.response = Insert(m.response, m.cursorx, string(e.Rune()))
 			m.cursorx++
 		}
@@ -490,3 +481,18 @@ const (
 	// GutterError represents a compiler error
 	GutterError
 )
+
+// gutterMessageStyle returns the style of the markers of a kind of gutter message
+func gutterMessageStyle(kind int) tcell.Style {
+	group := "gutter-info"
+	switch kind {
+	case GutterWarning:
+		group = "gutter-warning"
+	case GutterError:
+		group = "gutter-error"
+	}
+	if style, ok := colorscheme[group]; ok {
+		return style
+	}
+	return defStyle
+}
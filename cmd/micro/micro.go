@@ -38,6 +38,10 @@ var (
 	autocomplete *AutocompletionBox
 	// Object to handle templates
 	template *TemplateBox
+	// The popup with the documentation of an identifier
+	hover *HoverBox
+	// The parameters of the function being called
+	signatureBox *SignatureBox
 
 	// Object to send messages and prompts to the user
 	messenger *Messenger
@@ -51,8 +55,6 @@ var (
 	// If $XDG_CONFIG_HOME is not set, it is ~/.config/micro
 	configDir string
 
-	cursorLocations CursorLocations
-
 	// Version is the version number or commit hash
 	// These variables should be set by the linker when compiling
 	Version = "0.0.0-unknown"
@@ -213,10 +215,12 @@ func InitScreen() {
 // RedrawAll redraws everything -- all the views and the messenger
 func RedrawAll() {
 	messenger.Clear()
-	for _, v := range tabs[curTab].views {
+	for _, v := range tabs[curTab].shownViews() {
 		v.Display()
 		autocomplete.Display(v)
 	}
+	signatureBox.Display()
+	hover.Display()
 	DisplayTabs()
 	messenger.Display()
 	screen.Show()
@@ -250,6 +254,7 @@ func LoadAll() {
 // Passing -version as a flag will have micro print out the version number
 var flagVersion = flag.Bool("version", false, "Show the version number and information")
 var flagStartPos = flag.String("startpos", "", "LINE,COL to start the cursor at when opening a buffer.")
+var flagSession = flag.String("session", "", "Restore the tabs and splits of the session with the given name.")
 
 func main() {
 	flag.Usage = func() {
@@ -317,24 +322,40 @@ func main() {
 
 	autocomplete = new(AutocompletionBox)
 	template = new(TemplateBox)
+	hover = new(HoverBox)
+	signatureBox = new(SignatureBox)
+
+	loadBookmarks()
+
+	// Now we load the input, unless a session is restored instead
+	// The files given along with -session are opened after the tabs of the
+	// session, and the first of them is shown
+	restored := RestoreStartupSession()
+	if !restored || len(flag.Args()) > 0 {
+		buffers := LoadInput()
+		if len(buffers) == 0 && !restored {
+			screen.Fini()
+			os.Exit(1)
+		}
+		first := len(tabs)
+		if restored && len(buffers) > 0 {
+			curTab = first
+		}
+		for _, buf := range buffers {
+			// For each buffer we create a new tab and place the view in that tab
+			tab := NewTabFromView(NewView(buf))
+			tab.SetNum(len(tabs))
+			tabs = append(tabs, tab)
+			for _, t := range tabs[first:] {
+				for _, v := range t.views {
+					v.Center(false)
+				}
 
-	// Now we load the input
-	buffers := LoadInput()
-	if len(buffers) == 0 {
-		screen.Fini()
-		os.Exit(1)
-	}
-	for _, buf := range buffers {
-		// For each buffer we create a new tab and place the view in that tab
-		tab := NewTabFromView(NewView(buf))
-		tab.SetNum(len(tabs))
-		tabs = append(tabs, tab)
-		for _, t := range tabs {
-			for _, v := range t.views {
-				v.Center(false)
+				t.Resize()
 			}
-
-			t.Resize()
+		}
+		if !restored {
+			loadJumpList()
 		}
 	}
 
@@ -356,6 +377,7 @@ func main() {
 	L.SetGlobal("SetLocalOption", luar.New(L, SetLocalOption))
 	L.SetGlobal("BindKey", luar.New(L, BindKey))
 	L.SetGlobal("MakeCommand", luar.New(L, MakeCommand))
+	L.SetGlobal("SetCommandDescription", luar.New(L, SetCommandDescription))
 	L.SetGlobal("CurView", luar.New(L, CurView))
 	L.SetGlobal("IsWordChar", luar.New(L, IsWordChar))
 	L.SetGlobal("HandleCommand", luar.New(L, HandleCommand))
@@ -375,6 +397,11 @@ func main() {
 	L.SetGlobal("Reload", luar.New(L, LoadAll))
 	L.SetGlobal("ByteOffset", luar.New(L, ByteOffset))
 	L.SetGlobal("ToCharPos", luar.New(L, ToCharPos))
+	L.SetGlobal("RunMacro", luar.New(L, RunMacro))
+	L.SetGlobal("AddBookmark", luar.New(L, AddBookmark))
+	L.SetGlobal("GetBookmark", luar.New(L, GetBookmark))
+	L.SetGlobal("RemoveBookmark", luar.New(L, RemoveBookmark))
+	L.SetGlobal("ListBookmarks", luar.New(L, ListBookmarks))
 
 	// Used for asynchronous jobs
 	L.SetGlobal("JobStart", luar.New(L, JobStart))
@@ -467,8 +494,8 @@ func main() {
 					if CurView().mouseReleased {
 						// We loop through each view in the current tab and make sure the current view
 						// is the one being clicked in
-						for _, v := range tabs[curTab].views {
-							if x >= v.x && x < v.x+v.Width && y >= v.y && y < v.y+v.Height {
+						for _, v := range tabs[curTab].shownViews() {
+							if x >= v.x && x < v.x+v.Width+v.mapWidth && y >= v.y && y < v.y+v.Height {
 								tabs[curTab].CurView = v.Num
 							}
 						}
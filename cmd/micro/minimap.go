@@ -0,0 +1,268 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/zyedidia/tcell"
+)
+
+const (
+	// minimapWidth is the number of columns of the minimap: one for the git
+	// markers, the compressed text, and one for the diagnostics and the lines
+	// which match the last search
+	minimapWidth = 12
+	// minimapScale is the number of columns of text shown by a cell of the
+	// minimap
+	minimapScale = 3
+)
+
+// minimapState holds the lines of the buffer which match the last search, and
+// whether the minimap is being dragged
+type minimapState struct {
+	search  string
+	version int
+	lines   map[int]bool
+
+	dragging bool
+}
+
+// minimapColumns returns the width of the minimap of the view in a split of
+// the given width, which is 0 if the minimap is off or the split is too
+// narrow for it
+func (v *View) minimapColumns(width int) int {
+	if v.Type == vtScratch || !v.Buf.Settings["minimap"].(bool) || width < 3*minimapWidth {
+		return 0
+	}
+	return minimapWidth
+}
+
+// minimapStep returns the number of lines shown on each row of the minimap, so
+// that the whole buffer fits in it
+// The lines hidden by folds are left out
+func (v *View) minimapStep() int {
+	if v.Height < 1 {
+		return 1
+	}
+	return Max(1, (v.Buf.visibleLines()+v.Height-1)/v.Height)
+}
+
+// minimapRow returns the row of the minimap a line is shown on
+func (v *View) minimapRow(line int) int {
+	return v.Buf.visibleIndex(line) / v.minimapStep()
+}
+
+// lineStyles returns the syntax style of each rune of a line, matching the
+// rules on that line alone
+func lineStyles(rules []SyntaxRule, line string) []tcell.Style {
+	styles := make([]tcell.Style, Count(line))
+	for i := range styles {
+		styles[i] = defStyle
+	}
+	for _, rule := range rules {
+		for _, m := range rule.regex.FindAllStringIndex(line, -1) {
+			for i := runePos(m[0], line); i < runePos(m[1], line); i++ {
+				styles[i] = rule.style
+			}
+		}
+	}
+	return styles
+}
+
+// minimapSearchLines returns the lines of the buffer which match the last
+// search
+func (v *View) minimapSearchLines() map[int]bool {
+	if lastSearch == "" {
+		return nil
+	}
+	search := lastSearch
+	if v.Buf.Settings["ignorecase"].(bool) {
+		search = "(?i)" + search
+	}
+	m := &v.minimap
+	if m.lines != nil && m.search == search && m.version == v.Buf.version {
+		return m.lines
+	}
+	r, err := regexp.Compile(search)
+	if err != nil {
+		return nil
+	}
+	m.lines = make(map[int]bool)
+	for i := 0; i < v.Buf.NumLines; i++ {
+		if r.MatchString(v.Buf.Line(i)) {
+			m.lines[i] = true
+		}
+	}
+	m.search, m.version = search, v.Buf.version
+	return m.lines
+}
+
+// minimapStyle returns the style of the rows of the minimap which show the
+// visible part of the buffer
+func minimapStyle() tcell.Style {
+	if style, ok := colorscheme["minimap"]; ok {
+		return style
+	}
+	if style, ok := colorscheme["cursor-line"]; ok {
+		fg, _, _ := style.Decompose()
+		return defStyle.Background(fg)
+	}
+	return defStyle.Reverse(true)
+}
+
+// minimapSearchStyle returns the style of the markers of the lines which match
+// the last search
+func minimapSearchStyle() tcell.Style {
+	if style, ok := colorscheme["minimap-search"]; ok {
+		return style
+	}
+	return defStyle.Foreground(tcell.ColorYellow)
+}
+
+// withForeground returns a style with the foreground color of another style,
+// unless that one is the default color
+func withForeground(style, from tcell.Style) tcell.Style {
+	if fg, _, _ := from.Decompose(); fg != tcell.ColorDefault {
+		return style.Foreground(fg)
+	}
+	return style
+}
+
+// DisplayMinimap draws the minimap on the right of the view: the compressed
+// text of the whole buffer with the rows in view highlighted, the git markers
+// on the left, and the diagnostics and the matches of the last search on the
+// right
+func (v *View) DisplayMinimap() {
+	if v.mapWidth == 0 {
+		return
+	}
+
+	step := v.minimapStep()
+	numLines := v.Buf.visibleLines()
+	top := v.Buf.visibleIndex(v.Topline)
+	bottom := v.Buf.visibleIndex(v.Bottomline())
+
+	// The line of each row with a git marker, and the most severe diagnostic
+	// of each row
+	gitLines := make(map[int]int)
+	if v.hasGitGutter() {
+		for _, h := range v.Buf.git.hunks {
+			end := h.End()
+			if h.Kind == HunkDeleted {
+				end = h.Start + 1
+			}
+			for line := h.Start; line < end && line < v.Buf.NumLines; line++ {
+				gitLines[v.minimapRow(line)] = line
+			}
+		}
+	}
+	diagnostics := make(map[int]int)
+	for _, msgs := range v.messages {
+		for _, msg := range msgs {
+			row := v.minimapRow(msg.lineNum)
+			if kind, ok := diagnostics[row]; !ok || msg.kind > kind {
+				diagnostics[row] = msg.kind
+			}
+		}
+	}
+	searchRows := make(map[int]bool)
+	for line := range v.minimapSearchLines() {
+		searchRows[v.minimapRow(line)] = true
+	}
+
+	tabSize := int(v.Buf.Settings["tabsize"].(float64))
+	x := v.x + v.Width
+	for row := 0; row < v.Height; row++ {
+		y := v.y + row
+		first := row * step
+
+		style := defStyle
+		if first < bottom && first+step > top && first < numLines {
+			style = minimapStyle()
+		}
+		for i := 0; i < v.mapWidth; i++ {
+			screen.SetContent(x+i, y, ' ', nil, style)
+		}
+		if first >= numLines {
+			continue
+		}
+
+		if line, ok := gitLines[row]; ok {
+			_, gitStyle := v.gitGutterCell(line)
+			screen.SetContent(x, y, '▎', nil, withForeground(style, gitStyle))
+		}
+
+		// The first line of the row which isn't blank stands for the row
+		lineN := v.Buf.visibleLine(first)
+		for i := 1; i < step && first+i < numLines && IsSpacesOrTabs(v.Buf.Line(lineN)); i++ {
+			lineN = v.Buf.visibleLine(first + i)
+		}
+		line := v.Buf.Line(lineN)
+		var styles []tcell.Style
+		if v.Buf.Settings["syntax"].(bool) {
+			styles = lineStyles(v.Buf.rules, line)
+		}
+		col, lastCell := 0, -1
+		for i, ch := range []rune(line) {
+			cell := col / minimapScale
+			if ch == '\t' {
+				col += tabSize - col%tabSize
+			} else {
+				col += StringWidth(string(ch), tabSize)
+			}
+			if cell >= v.mapWidth-2 {
+				break
+			}
+			if ch == ' ' || ch == '\t' || cell == lastCell {
+				continue
+			}
+			cellStyle := style
+			if styles != nil {
+				cellStyle = withForeground(style, styles[i])
+			}
+			screen.SetContent(x+1+cell, y, '▬', nil, cellStyle)
+			lastCell = cell
+		}
+
+		if kind, ok := diagnostics[row]; ok {
+			screen.SetContent(x+v.mapWidth-1, y, '■', nil, withForeground(style, gutterMessageStyle(kind)))
+		} else if searchRows[row] {
+			screen.SetContent(x+v.mapWidth-1, y, '■', nil, withForeground(style, minimapSearchStyle()))
+		}
+	}
+}
+
+// minimapScroll scrolls the view so that the lines of a row of the minimap are
+// in the middle of it
+func (v *View) minimapScroll(row int) {
+	step := v.minimapStep()
+	row = Max(0, Min(row, v.Height-1))
+	i := row*step + step/2 - v.Height/2
+	i = Max(0, Min(i, v.Buf.visibleLines()-v.Height))
+	v.Topline = v.Buf.visibleLine(i)
+}
+
+// minimapMouse scrolls the view when the minimap is clicked or dragged, and
+// returns whether the mouse event was for the minimap
+func (v *View) minimapMouse(e *tcell.EventMouse) bool {
+	x, y := e.Position()
+	m := &v.minimap
+	switch e.Buttons() {
+	case tcell.Button1:
+		if !m.dragging {
+			if !v.mouseReleased || v.mapWidth == 0 || x < v.x+v.Width || x >= v.x+v.Width+v.mapWidth || y < v.y || y >= v.y+v.Height {
+				return false
+			}
+			m.dragging = true
+			v.mouseReleased = false
+		}
+		v.minimapScroll(y - v.y)
+		return true
+	case tcell.ButtonNone:
+		if m.dragging {
+			m.dragging = false
+			v.mouseReleased = true
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/zyedidia/tcell"
+)
+
+func TestLineStyles(t *testing.T) {
+	str := defStyle.Foreground(tcell.ColorRed)
+	comment := defStyle.Foreground(tcell.ColorBlue)
+	rules := []SyntaxRule{
+		{regex: regexp.MustCompile(`"[^"]*"`), style: str},
+		{regex: regexp.MustCompile(`//.*`), style: comment},
+	}
+	styles := lineStyles(rules, `é "a" // "b"`)
+	want := []tcell.Style{defStyle, defStyle, str, str, str, defStyle, comment, comment, comment, comment, comment, comment}
+	if len(styles) != len(want) {
+		t.Fatalf("lineStyles: got %d styles, want %d", len(styles), len(want))
+	}
+	for i := range want {
+		if styles[i] != want[i] {
+			t.Errorf("lineStyles: rune %d has the wrong style", i)
+		}
+	}
+}
+
+func TestMinimapRows(t *testing.T) {
+	b := &Buffer{NumLines: 100, Settings: map[string]interface{}{"minimap": true}}
+	v := &View{Buf: b, Height: 30}
+
+	if step := v.minimapStep(); step != 4 {
+		t.Errorf("minimapStep: got %d, want 4", step)
+	}
+	b.folds.closed = []Fold{{10, 49}}
+	if step := v.minimapStep(); step != 3 {
+		t.Errorf("minimapStep with a fold: got %d, want 3", step)
+	}
+	for line, want := range map[int]int{0: 0, 9: 3, 10: 3, 50: 3, 99: 20} {
+		if row := v.minimapRow(line); row != want {
+			t.Errorf("minimapRow(%d): got %d, want %d", line, row, want)
+		}
+	}
+
+	if w := v.minimapColumns(80); w != minimapWidth {
+		t.Errorf("minimapColumns(80): got %d", w)
+	}
+	if w := v.minimapColumns(20); w != 0 {
+		t.Errorf("minimapColumns(20): got %d", w)
+	}
+	v.Type = vtScratch
+	if w := v.minimapColumns(80); w != 0 {
+		t.Errorf("minimapColumns of a scratch view: got %d", w)
+	}
+}
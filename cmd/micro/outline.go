@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Symbol is a declaration listed in the outline of a file
+type Symbol struct {
+	Name string
+	// What was declared, such as func, method or type
+	Kind string
+	// The symbol this one is declared in, such as the receiver of a method
+	Parent string
+	// The position of the name
+	Loc Loc
+	// The nesting level in the outline tree
+	Depth int
+}
+
+// FullName returns the name of the symbol qualified by its parent
+func (s Symbol) FullName() string {
+	if s.Parent != "" {
+		return s.Parent + "." + s.Name
+	}
+	return s.Name
+}
+
+// symbolCache holds the symbols of a buffer for one version of its text
+type symbolCache struct {
+	version int
+	symbols []Symbol
+}
+
+// Symbols returns the declarations in the buffer
+// Go files are parsed, and other filetypes use the symbol rules of their syntax file
+func (b *Buffer) Symbols() []Symbol {
+	if b.symbols != nil && b.symbols.version == b.version {
+		return b.symbols.symbols
+	}
+	var symbols []Symbol
+	if b.FileType() == "go" {
+		symbols = GoSymbols(b.String())
+	} else if len(b.symbolRules) > 0 {
+		lines := make([]string, b.NumLines)
+		for i := range lines {
+			lines[i] = b.Line(i)
+		}
+		symbols = RegexSymbols(lines, b.symbolRules)
+	}
+	b.symbols = &symbolCache{b.version, symbols}
+	return symbols
+}
+
+// GoSymbols returns the types, functions, methods, constants and variables
+// declared in Go source code, with methods placed after their receiver type
+// Source with syntax errors still gives the declarations the parser recovered
+func GoSymbols(src string) []Symbol {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, "", src, 0)
+	if f == nil {
+		return nil
+	}
+	lines := strings.Split(src, "\n")
+	loc := func(pos token.Pos) Loc {
+		p := fset.Position(pos)
+		y := p.Line - 1
+		if y < 0 || y >= len(lines) {
+			return Loc{0, 0}
+		}
+		col := Min(p.Column-1, len(lines[y]))
+		return Loc{utf8.RuneCountInString(lines[y][:col]), y}
+	}
+
+	var top, methods []Symbol
+	types := make(map[string]bool)
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				top = append(top, Symbol{Name: d.Name.Name, Kind: "func", Loc: loc(d.Name.Pos())})
+				continue
+			}
+			recv := receiverName(d.Recv.List[0].Type)
+			methods = append(methods, Symbol{Name: d.Name.Name, Kind: "method", Parent: recv, Loc: loc(d.Name.Pos())})
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					types[s.Name.Name] = true
+					top = append(top, Symbol{Name: s.Name.Name, Kind: "type", Loc: loc(s.Name.Pos())})
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						top = append(top, Symbol{Name: name.Name, Kind: d.Tok.String(), Loc: loc(name.Pos())})
+					}
+				}
+			}
+		}
+	}
+
+	var symbols []Symbol
+	for _, s := range top {
+		symbols = append(symbols, s)
+		if s.Kind != "type" {
+			continue
+		}
+		for _, m := range methods {
+			if m.Parent == s.Name {
+				m.Depth = 1
+				symbols = append(symbols, m)
+			}
+		}
+	}
+	// Methods of types declared in other files stay at the top level
+	for _, m := range methods {
+		if !types[m.Parent] {
+			symbols = append(symbols, m)
+		}
+	}
+	return symbols
+}
+
+// receiverName returns the name of the type of a method receiver
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// RegexSymbols returns the symbols found by the symbol rules of a syntax file
+// A symbol is nested in the closest symbol above it which is less indented
+func RegexSymbols(lines []string, rules []SymbolRule) []Symbol {
+	var symbols []Symbol
+	type scope struct {
+		indent int
+		name   string
+	}
+	var stack []scope
+	for y, line := range lines {
+		for _, rule := range rules {
+			m := rule.regex.FindStringSubmatchIndex(line)
+			if m == nil {
+				continue
+			}
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if start == end {
+				continue
+			}
+			indent := len(GetLeadingWhitespace(line))
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			s := Symbol{
+				Name:  line[start:end],
+				Kind:  rule.kind,
+				Loc:   Loc{utf8.RuneCountInString(line[:start]), y},
+				Depth: len(stack),
+			}
+			if len(stack) > 0 {
+				s.Parent = stack[len(stack)-1].name
+			}
+			symbols = append(symbols, s)
+			stack = append(stack, scope{indent, s.Name})
+			break
+		}
+	}
+	return symbols
+}
+
+// symbolAt returns the index of the last symbol which starts at or before the line
+func symbolAt(symbols []Symbol, line int) int {
+	best := -1
+	for i, s := range symbols {
+		if s.Loc.Y <= line && (best == -1 || s.Loc.Y >= symbols[best].Loc.Y) {
+			best = i
+		}
+	}
+	return best
+}
+
+// outlineText returns the lines of the outline tree of the symbols
+func outlineText(symbols []Symbol) string {
+	lines := make([]string, len(symbols))
+	for i, s := range symbols {
+		name := s.Name
+		if s.Depth == 0 && s.Parent != "" {
+			name = s.FullName()
+		}
+		lines[i] = strings.Repeat("  ", s.Depth) + s.Kind + " " + name
+	}
+	return strings.Join(lines, "\n")
+}
+
+// outlineState links an outline view to the view whose symbols it lists
+type outlineState struct {
+	source  *View
+	buf     *Buffer
+	version int
+	symbols []Symbol
+}
+
+// update refreshes the outline when the text of the source view changed, and
+// moves the cursor of the outline to the symbol under the cursor of the source
+func (o *outlineState) update(v *View) {
+	if !viewIsOpen(o.source) {
+		return
+	}
+	src := o.source
+	if src.Buf != o.buf || src.Buf.version != o.version {
+		o.buf, o.version = src.Buf, src.Buf.version
+		o.symbols = src.Buf.Symbols()
+		v.Buf.SetText(outlineText(o.symbols))
+		v.Buf.name = "Outline " + src.Buf.GetName()
+	}
+	if CurView() != v {
+		if i := symbolAt(o.symbols, src.Cursor.Y); i >= 0 {
+			v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, i, 0
+			v.Relocate()
+		}
+	}
+}
+
+// gotoSymbolLoc moves the cursor to the location of a symbol and centers the view on it
+func (v *View) gotoSymbolLoc(loc Loc) {
+	v.Cursor.ResetSelection()
+	v.Cursor.Loc = loc
+	v.Cursor.Relocate()
+	v.Cursor.LastVisualX = v.Cursor.GetVisualX()
+	v.Center(false)
+}
+
+// jumpToSymbol moves the cursor of the view to a symbol and records the jump
+func (v *View) jumpToSymbol(s Symbol) {
+	v.recordJump()
+	v.gotoSymbolLoc(s.Loc)
+}
+
+// Outline opens a split on the left which lists the declarations of the
+// current file as a tree, or closes it if it is already open
+// Pressing enter on a symbol jumps to it in the file
+func Outline(args []string) {
+	v := CurView()
+	for _, view := range tabs[curTab].views {
+		if view.outline != nil && (view == v || view.outline.source == v) {
+			view.Quit(false)
+			return
+		}
+	}
+	if v.Type != vtDefault {
+		messenger.Error("The outline only lists the symbols of files")
+		return
+	}
+	if len(v.Buf.Symbols()) == 0 && v.Buf.FileType() != "go" && len(v.Buf.symbolRules) == 0 {
+		messenger.Error("No symbol rules for the filetype ", v.Buf.FileType())
+		return
+	}
+
+	buf := NewScratchBuffer("", "Outline "+v.Buf.GetName(), "Unknown")
+	buf.Settings["ruler"] = false
+	v.VSplitIndex(buf, v.Num)
+	ov := CurView()
+	ov.Type = vtScratch
+	ov.Width = 30
+	ov.LockWidth = true
+	tabs[curTab].Resize()
+	ov.outline = &outlineState{source: v, version: -1}
+	ov.outline.update(ov)
+
+	ov.lineAction = func(ov *View, line int) {
+		o := ov.outline
+		if line >= len(o.symbols) {
+			return
+		}
+		if !viewIsOpen(o.source) {
+			messenger.Error("The file of the outline was closed")
+			return
+		}
+		tabs[curTab].CurView = o.source.Num
+		o.source.jumpToSymbol(o.symbols[line])
+	}
+}
+
+// GotoSymbol opens a fuzzy finder of the declarations in the current file
+func (v *View) GotoSymbol(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("GotoSymbol", v) {
+		return false
+	}
+
+	symbols := v.Buf.Symbols()
+	if len(symbols) == 0 {
+		messenger.Message("No symbols in this file")
+	} else {
+		autocomplete.Open(func(v *View) (messages Messages) {
+			for i, s := range symbols {
+				messages = append(messages, Message{
+					Searchable:       s.FullName(),
+					MessageToDisplay: fmt.Sprintf("%s %s (line %d)", s.Kind, s.FullName(), s.Loc.Y+1),
+					Value2:           []byte(strconv.Itoa(i)),
+				})
+			}
+			return messages
+		}, func(message Message) {
+			if i, err := strconv.Atoi(string(message.Value2)); err == nil && i < len(symbols) {
+				v.jumpToSymbol(symbols[i])
+			}
+		}, nil, v)
+	}
+
+	if usePlugin {
+		return PostActionCall("GotoSymbol", v)
+	}
+	return true
+}
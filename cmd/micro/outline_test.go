@@ -0,0 +1,74 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGoSymbols(t *testing.T) {
+	src := `package main
+
+const max = 3
+
+type Buffer struct{}
+
+func (b *Buffer) Save() {}
+
+var a, b int
+
+func main() {}
+
+func (v View) Quit() {}
+
+func (b Buffer) Close() {
+`
+	expected := []Symbol{
+		{Name: "max", Kind: "const", Loc: Loc{6, 2}},
+		{Name: "Buffer", Kind: "type", Loc: Loc{5, 4}},
+		{Name: "Save", Kind: "method", Parent: "Buffer", Loc: Loc{17, 6}, Depth: 1},
+		{Name: "Close", Kind: "method", Parent: "Buffer", Loc: Loc{16, 14}, Depth: 1},
+		{Name: "a", Kind: "var", Loc: Loc{4, 8}},
+		{Name: "b", Kind: "var", Loc: Loc{7, 8}},
+		{Name: "main", Kind: "func", Loc: Loc{5, 10}},
+		{Name: "Quit", Kind: "method", Parent: "View", Loc: Loc{14, 12}},
+	}
+	symbols := GoSymbols(src)
+	if len(symbols) != len(expected) {
+		t.Fatalf("GoSymbols returned %v, want %v", symbols, expected)
+	}
+	for i, s := range symbols {
+		if s != expected[i] {
+			t.Errorf("Symbol %d is %v, want %v", i, s, expected[i])
+		}
+	}
+}
+
+func TestRegexSymbols(t *testing.T) {
+	rules := []SymbolRule{
+		{"class", regexp.MustCompile(`^\s*class\s+(\w+)`)},
+		{"function", regexp.MustCompile(`^\s*def\s+(\w+)`)},
+	}
+	lines := []string{
+		"class Foo:",
+		"    def bar(self):",
+		"        def inner():",
+		"    def baz(self):",
+		"def top():",
+	}
+	expected := []Symbol{
+		{Name: "Foo", Kind: "class", Loc: Loc{6, 0}},
+		{Name: "bar", Kind: "function", Parent: "Foo", Loc: Loc{8, 1}, Depth: 1},
+		{Name: "inner", Kind: "function", Parent: "bar", Loc: Loc{12, 2}, Depth: 2},
+		{Name: "baz", Kind: "function", Parent: "Foo", Loc: Loc{8, 3}, Depth: 1},
+		{Name: "top", Kind: "function", Loc: Loc{4, 4}},
+	}
+	symbols := RegexSymbols(lines, rules)
+	if len(symbols) != len(expected) {
+		t.Fatalf("RegexSymbols returned %v, want %v", symbols, expected)
+	}
+	for i, s := range symbols {
+		if s != expected[i] {
+			t.Errorf("Symbol %d is %v, want %v", i, s, expected[i])
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// actionDescriptions are the short descriptions of the actions shown in the command palette
+var actionDescriptions = map[string]string{
+	"CursorUp":            "Move the cursor up",
+	"CursorDown":          "Move the cursor down",
+	"CursorPageUp":        "Move the cursor up one page",
+	"CursorPageDown":      "Move the cursor down one page",
+	"CursorLeft":          "Move the cursor left",
+	"CursorRight":         "Move the cursor right",
+	"CursorStart":         "Move the cursor to the start of the buffer",
+	"CursorEnd":           "Move the cursor to the end of the buffer",
+	"SelectToStart":       "Select to the start of the buffer",
+	"SelectToEnd":         "Select to the end of the buffer",
+	"SelectUp":            "Select up one line",
+	"SelectDown":          "Select down one line",
+	"SelectLeft":          "Select the character to the left",
+	"SelectRight":         "Select the character to the right",
+	"WordRight":           "Move the cursor to the next word",
+	"WordLeft":            "Move the cursor to the previous word",
+	"SelectWordRight":     "Select to the next word",
+	"SelectWordLeft":      "Select to the previous word",
+	"DeleteWordRight":     "Delete to the next word",
+	"DeleteWordLeft":      "Delete to the previous word",
+	"SelectToStartOfLine": "Select to the start of the line",
+	"SelectToEndOfLine":   "Select to the end of the line",
+	"InsertNewline":       "Insert a new line",
+	"InsertSpace":         "Insert a space",
+	"Backspace":           "Delete the character before the cursor",
+	"Delete":              "Delete the character under the cursor",
+	"InsertTab":           "Insert a tab or indent the selection",
+	"Save":                "Save the buffer",
+	"SaveAs":              "Save the buffer under a new name",
+	"Find":                "Search the buffer",
+	"FindNext":            "Find the next match of the last search",
+	"FindPrevious":        "Find the previous match of the last search",
+	"Center":              "Center the view on the cursor",
+	"Undo":                "Undo the last change",
+	"Redo":                "Redo the last undone change",
+	"Copy":                "Copy the selection to the clipboard",
+	"Cut":                 "Cut the selection to the clipboard",
+	"CutLine":             "Cut the current line to the clipboard",
+	"DuplicateLine":       "Duplicate the current line",
+	"DeleteLine":          "Delete the current line",
+	"MoveLinesUp":         "Move the selected lines up",
+	"MoveLinesDown":       "Move the selected lines down",
+	"IndentSelection":     "Indent the selected lines",
+	"OutdentSelection":    "Outdent the selected lines",
+	"OutdentLine":         "Outdent the current line",
+	"Paste":               "Paste from the clipboard",
+	"PastePrimary":        "Paste from the primary selection",
+	"SelectAll":           "Select the whole buffer",
+	"OpenFile":            "Open a file",
+	"GotoFile":            "Open a file in the working directory by name",
+	"Start":               "Scroll to the start of the buffer",
+	"End":                 "Scroll to the end of the buffer",
+	"PageUp":              "Scroll up one page",
+	"PageDown":            "Scroll down one page",
+	"HalfPageUp":          "Scroll up half a page",
+	"HalfPageDown":        "Scroll down half a page",
+	"StartOfLine":         "Move the cursor to the start of the line",
+	"EndOfLine":           "Move the cursor to the end of the line",
+	"StartOfDisplayLine":  "Move the cursor to the start of the row of a soft wrapped line",
+	"EndOfDisplayLine":    "Move the cursor to the end of the row of a soft wrapped line",
+	"ToggleHelp":          "Show or hide the help",
+	"ToggleRuler":         "Show or hide the line numbers",
+	"JumpLine":            "Jump to a line",
+	"ClearStatus":         "Clear the message in the infobar",
+	"ShellMode":           "Run a shell command",
+	"CommandMode":         "Run a command",
+	"CommandPalette":      "Search for an action or command to run",
+	"Escape":              "Leave the current mode or quit",
+	"Quit":                "Close the current view",
+	"QuitAll":             "Close all views and exit",
+	"AddTab":              "Open a new tab",
+	"PreviousTab":         "Go to the previous tab",
+	"NextTab":             "Go to the next tab",
+	"NextSplit":           "Go to the next split",
+	"PreviousSplit":       "Go to the previous split",
+	"Unsplit":             "Close all other splits",
+	"VSplit":              "Open a new vertical split",
+	"HSplit":              "Open a new horizontal split",
+	"ToggleMacro":         "Start or stop recording a macro",
+	"PlayMacro":           "Play the recorded macro",
+	"Format":              "Format the file",
+	"NextLoc":             "Go forward in the jump list of the split",
+	"PrevLoc":             "Go back to where the split last jumped from",
+	"JumpList":            "Pick a location from the jump list of the split",
+	"PrevChange":          "Go to the previous edit in the change list of the buffer",
+	"NextChange":          "Go to the next edit in the change list of the buffer",
+	"ChangeList":          "Pick a location from the change list of the buffer",
+	"SetBookmark":         "Set a bookmark at the cursor (a-z in the file, A-Z global)",
+	"GotoBookmark":        "Jump to a bookmark",
+	"DeleteBookmarks":     "Delete the bookmarks on the current line",
+	"BookmarkList":        "Pick a bookmark from all files",
+	"Fold":                "Close the innermost fold around the cursor",
+	"Unfold":              "Open the closed fold on the current line",
+	"ToggleFold":          "Open or close the fold on the current line",
+	"FoldAll":             "Close every fold of the buffer",
+	"UnfoldAll":           "Open every fold of the buffer",
+	"BufferList":          "Switch to a loaded buffer, most recently used first",
+	"GrowSplitWidth":      "Make the current split wider",
+	"ShrinkSplitWidth":    "Make the current split narrower",
+	"GrowSplitHeight":     "Make the current split taller",
+	"ShrinkSplitHeight":   "Make the current split shorter",
+	"EqualizeSplits":      "Give all the splits of the tab the same size",
+	"ZoomSplit":           "Show the current split over the whole tab, or all the splits again",
+	"SwapSplit":           "Swap the current split with the next one",
+	"RotateSplits":        "Move the splits next to the current one a place forward",
+	"GotoDefinition":      "Go to the definition of the identifier under the cursor",
+	"Referrers":           "List the references to the identifier under the cursor",
+	"Describe":            "Show the documentation of the identifier under the cursor",
+	"Rename":              "Rename the identifier under the cursor",
+	"Autocomplete":        "Complete the word under the cursor",
+	"GotoGutterMesssage":  "Go to the next gutter message",
+	"SelectWord":          "Select the word under the cursor",
+	"What":                "Show what is under the cursor",
+	"Suggest":             "Show suggestions for the code under the cursor",
+	"Template":            "Insert a snippet",
+	"ExtractVariable":     "Extract the selection into a local variable",
+	"NextHunk":            "Go to the next changed hunk",
+	"PreviousHunk":        "Go to the previous changed hunk",
+	"PreviewHunk":         "Show the HEAD version of the hunk under the cursor",
+	"RevertHunk":          "Revert the hunk under the cursor to HEAD",
+	"StageHunk":           "Stage the hunk under the cursor in git",
+	"DiffGet":             "Take the hunk under the cursor from the other side of the diff",
+	"DiffPut":             "Copy the hunk under the cursor to the other side of the diff",
+	"ConflictTakeOurs":    "Resolve the merge conflict with our side",
+	"ConflictTakeTheirs":  "Resolve the merge conflict with their side",
+	"ConflictTakeBoth":    "Resolve the merge conflict with both sides",
+	"ConflictEdit":        "Remove the markers of the merge conflict to edit it",
+	"NextConflict":        "Go to the next merge conflict",
+	"PreviousConflict":    "Go to the previous merge conflict",
+	"GotoSymbol":          "Jump to a declaration in the file by name",
+	"GotoWorkspaceSymbol": "Jump to a declaration in the Go packages of the workspace",
+	"CallHierarchy":       "Show the calls into and out of the Go function under the cursor",
+	"TypeHierarchy":       "Show the interfaces, implementations and embedded types of the Go type under the cursor",
+	"InsertEnter":         "Insert a new line",
+}
+
+// commandDescriptions are the arguments and short descriptions of the commands
+// shown in the command palette
+// Commands without arguments run as soon as they are chosen
+var commandDescriptions = map[string][2]string{
+	"set":      {"option value", "Set a global option"},
+	"setlocal": {"option value", "Set an option for the current buffer"},
+	"show":     {"option", "Show the value of an option"},
+	"bind":     {"key action", "Bind a key to an action"},
+	"run":      {"command", "Run a shell command in the background"},
+	"quit":     {"", "Close the current view"},
+	"save":     {"[filename]", "Save the buffer"},
+	"replace":  {"search value [flags]", "Replace the matches of a regex"},
+	"vsplit":   {"[filename]", "Open a file in a vertical split"},
+	"hsplit":   {"[filename]", "Open a file in a horizontal split"},
+	"tab":      {"[filename]", "Open a file in a new tab"},
+	"help":     {"[topic]", "Open a help page"},
+	"eval":     {"expression", "Evaluate a Lua expression"},
+	"log":      {"", "Show or hide the log"},
+	"plugin":   {"command [plugin]", "Install, remove, update or list plugins"},
+	"reload":   {"", "Reload the settings, bindings and plugins"},
+	"cd":       {"directory", "Change the working directory"},
+	"pwd":      {"", "Show the working directory"},
+	"open":     {"filename", "Open a file in the current view"},
+	"blame":    {"", "Show the git blame of the file"},
+	"gitlog":   {"", "List the commits which changed the file"},
+	"diff":     {"[file] [file]", "Compare the buffer with the file on disk, or two files"},
+	"diffhead": {"", "Compare the buffer with git's HEAD"},
+	"diffoff":  {"", "Stop comparing the current view"},
+	"session":  {"save|load|delete|list [name]", "Manage named sessions"},
+	"macro":    {"list|save|play|lines|edit|delete [name]", "Manage named macros"},
+	"outline":  {"", "Show or hide the outline of the file"},
+	"explorer": {"", "Show or hide the file tree of the working directory"},
+	"buffer":   {"[name|number]", "Show a loaded buffer in the current view"},
+	"bclose":   {"[name|number]", "Hide a buffer, keeping it loaded"},
+	"bdelete":  {"[name|number]", "Unload a buffer"},
+	"bwipe":    {"[name|number]", "Unload a buffer and forget its saved cursor and undo history"},
+}
+
+// SetCommandDescription sets the arguments and the description shown for a
+// command in the command palette, so plugins can describe their commands
+func SetCommandDescription(name, args, description string) {
+	commandDescriptions[name] = [2]string{args, description}
+}
+
+// actionKeys returns the keys which run only the given action
+func actionKeys(action string) []string {
+	var keys []string
+	var walk func(m *keyMap, prefix []Key)
+	walk = func(m *keyMap, prefix []Key) {
+		if len(m.names) == 1 && m.names[0] == action {
+			keys = append(keys, keySequenceString(prefix))
+		}
+		for k, child := range m.children {
+			walk(child, append(prefix[:len(prefix):len(prefix)], k))
+		}
+	}
+	walk(globalKeyMap, nil)
+	sort.Strings(keys)
+	return keys
+}
+
+// A paletteEntry is an action or command listed in the command palette
+type paletteEntry struct {
+	name    string
+	command bool
+	keys    []string
+	args    string
+	desc    string
+}
+
+// String returns the text of the entry shown in the palette
+func (p paletteEntry) String() string {
+	name := p.name
+	if p.command {
+		name = ":" + name
+		if p.args != "" {
+			name += " " + p.args
+		}
+	}
+	if len(p.keys) > 0 {
+		name += " (" + strings.Join(p.keys, ", ") + ")"
+	}
+	if p.desc != "" {
+		name += " - " + p.desc
+	}
+	return name
+}
+
+// paletteEntries returns every action and command, with commands last
+func paletteEntries() []paletteEntry {
+	var actions, cmds []paletteEntry
+	for name := range bindingActions {
+		actions = append(actions, paletteEntry{name: name, keys: actionKeys(name), desc: actionDescriptions[name]})
+	}
+	for name := range commands {
+		e := paletteEntry{name: name, command: true}
+		if d, ok := commandDescriptions[name]; ok {
+			e.args, e.desc = d[0], d[1]
+		} else {
+			// Commands made by plugins take any arguments
+			e.args, e.desc = "[args]", "Plugin command"
+		}
+		cmds = append(cmds, e)
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].name < actions[j].name })
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].name < cmds[j].name })
+	return append(actions, cmds...)
+}
+
+// runPaletteEntry runs the action, or prompts for the arguments of the command
+// using the command's completions
+func (v *View) runPaletteEntry(e paletteEntry) {
+	if !e.command {
+		if findAction(e.name)(v, true) {
+			v.Relocate()
+		}
+		return
+	}
+	cmd, ok := commands[e.name]
+	if !ok {
+		return
+	}
+	if e.args == "" {
+		HandleCommand(e.name)
+		return
+	}
+	completions := cmd.completions
+	if len(completions) == 0 {
+		completions = []Completion{NoCompletion}
+	}
+	input, canceled := messenger.Prompt("> "+e.name+" ", "", "Palette", completions...)
+	if !canceled {
+		HandleCommand(e.name + " " + input)
+	}
+}
+
+// CommandPalette opens a fuzzy finder of all actions and commands
+func (v *View) CommandPalette(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("CommandPalette", v) {
+		return false
+	}
+
+	entries := paletteEntries()
+	autocomplete.Open(func(v *View) (messages Messages) {
+		for i, e := range entries {
+			messages = append(messages, Message{Searchable: e.name, MessageToDisplay: e.String(), Value2: []byte(strconv.Itoa(i))})
+		}
+		return messages
+	}, func(message Message) {
+		if i, err := strconv.Atoi(string(message.Value2)); err == nil {
+			v.runPaletteEntry(entries[i])
+		}
+	}, nil, v)
+
+	if usePlugin {
+		return PostActionCall("CommandPalette", v)
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestPaletteDescriptions(t *testing.T) {
+	for name := range bindingActions {
+		if actionDescriptions[name] == "" {
+			t.Errorf("Action %s has no description", name)
+		}
+	}
+	for name := range DefaultCommands() {
+		if _, ok := commandDescriptions[name]; !ok {
+			t.Errorf("Command %s has no description", name)
+		}
+	}
+}
+
+func TestPaletteEntryString(t *testing.T) {
+	tests := []struct {
+		entry    paletteEntry
+		expected string
+	}{
+		{paletteEntry{name: "Save", keys: []string{"CtrlS"}, desc: "Save the buffer"}, "Save (CtrlS) - Save the buffer"},
+		{paletteEntry{name: "Undo", keys: []string{"CtrlZ", "CtrlK CtrlU"}}, "Undo (CtrlZ, CtrlK CtrlU)"},
+		{paletteEntry{name: "set", command: true, args: "option value", desc: "Set a global option"}, ":set option value - Set a global option"},
+		{paletteEntry{name: "pwd", command: true}, ":pwd"},
+	}
+	for _, test := range tests {
+		if got := test.entry.String(); got != test.expected {
+			t.Errorf("String() = %q, want %q", got, test.expected)
+		}
+	}
+}
@@ -58,12 +58,13 @@ func ExitSearch(v *View) {
 func HandleSearchEvent(event tcell.Event, v *View) {
 	switch e := event.(type) {
 	case *tcell.EventKey:
-		switch e.Key() {
-		case tcell.KeyEscape:
+		names := contextActions("search", e)
+		switch {
+		case hasAction(names, "Cancel"):
 			// Exit the search mode
 			ExitSearch(v)
 			return
-		case tcell.KeyCtrlQ, tcell.KeyCtrlC, tcell.KeyEnter:
+		case hasAction(names, "Accept"):
 			// Done
 			EndSearch()
 			return
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// A SerializedView is a view in a saved session
+type SerializedView struct {
+	// The absolute path of the file in the view
+	Path    string
+	Topline int
+	Cursor  Loc
+	// The jump list of the view
+	Jumps   []Mark
+	JumpPos int
+}
+
+// A SerializedSplit is a node of the split tree in a saved session
+// Leaves have a View, other nodes have a Kind and Children
+type SerializedSplit struct {
+	View     *SerializedView
+	Kind     SplitType
+	Children []*SerializedSplit
+
+	Width      int
+	Height     int
+	LockWidth  bool
+	LockHeight bool
+	// The share of the space of the parent the split gets once resized
+	Weight float64
+}
+
+// A SerializedTab is a tab in a saved session
+type SerializedTab struct {
+	Tree *SerializedSplit
+	// The index of the current view in the order the leaves appear in the tree
+	CurView int
+}
+
+// A Session holds the layout of all tabs and splits so it can be restored later
+type Session struct {
+	Tabs   []SerializedTab
+	CurTab int
+}
+
+// sessionDir returns the directory the sessions are stored in
+func sessionDir() string {
+	return filepath.Join(configDir, "sessions")
+}
+
+// sessionPath returns the file the session with the given name is stored in
+func sessionPath(name string) string {
+	return filepath.Join(sessionDir(), name)
+}
+
+// autoSessionPath returns the file the last session in the working directory is stored in
+func autoSessionPath() string {
+	wd, _ := os.Getwd()
+	return filepath.Join(sessionDir(), "auto", EscapePath(wd))
+}
+
+// checkConfigName returns an error if the name can't be used for a file in the config directory
+func checkConfigName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return errors.New("Invalid name " + name)
+	}
+	return nil
+}
+
+// validSessionName returns an error if the name can't be used for a session file
+func validSessionName(name string) error {
+	if name == "auto" {
+		return errors.New("Invalid session name " + name)
+	}
+	return checkConfigName(name)
+}
+
+// ListSessions returns the names of the saved sessions
+func ListSessions() []string {
+	files, _ := ioutil.ReadDir(sessionDir())
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serializeNode returns the serialized form of a node of the split tree
+// Views which don't show a file are left out, and nil is returned if nothing is left
+func serializeNode(n Node) *SerializedSplit {
+	switch n := n.(type) {
+	case *LeafNode:
+		v := n.view
+		if v.Type != vtDefault || v.Buf.Path == "" {
+			return nil
+		}
+		return &SerializedSplit{
+			View:       &SerializedView{v.Buf.AbsPath, v.Topline, v.Cursor.Loc, v.jumps.Serialize(), v.jumps.Pos},
+			Width:      v.Width,
+			Height:     v.Height,
+			LockWidth:  v.LockWidth,
+			LockHeight: v.LockHeight,
+			Weight:     n.weight,
+		}
+	case *SplitTree:
+		s := &SerializedSplit{
+			Kind:       n.kind,
+			Width:      n.width,
+			Height:     n.height,
+			LockWidth:  n.lockWidth,
+			LockHeight: n.lockHeight,
+			Weight:     n.weight,
+		}
+		for _, child := range n.children {
+			if c := serializeNode(child); c != nil {
+				s.Children = append(s.Children, c)
+			}
+		}
+		switch len(s.Children) {
+		case 0:
+			return nil
+		case 1:
+			// The child takes the place of the split
+			s.Children[0].Weight = s.Weight
+			return s.Children[0]
+		}
+		return s
+	}
+	return nil
+}
+
+// leaves returns the views in the serialized tree in order
+func (s *SerializedSplit) leaves() []*SerializedView {
+	if s.View != nil {
+		return []*SerializedView{s.View}
+	}
+	var views []*SerializedView
+	for _, c := range s.Children {
+		views = append(views, c.leaves()...)
+	}
+	return views
+}
+
+// CurrentSession returns the session of the open tabs
+func CurrentSession() *Session {
+	s := &Session{CurTab: 0}
+	for i, t := range tabs {
+		tree := serializeNode(t.tree)
+		if tree == nil {
+			continue
+		}
+		tab := SerializedTab{Tree: tree}
+		// Find the position of the current view among the leaves which were kept
+		cur := t.views[t.CurView]
+		for j, sv := range tree.leaves() {
+			if cur.Type == vtDefault && sv.Path == cur.Buf.AbsPath {
+				tab.CurView = j
+				break
+			}
+		}
+		if i == curTab {
+			s.CurTab = len(s.Tabs)
+		}
+		s.Tabs = append(s.Tabs, tab)
+	}
+	return s
+}
+
+// Save writes the session to the given file
+func (s *Session) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return gob.NewEncoder(file).Encode(s)
+}
+
+// LoadSession reads a session from the given file
+func LoadSession(path string) (*Session, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	s := new(Session)
+	if err := gob.NewDecoder(file).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// sessionBuffer opens the file at the given absolute path, reusing buffers which are already open
+func sessionBuffer(path string, open map[string]*Buffer) *Buffer {
+	if buf, ok := open[path]; ok {
+		return buf
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	// Show paths relative to the working directory like files opened on the command line
+	name := path
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+	}
+	buf := NewBuffer(file, name)
+	open[path] = buf
+	return buf
+}
+
+// build creates the split tree of a tab from the serialized tree
+// Views whose file can no longer be opened are skipped
+func (s *SerializedSplit) build(t *Tab, parent *SplitTree, open map[string]*Buffer) Node {
+	if s.View != nil {
+		buf := sessionBuffer(s.View.Path, open)
+		if buf == nil {
+			return nil
+		}
+		v := NewView(buf)
+		v.Width, v.Height = s.Width, s.Height
+		v.LockWidth, v.LockHeight = s.LockWidth, s.LockHeight
+		v.Cursor.X, v.Cursor.Y = s.View.Cursor.X, s.View.Cursor.Y
+		v.Cursor.Relocate()
+		v.Topline = Min(s.View.Topline, Max(buf.NumLines-1, 0))
+		v.jumps = restoreJumpList(s.View.Jumps, s.View.JumpPos)
+		t.views = append(t.views, v)
+		l := NewLeafNode(v, parent)
+		l.weight = s.Weight
+		return l
+	}
+	tree := &SplitTree{
+		kind:       s.Kind,
+		parent:     parent,
+		width:      s.Width,
+		height:     s.Height,
+		lockWidth:  s.LockWidth,
+		lockHeight: s.LockHeight,
+		weight:     s.Weight,
+	}
+	for _, c := range s.Children {
+		if n := c.build(t, tree, open); n != nil {
+			tree.children = append(tree.children, n)
+		}
+	}
+	if len(tree.children) == 0 {
+		return nil
+	}
+	return tree
+}
+
+// Restore replaces the open tabs with the ones in the session
+// It returns false if none of the files in the session could be opened
+func (s *Session) Restore() bool {
+	open := make(map[string]*Buffer)
+	for _, t := range tabs {
+		for _, v := range t.views {
+			if v.Type == vtDefault && v.Buf.Path != "" {
+				open[v.Buf.AbsPath] = v.Buf
+			}
+		}
+	}
+
+	var newTabs []*Tab
+	cur := 0
+	for i, st := range s.Tabs {
+		t := new(Tab)
+		node := st.Tree.build(t, nil, open)
+		if node == nil {
+			continue
+		}
+		if tree, ok := node.(*SplitTree); ok {
+			t.tree = tree
+		} else {
+			t.tree = &SplitTree{kind: VerticalSplit}
+			t.tree.children = []Node{node}
+			node.(*LeafNode).parent = t.tree
+		}
+		t.tree.Cleanup()
+		leaves := st.Tree.leaves()
+		if st.CurView < len(leaves) {
+			// Skipped views shift the index of the current view
+			for j, v := range t.views {
+				if v.Buf.AbsPath == leaves[st.CurView].Path {
+					t.CurView = j
+					break
+				}
+			}
+		}
+		if i == s.CurTab {
+			cur = len(newTabs)
+		}
+		newTabs = append(newTabs, t)
+	}
+	if len(newTabs) == 0 {
+		return false
+	}
+
+	// The buffers the session shows again stay loaded when the views which
+	// showed them are closed
+	oldTabs := tabs
+	tabs = newTabs
+	curTab = cur
+	for _, t := range oldTabs {
+		for _, v := range t.views {
+			v.CloseBuffer()
+			releaseBuffer(v.Buf)
+		}
+	}
+	for i, t := range tabs {
+		t.SetNum(i)
+		t.Resize()
+		// The cursor must stay visible now that the size of the views is known
+		for _, v := range t.views {
+			v.Relocate()
+		}
+	}
+	return true
+}
+
+// saveAutoSession stores the open tabs as the last session of the working directory
+// if the autosession option is on
+func saveAutoSession() {
+	if globalSettings["autosession"].(bool) {
+		CurrentSession().Save(autoSessionPath())
+	}
+}
+
+// RestoreStartupSession restores the session given with -session, or the last session
+// of the working directory if the autosession option is on and no files were given
+func RestoreStartupSession() bool {
+	if *flagSession != "" {
+		s, err := LoadSession(sessionPath(*flagSession))
+		if err != nil {
+			TermMessage("Could not load session ", *flagSession, ": ", err)
+			return false
+		}
+		if !s.Restore() {
+			TermMessage("None of the files in session ", *flagSession, " could be opened")
+			return false
+		}
+		return true
+	}
+	if globalSettings["autosession"].(bool) && len(flag.Args()) == 0 && isatty.IsTerminal(os.Stdin.Fd()) {
+		if s, err := LoadSession(autoSessionPath()); err == nil {
+			return s.Restore()
+		}
+	}
+	return false
+}
+
+// SessionCmd saves, loads, deletes or lists named sessions
+func SessionCmd(args []string) {
+	if len(args) == 0 {
+		messenger.Error("Not enough arguments")
+		return
+	}
+	if args[0] == "list" {
+		names := ListSessions()
+		if len(names) == 0 {
+			messenger.Message("No saved sessions")
+		} else {
+			messenger.Message("Sessions: ", strings.Join(names, " "))
+		}
+		return
+	}
+	if len(args) < 2 {
+		messenger.Error("Not enough arguments")
+		return
+	}
+	name := args[1]
+	if err := validSessionName(name); err != nil {
+		messenger.Error(err)
+		return
+	}
+
+	switch args[0] {
+	case "save":
+		if err := CurrentSession().Save(sessionPath(name)); err != nil {
+			messenger.Error("Could not save session: ", err)
+			return
+		}
+		messenger.Message("Saved session ", name)
+	case "load":
+		s, err := LoadSession(sessionPath(name))
+		if err != nil {
+			messenger.Error("Could not load session ", name, ": ", err)
+			return
+		}
+		// Make sure not to lose unsaved changes to the files which are open now
+		for _, t := range tabs {
+			for _, v := range t.views {
+				if !v.CanClose() {
+					return
+				}
+			}
+		}
+		if !s.Restore() {
+			messenger.Error("None of the files in session ", name, " could be opened")
+			return
+		}
+		messenger.Message("Loaded session ", name)
+	case "delete":
+		if err := os.Remove(sessionPath(name)); err != nil {
+			messenger.Error("Could not delete session: ", err)
+			return
+		}
+		messenger.Message("Deleted session ", name)
+	default:
+		messenger.Error("Unknown session command ", args[0])
+	}
+}
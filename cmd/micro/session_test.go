@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zyedidia/tcell"
+)
+
+func TestSessionSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "microsession")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := &SerializedView{"/a.go", 10, Loc{2, 12}, []Mark{{"/a.go", Loc{1, 2}}, {"/b.go", Loc{0, 5}}}, 1}
+	b := &SerializedView{"/b.go", 0, Loc{0, 0}, nil, 0}
+	s := &Session{
+		Tabs: []SerializedTab{{
+			Tree: &SerializedSplit{Kind: VerticalSplit, Width: 80, Height: 24, Children: []*SerializedSplit{
+				{View: a, Width: 40, Height: 23},
+				{View: b, Width: 20, Height: 23, LockWidth: true},
+			}},
+			CurView: 1,
+		}},
+	}
+	path := filepath.Join(dir, "sessions", "test")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("LoadSession = %+v, want %+v", loaded, s)
+	}
+	if leaves := loaded.Tabs[0].Tree.leaves(); !reflect.DeepEqual(leaves, []*SerializedView{a, b}) {
+		t.Errorf("leaves = %v", leaves)
+	}
+}
+
+func TestValidSessionName(t *testing.T) {
+	for name, valid := range map[string]bool{"work": true, "my-session": true, "": false, "auto": false, "a/b": false, "..": false} {
+		if err := validSessionName(name); (err == nil) != valid {
+			t.Errorf("validSessionName(%q) = %v", name, err)
+		}
+	}
+}
+
+func TestRestoreOverModifiedBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "microsession")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { configDir = d }(configDir)
+	configDir = dir
+	defer func(list []*Buffer) { bufferList = list }(bufferList)
+	bufferList = nil
+	defer func(t []*Tab, c int) { tabs, curTab = t, c }(tabs, curTab)
+
+	globalSettings = DefaultGlobalSettings()
+	screen = tcell.NewSimulationScreen("")
+	screen.Init()
+	messenger = new(Messenger)
+
+	pathA, pathB := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	ioutil.WriteFile(pathA, []byte("a\n"), 0644)
+	ioutil.WriteFile(pathB, []byte("b\n"), 0644)
+	a := NewBuffer(strings.NewReader("a\n"), pathA)
+	b := NewBuffer(strings.NewReader("b\n"), pathB)
+	tabs = []*Tab{NewTabFromView(NewViewWidthHeight(a, 80, 24)), NewTabFromView(NewViewWidthHeight(b, 80, 24))}
+	curTab = 0
+	a.Insert(Loc{0, 0}, "x")
+	b.Insert(Loc{0, 0}, "y")
+
+	s := &Session{Tabs: []SerializedTab{{
+		Tree: &SerializedSplit{View: &SerializedView{Path: pathA}, Width: 80, Height: 24},
+	}}}
+	if !s.Restore() {
+		t.Fatal("Restore failed")
+	}
+	if CurView().Buf != a || !a.IsModified {
+		t.Errorf("Restore didn't show the modified buffer of a.txt again")
+	}
+	// The buffer shown again stays listed, and the one of b.txt, whose
+	// changes were discarded, is dropped
+	if findBuffer(pathA) != a || findBuffer(pathB) != nil {
+		t.Errorf("buffer list after Restore: %v", bufferList)
+	}
+	if buf := NewBuffer(strings.NewReader("a\n"), pathA); buf != a {
+		t.Errorf("opening a.txt again loaded a second buffer")
+	}
+}
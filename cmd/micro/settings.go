@@ -24,6 +24,10 @@ var optionValidators = map[string]optionValidator{
 	"scrollspeed":  validateNonNegativeValue,
 	"colorscheme":  validateColorscheme,
 	"colorcolumn":  validateNonNegativeValue,
+	"chordtimeout": validateNonNegativeValue,
+	"hoverdelay":   validateNonNegativeValue,
+	"foldmethod":   validateFoldMethod,
+	"leader":       validateKeyName,
 }
 
 // InitGlobalSettings initializes the options map and sets all options to their default values
@@ -178,14 +182,21 @@ func DefaultGlobalSettings() map[string]interface{} {
 		"autoindent":   true,
 		"keepautoindent": false,
 		"autosave":     false,
+		"autosession":  false,
+		"chordtimeout": float64(1000),
 		"colorcolumn":  float64(0),
 		"colorscheme":  "default",
 		"cursorline":   true,
 		"eofnewline":   false,
+		"foldmethod":   "auto",
+		"gitgutter":    true,
+		"hoverdelay":   float64(0),
 		"rmtrailingws": false,
 		"ignorecase":   false,
 		"indentchar":   " ",
 		"infobar":      true,
+		"leader":       "CtrlUnderscore",
+		"minimap":      false,
 		"ruler":        true,
 		"savecursor":   false,
 		"saveundo":     false,
@@ -195,9 +206,13 @@ func DefaultGlobalSettings() map[string]interface{} {
 		"splitRight":   true,
 		"splitBottom":  true,
 		"statusline":   true,
+		"stickyscroll": false,
 		"syntax":       true,
 		"tabsize":      float64(4),
 		"tabstospaces": false,
+		"vimmode":      false,
+		"wordwrap":     false,
+		"wrapindent":   false,
 		"pluginchannels": []string{
 			"https://raw.githubusercontent.com/micro-editor/plugin-channel/master/channel.json",
 		},
@@ -215,10 +230,14 @@ func DefaultLocalSettings() map[string]interface{} {
 		"colorcolumn":  float64(0),
 		"cursorline":   true,
 		"eofnewline":   false,
+		"foldmethod":   "auto",
+		"gitgutter":    true,
 		"rmtrailingws": false,
 		"filetype":     "Unknown",
 		"ignorecase":   false,
 		"indentchar":   " ",
+		"minimap":      false,
+		"readonly":     false,
 		"ruler":        true,
 		"savecursor":   false,
 		"saveundo":     false,
@@ -228,9 +247,12 @@ func DefaultLocalSettings() map[string]interface{} {
 		"splitRight":   true,
 		"splitBottom":  true,
 		"statusline":   true,
+		"stickyscroll": false,
 		"syntax":       true,
 		"tabsize":      float64(4),
 		"tabstospaces": false,
+		"wordwrap":     false,
+		"wrapindent":   false,
 	}
 }
 
@@ -286,6 +308,10 @@ func SetOption(option, value string) error {
 		}
 	}
 
+	if option == "leader" {
+		InitBindings()
+	}
+
 	if option == "infobar" {
 		for _, tab := range tabs {
 			tab.Resize()
@@ -344,6 +370,10 @@ func SetLocalOption(option, value string, view *View) error {
 		}
 	}
 
+	if option == "minimap" {
+		tabs[view.TabNum].Resize()
+	}
+
 	if option == "filetype" {
 		LoadSyntaxFiles()
 		buf.UpdateRules()
@@ -424,3 +454,17 @@ func validateColorscheme(option string, value interface{}) error {
 
 	return nil
 }
+
+func validateKeyName(option string, value interface{}) error {
+	name, ok := value.(string)
+
+	if !ok {
+		return errors.New("Expected string type for " + option)
+	}
+
+	if _, ok := findKey(name); !ok {
+		return errors.New(name + " is not a valid key")
+	}
+
+	return nil
+}
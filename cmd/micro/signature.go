@@ -0,0 +1,334 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/zyedidia/tcell"
+)
+
+// The number of lines above the cursor searched for the open paren of a call
+const signatureSearchLines = 200
+
+// SignatureBox shows the parameters of the function being called above the
+// cursor, with the parameter of the argument being typed highlighted
+type SignatureBox struct {
+	open bool
+	view *View
+	// The open paren of the call
+	paren Loc
+	name  string
+	// The parameters of the function, as name and type
+	params  []string
+	results string
+	// The index of the argument under the cursor
+	arg int
+}
+
+// The keywords which are followed by a paren without being a call
+var notCallKeywords = map[string]bool{
+	"if": true, "for": true, "switch": true, "return": true, "func": true,
+	"case": true, "range": true, "go": true, "defer": true, "select": true,
+}
+
+// funcDeclPrefix matches the start of a line declaring a function or method
+var funcDeclPrefix = regexp.MustCompile(`^\s*func\s*(\([^)]*\)\s*)?$`)
+
+// callAt finds the call whose argument list the end of text is in
+// It returns the byte index of the open paren, the index of the argument
+// being typed, and the byte index where the name of the function starts
+// Strings and comments are skipped, and calls in the declaration of a function
+// or in a composite literal inside of the arguments are not found
+func callAt(text string) (paren, arg, name int, ok bool) {
+	type open struct {
+		pos    int
+		commas int
+	}
+	var stack []open
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"', '\'':
+			j := i + 1
+			for j < len(text) && text[j] != c && text[j] != '\n' {
+				if text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			i = j
+		case '`':
+			if j := strings.IndexByte(text[i+1:], '`'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(text)
+			}
+		case '/':
+			if strings.HasPrefix(text[i:], "//") {
+				if j := strings.IndexByte(text[i:], '\n'); j >= 0 {
+					i += j
+				} else {
+					i = len(text)
+				}
+			} else if strings.HasPrefix(text[i:], "/*") {
+				if j := strings.Index(text[i+2:], "*/"); j >= 0 {
+					i += j + 3
+				} else {
+					i = len(text)
+				}
+			}
+		case '(', '[', '{':
+			stack = append(stack, open{i, 0})
+		case ')', ']', '}':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if len(stack) > 0 {
+				stack[len(stack)-1].commas++
+			}
+		}
+	}
+	if len(stack) == 0 || text[stack[len(stack)-1].pos] != '(' {
+		return 0, 0, 0, false
+	}
+	top := stack[len(stack)-1]
+
+	end := len(strings.TrimRight(text[:top.pos], " \t"))
+	start := end
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		start -= size
+	}
+	ident := text[start:end]
+	if ident == "" || notCallKeywords[ident] || unicode.IsDigit([]rune(ident)[0]) {
+		return 0, 0, 0, false
+	}
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	if funcDeclPrefix.MatchString(text[lineStart:start]) {
+		return 0, 0, 0, false
+	}
+	return top.pos, top.commas, start, true
+}
+
+// splitSignature splits the type of a function printed by go/types, such as
+// func(name string, n int) error, into its parameters and its results
+func splitSignature(typ string) (params []string, results string, ok bool) {
+	if !strings.HasPrefix(typ, "func(") {
+		return nil, "", false
+	}
+	depth, start := 0, len("func(")
+	for i := start - 1; i < len(typ); i++ {
+		switch typ[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if p := strings.TrimSpace(typ[start:i]); p != "" {
+					params = append(params, p)
+				}
+				return params, strings.TrimSpace(typ[i+1:]), true
+			}
+		case ',':
+			if depth == 1 {
+				params = append(params, strings.TrimSpace(typ[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// activeParam returns the index of the parameter of an argument, or -1 if
+// there are more arguments than parameters
+func activeParam(params []string, arg int) int {
+	if arg < len(params) {
+		return arg
+	}
+	if len(params) > 0 && strings.Contains(params[len(params)-1], "...") {
+		return len(params) - 1
+	}
+	return -1
+}
+
+// cursorCall finds the call the cursor of the view is in, and returns the
+// location of its open paren, the index of the argument and the location of
+// the name of the function
+func (v *View) cursorCall() (paren Loc, arg int, name Loc, ok bool) {
+	first := Max(0, v.Cursor.Y-signatureSearchLines)
+	lines := make([]string, 0, v.Cursor.Y-first+1)
+	for y := first; y < v.Cursor.Y; y++ {
+		lines = append(lines, v.Buf.Line(y))
+	}
+	line := []rune(v.Buf.Line(v.Cursor.Y))
+	lines = append(lines, string(line[:Min(v.Cursor.X, len(line))]))
+	text := strings.Join(lines, "\n")
+
+	p, arg, n, ok := callAt(text)
+	if !ok {
+		return Loc{}, 0, Loc{}, false
+	}
+	loc := func(i int) Loc {
+		lineStart := strings.LastIndexByte(text[:i], '\n') + 1
+		return Loc{utf8.RuneCountInString(text[lineStart:i]), first + strings.Count(text[:i], "\n")}
+	}
+	return loc(p), arg, loc(n), true
+}
+
+// showSignature looks up the function of the call the cursor is in, in the
+// background, and opens the signature box for it
+// Nothing is looked up while the box already shows that call
+func (v *View) showSignature() {
+	paren, _, name, ok := v.cursorCall()
+	if !ok {
+		return
+	}
+	if signatureBox.open && signatureBox.view == v && signatureBox.paren == paren {
+		return
+	}
+	if name.Y != paren.Y {
+		return
+	}
+	buf := v.Buf
+	path, bufName, text := buf.Path, buf.GetName(), buf.String()
+	offset := ByteOffset(name, buf)
+	callee := []rune(buf.Line(name.Y))[name.X:paren.X]
+	go func() {
+		desc, err := guruDescribe(path, bufName, text, offset)
+		if err != nil || desc.Detail != "value" || desc.Value == nil {
+			return
+		}
+		params, results, ok := splitSignature(desc.Value.Type)
+		if !ok {
+			return
+		}
+		jobs <- JobFunction{func(string, ...string) {
+			if !viewIsOpen(v) || CurView() != v || v.Buf != buf {
+				return
+			}
+			if p, arg, _, ok := v.cursorCall(); ok && p == paren {
+				signatureBox.Open(v, paren, strings.TrimSpace(string(callee)), params, results, arg)
+			}
+		}, "", nil}
+	}()
+}
+
+// Open opens the signature box for the call with the open paren at a location
+func (s *SignatureBox) Open(v *View, paren Loc, name string, params []string, results string, arg int) {
+	s.open = true
+	s.view, s.paren, s.name, s.params, s.results, s.arg = v, paren, name, params, results, arg
+}
+
+// Reset closes the signature box
+func (s *SignatureBox) Reset() {
+	s.open = false
+	s.view = nil
+	s.params = nil
+}
+
+// update follows the argument under the cursor, and closes the box when the
+// cursor left the call
+func (s *SignatureBox) update() {
+	if !s.open {
+		return
+	}
+	if !viewIsOpen(s.view) || CurView() != s.view {
+		s.Reset()
+		return
+	}
+	paren, arg, _, ok := s.view.cursorCall()
+	if !ok || paren != s.paren {
+		s.Reset()
+		return
+	}
+	s.arg = arg
+}
+
+// Display draws the signature box on the line above the cursor, or below it
+// on the first line of the view
+func (s *SignatureBox) Display() {
+	s.update()
+	if !s.open {
+		return
+	}
+	style := defStyle.Reverse(true)
+	if st, ok := colorscheme["hover"]; ok {
+		style = st
+	}
+	active := activeParam(s.params, s.arg)
+
+	type part struct {
+		text  string
+		style tcell.Style
+	}
+	parts := []part{{" " + s.name + "(", style}}
+	for i, p := range s.params {
+		if i > 0 {
+			parts = append(parts, part{", ", style})
+		}
+		if i == active {
+			parts = append(parts, part{p, style.Bold(true).Underline(true)})
+		} else {
+			parts = append(parts, part{p, style})
+		}
+	}
+	end := ")"
+	if s.results != "" {
+		end += " " + s.results
+	}
+	parts = append(parts, part{end + " ", style})
+
+	width := 0
+	for _, p := range parts {
+		width += runewidth.StringWidth(p.text)
+	}
+	w, _ := screen.Size()
+	v := s.view
+	// The box starts above the name of the function if the paren is on the
+	// cursor line
+	x := cursorGX
+	if s.paren.Y == v.Cursor.Y {
+		x -= runewidth.StringWidth(string([]rune(v.Buf.Line(v.Cursor.Y))[s.paren.X:v.Cursor.X])) + runewidth.StringWidth(s.name) + 1
+	}
+	x = Max(0, Min(x, w-width))
+	y := cursorGY - 1
+	if y < v.y {
+		y = cursorGY + 1
+	}
+
+	for _, p := range parts {
+		for _, r := range p.text {
+			if x >= w {
+				return
+			}
+			screen.SetContent(x, y, r, nil, p.style)
+			x += runewidth.RuneWidth(r)
+		}
+	}
+}
+
+// signatureActions are the actions of the signature keymap context
+var signatureActions = map[string]func(s *SignatureBox){
+	"Cancel": (*SignatureBox).Reset,
+}
+
+// HandleEvent runs the actions of the signature context
+// Other keys are left to the view, which keeps the box up to date
+func (s *SignatureBox) HandleEvent(e *tcell.EventKey) bool {
+	names := contextActions("signature", e)
+	if names == nil {
+		return false
+	}
+	for _, name := range names {
+		if action, ok := signatureActions[name]; ok {
+			action(s)
+		}
+	}
+	return true
+}
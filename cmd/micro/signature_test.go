@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallAt(t *testing.T) {
+	tests := []struct {
+		text  string
+		ok    bool
+		arg   int
+		ident string
+	}{
+		{"x := foo(", true, 0, "foo"},
+		{"x := pkg.Foo(a, b", true, 1, "Foo"},
+		{"foo(a, bar(1, 2), ", true, 2, "foo"},
+		{"foo(a, bar(1, ", true, 1, "bar"},
+		{"foo(\"a, (b\", 'c', ", true, 2, "foo"},
+		{"foo(`a,\n(b`, // c, (\n\tx, /* y, */ ", true, 2, "foo"},
+		{"foo(a, []int{1, 2", false, 0, ""},
+		{"foo(a)", false, 0, ""},
+		{"if (", false, 0, ""},
+		{"func Foo(a int, ", false, 0, ""},
+		{"func (r *T) Foo(a int, ", false, 0, ""},
+		{"\tgo run(a, ", true, 1, "run"},
+		{"x := (a + ", false, 0, ""},
+		{"fmt.Println(s.größe(", true, 0, "größe"},
+	}
+	for _, test := range tests {
+		paren, arg, name, ok := callAt(test.text)
+		if ok != test.ok {
+			t.Errorf("callAt %q: got ok %v", test.text, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if ident := test.text[name:paren]; arg != test.arg || ident != test.ident {
+			t.Errorf("callAt %q: got %q argument %d, want %q argument %d", test.text, ident, arg, test.ident, test.arg)
+		}
+	}
+}
+
+func TestSplitSignature(t *testing.T) {
+	tests := []struct {
+		typ     string
+		params  []string
+		results string
+	}{
+		{"func()", nil, ""},
+		{"func(name string, n int) error", []string{"name string", "n int"}, "error"},
+		{"func(f func(a, b int) bool, m map[string]struct{x, y int}) (int, error)",
+			[]string{"f func(a, b int) bool", "m map[string]struct{x, y int}"}, "(int, error)"},
+		{"func(format string, a ...interface{})", []string{"format string", "a ...interface{}"}, ""},
+	}
+	for _, test := range tests {
+		params, results, ok := splitSignature(test.typ)
+		if !ok || !reflect.DeepEqual(params, test.params) || results != test.results {
+			t.Errorf("splitSignature %q: got %q %q %v", test.typ, params, results, ok)
+		}
+	}
+	if _, _, ok := splitSignature("int"); ok {
+		t.Errorf("splitSignature accepted a type which isn't a function")
+	}
+	if i := activeParam([]string{"format string", "a ...interface{}"}, 4); i != 1 {
+		t.Errorf("activeParam of a variadic argument: got %d", i)
+	}
+	if i := activeParam([]string{"a int"}, 1); i != -1 {
+		t.Errorf("activeParam of an extra argument: got %d", i)
+	}
+}
@@ -22,6 +22,9 @@ type LeafNode struct {
 	view *View
 
 	parent *SplitTree
+	// The share of the free space of the parent the split gets, see
+	// SplitTree.childSizes
+	weight float64
 }
 
 // NewLeafNode returns a new leaf node containing the given view
@@ -47,10 +50,26 @@ type SplitTree struct {
 	height     int
 	lockWidth  bool
 	lockHeight bool
+	// The share of the free space of the parent the split gets
+	weight float64
 
 	tabNum int
 }
 
+// splitView returns a view of the buffer for a new split next to the leaf,
+// which starts with the jump list of the view of the leaf
+func (l *LeafNode) splitView(buf *Buffer) *View {
+	v := NewView(buf)
+	v.jumps = l.view.jumps.Copy()
+	return v
+}
+
+// shareWeight gives half of the size of the leaf to a new split next to it
+func (l *LeafNode) shareWeight(n *LeafNode) {
+	l.weight /= 2
+	n.weight = l.weight
+}
+
 // VSplit creates a vertical split
 func (l *LeafNode) VSplit(buf *Buffer, splitIndex int) {
 	if splitIndex < 0 {
@@ -63,12 +82,13 @@ func (l *LeafNode) VSplit(buf *Buffer, splitIndex int) {
 			splitIndex = len(l.parent.children)
 		}
 
-		newView := NewView(buf)
+		newView := l.splitView(buf)
 		newView.TabNum = l.parent.tabNum
 
 		l.parent.children = append(l.parent.children, nil)
 		copy(l.parent.children[splitIndex+1:], l.parent.children[splitIndex:])
 		l.parent.children[splitIndex] = NewLeafNode(newView, l.parent)
+		l.shareWeight(l.parent.children[splitIndex].(*LeafNode))
 
 		tab.views = append(tab.views, nil)
 		copy(tab.views[splitIndex+1:], tab.views[splitIndex:])
@@ -84,7 +104,7 @@ func (l *LeafNode) VSplit(buf *Buffer, splitIndex int) {
 		s.kind = VerticalSplit
 		s.parent = l.parent
 		s.tabNum = l.parent.tabNum
-		newView := NewView(buf)
+		newView := l.splitView(buf)
 		newView.TabNum = l.parent.tabNum
 		if splitIndex == 1 {
 			s.children = []Node{l, NewLeafNode(newView, s)}
@@ -93,6 +113,8 @@ func (l *LeafNode) VSplit(buf *Buffer, splitIndex int) {
 		}
 		l.parent.children[search(l.parent.children, l)] = s
 		l.parent = s
+		// The new split takes the place and the size of the leaf
+		s.weight, l.weight = l.weight, 0
 
 		tab.views = append(tab.views, nil)
 		copy(tab.views[splitIndex+1:], tab.views[splitIndex:])
@@ -116,12 +138,13 @@ func (l *LeafNode) HSplit(buf *Buffer, splitIndex int) {
 			splitIndex = len(l.parent.children)
 		}
 
-		newView := NewView(buf)
+		newView := l.splitView(buf)
 		newView.TabNum = l.parent.tabNum
 
 		l.parent.children = append(l.parent.children, nil)
 		copy(l.parent.children[splitIndex+1:], l.parent.children[splitIndex:])
 		l.parent.children[splitIndex] = NewLeafNode(newView, l.parent)
+		l.shareWeight(l.parent.children[splitIndex].(*LeafNode))
 
 		tab.views = append(tab.views, nil)
 		copy(tab.views[splitIndex+1:], tab.views[splitIndex:])
@@ -137,7 +160,7 @@ func (l *LeafNode) HSplit(buf *Buffer, splitIndex int) {
 		s.kind = HorizontalSplit
 		s.tabNum = l.parent.tabNum
 		s.parent = l.parent
-		newView := NewView(buf)
+		newView := l.splitView(buf)
 		newView.TabNum = l.parent.tabNum
 		newView.Num = len(tab.views)
 		if splitIndex == 1 {
@@ -147,6 +170,8 @@ func (l *LeafNode) HSplit(buf *Buffer, splitIndex int) {
 		}
 		l.parent.children[search(l.parent.children, l)] = s
 		l.parent = s
+		// The new split takes the place and the size of the leaf
+		s.weight, l.weight = l.weight, 0
 
 		tab.views = append(tab.views, nil)
 		copy(tab.views[splitIndex+1:], tab.views[splitIndex:])
@@ -188,6 +213,7 @@ func (s *SplitTree) Cleanup() {
 				if child, ok := n.children[0].(*LeafNode); ok {
 					s.children[i] = child
 					child.parent = s
+					child.weight = n.weight
 					continue
 				}
 			}
@@ -198,86 +224,24 @@ func (s *SplitTree) Cleanup() {
 
 // ResizeSplits resizes all the splits correctly
 func (s *SplitTree) ResizeSplits() {
-	lockedWidth := 0
-	lockedHeight := 0
-	lockedChildren := 0
-	for _, node := range s.children {
-		if n, ok := node.(*LeafNode); ok {
-			if s.kind == VerticalSplit {
-				if n.view.LockWidth {
-					lockedWidth += n.view.Width
-					lockedChildren++
-				}
-			} else {
-				if n.view.LockHeight {
-					lockedHeight += n.view.Height
-					lockedChildren++
-				}
-			}
-		} else if n, ok := node.(*SplitTree); ok {
-			if s.kind == VerticalSplit {
-				if n.lockWidth {
-					lockedWidth += n.width
-					lockedChildren++
-				}
-			} else {
-				if n.lockHeight {
-					lockedHeight += n.height
-					lockedChildren++
-				}
-			}
-		}
-	}
 	x, y := 0, 0
-	for _, node := range s.children {
-		if n, ok := node.(*LeafNode); ok {
-			if s.kind == VerticalSplit {
-				if !n.view.LockWidth {
-					n.view.Width = (s.width - lockedWidth) / (len(s.children) - lockedChildren)
-				}
-				n.view.Height = s.height
-
-				n.view.x = s.x + x
-				n.view.y = s.y
-				x += n.view.Width
-			} else {
-				if !n.view.LockHeight {
-					n.view.Height = (s.height - lockedHeight) / (len(s.children) - lockedChildren)
-				}
-				n.view.Width = s.width
-
-				n.view.y = s.y + y
-				n.view.x = s.x
-				y += n.view.Height
-			}
-			if n.view.Buf.Settings["statusline"].(bool) {
-				n.view.Height--
-			}
-
-			n.view.ToggleTabbar()
-			n.view.matches = Match(n.view)
-		} else if n, ok := node.(*SplitTree); ok {
-			if s.kind == VerticalSplit {
-				if !n.lockWidth {
-					n.width = (s.width - lockedWidth) / (len(s.children) - lockedChildren)
-				}
-				n.height = s.height
-
-				n.x = s.x + x
-				n.y = s.y
-				x += n.width
-			} else {
-				if !n.lockHeight {
-					n.height = (s.height - lockedHeight) / (len(s.children) - lockedChildren)
-				}
-				n.width = s.width
-
-				n.y = s.y + y
-				n.x = s.x
-				y += n.height
-			}
+	for i, size := range s.childSizes() {
+		width, height := size, s.height
+		if s.kind == HorizontalSplit {
+			width, height = s.width, size
+		}
+		if n, ok := s.children[i].(*LeafNode); ok {
+			n.view.place(s.x+x, s.y+y, width, height)
+		} else if n, ok := s.children[i].(*SplitTree); ok {
+			n.x, n.y = s.x+x, s.y+y
+			n.width, n.height = width, height
 			n.ResizeSplits()
 		}
+		if s.kind == VerticalSplit {
+			x += size
+		} else {
+			y += size
+		}
 	}
 }
 
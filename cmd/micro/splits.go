@@ -0,0 +1,441 @@
+package main
+
+import (
+	"github.com/zyedidia/tcell"
+)
+
+const (
+	// minSplitWidth and minSplitHeight are the smallest size a split can be
+	// resized to, the height counting the statusline
+	minSplitWidth  = 8
+	minSplitHeight = 2
+	// splitResizeStep is the number of columns or rows the resize actions
+	// move a border by
+	splitResizeStep = 2
+)
+
+// place puts the view in the given area of the screen, which includes its
+// statusline and its minimap
+func (v *View) place(x, y, width, height int) {
+	v.x, v.y = x, y
+	v.Height = height
+	v.mapWidth = v.minimapColumns(width)
+	v.Width = width - v.mapWidth
+	if v.Buf.Settings["statusline"].(bool) {
+		v.Height--
+	}
+	v.ToggleTabbar()
+	v.matches = Match(v)
+}
+
+// nodeSize returns the size of a node along a kind of split, and whether that
+// size is locked
+func nodeSize(n Node, kind SplitType) (int, bool) {
+	switch n := n.(type) {
+	case *LeafNode:
+		v := n.view
+		if kind == VerticalSplit {
+			return v.Width + v.mapWidth, v.LockWidth
+		}
+		height := v.Height
+		if v.Buf.Settings["statusline"].(bool) {
+			height++
+		}
+		if v.y == 1 && len(tabs) > 1 {
+			// The tabbar row belongs to the split
+			height++
+		}
+		return height, v.LockHeight
+	case *SplitTree:
+		if kind == VerticalSplit {
+			return n.width, n.lockWidth
+		}
+		return n.height, n.lockHeight
+	}
+	return 0, false
+}
+
+// setNodeSize changes the size a node is locked to along a kind of split
+func setNodeSize(n Node, kind SplitType, size int) {
+	switch n := n.(type) {
+	case *LeafNode:
+		old, _ := nodeSize(n, kind)
+		if kind == VerticalSplit {
+			n.view.Width += size - old
+		} else {
+			n.view.Height += size - old
+		}
+	case *SplitTree:
+		if kind == VerticalSplit {
+			n.width = size
+		} else {
+			n.height = size
+		}
+	}
+}
+
+// nodeWeight returns the share of the free space of its parent a node gets
+func nodeWeight(n Node) float64 {
+	w := 0.0
+	switch n := n.(type) {
+	case *LeafNode:
+		w = n.weight
+	case *SplitTree:
+		w = n.weight
+	}
+	if w <= 0 {
+		return 1
+	}
+	return w
+}
+
+// setNodeWeight sets the share of the free space of its parent a node gets,
+// where 0 is the default share
+func setNodeWeight(n Node, w float64) {
+	switch n := n.(type) {
+	case *LeafNode:
+		n.weight = w
+	case *SplitTree:
+		n.weight = w
+	}
+}
+
+// childSizes returns the size of each child along the split
+// Locked children keep their size, and the others share the rest of the
+// space by their weights, so that resized splits keep their proportions when
+// the terminal is resized
+func (s *SplitTree) childSizes() []int {
+	total := s.width
+	if s.kind == HorizontalSplit {
+		total = s.height
+	}
+	sizes := make([]int, len(s.children))
+	var free []int
+	weights := 0.0
+	for i, n := range s.children {
+		if size, locked := nodeSize(n, s.kind); locked {
+			sizes[i] = size
+			total -= size
+		} else {
+			free = append(free, i)
+			weights += nodeWeight(n)
+		}
+	}
+	left := total
+	for k, i := range free {
+		if k == len(free)-1 {
+			// The last split gets the columns left by rounding
+			sizes[i] = left
+		} else {
+			sizes[i] = int(float64(total) * nodeWeight(s.children[i]) / weights)
+			left -= sizes[i]
+		}
+	}
+	return sizes
+}
+
+// resizeChild moves the border between the children i and i+1 of the split
+// by delta cells, so that child i grows by delta and child i+1 shrinks by it
+// The sizes of the children are kept as their weights
+func (s *SplitTree) resizeChild(i, delta int) {
+	if i < 0 || i+1 >= len(s.children) {
+		return
+	}
+	min := minSplitWidth
+	if s.kind == HorizontalSplit {
+		min = minSplitHeight
+	}
+	sizes := s.childSizes()
+	delta = Max(delta, Min(0, min-sizes[i]))
+	delta = Min(delta, Max(0, sizes[i+1]-min))
+	if delta == 0 {
+		return
+	}
+	sizes[i] += delta
+	sizes[i+1] -= delta
+	for k, n := range s.children {
+		if _, locked := nodeSize(n, s.kind); locked {
+			setNodeSize(n, s.kind, sizes[k])
+		} else {
+			setNodeWeight(n, float64(sizes[k]))
+		}
+	}
+}
+
+// equalize gives every split which isn't locked the same share of the space
+func (s *SplitTree) equalize() {
+	for _, n := range s.children {
+		setNodeWeight(n, 0)
+		if t, ok := n.(*SplitTree); ok {
+			t.equalize()
+		}
+	}
+}
+
+// enclosingSplit returns the nearest split above the leaf which has more
+// than one child, and the index of the child which holds the leaf
+// Unless anyKind is set only splits of the given kind are looked at
+func (l *LeafNode) enclosingSplit(kind SplitType, anyKind bool) (*SplitTree, int) {
+	var node Node = l
+	for s := l.parent; s != nil; s = s.parent {
+		if len(s.children) > 1 && (anyKind || s.kind == kind) {
+			return s, search(s.children, node)
+		}
+		node = s
+	}
+	return nil, 0
+}
+
+// borderAt returns the split and the index of the child before the border
+// between two splits at a cell of the screen, or nil if there is no border
+// there
+// The border of two side by side splits is the divider column of the right
+// one, and the border of two stacked splits is the statusline of the top one
+func (s *SplitTree) borderAt(x, y int) (*SplitTree, int) {
+	if x < s.x || x >= s.x+s.width || y < s.y || y >= s.y+s.height {
+		return nil, 0
+	}
+	pos := 0
+	sizes := s.childSizes()
+	for i, size := range sizes {
+		if s.kind == VerticalSplit {
+			if i > 0 && x == s.x+pos {
+				return s, i - 1
+			}
+		} else if i < len(sizes)-1 && y == s.y+pos+size-1 {
+			return s, i
+		}
+		pos += size
+	}
+	for _, n := range s.children {
+		if t, ok := n.(*SplitTree); ok {
+			if found, i := t.borderAt(x, y); found != nil {
+				return found, i
+			}
+		}
+	}
+	return nil, 0
+}
+
+// A splitDrag is the border between two splits which is being dragged with
+// the mouse
+type splitDrag struct {
+	tree  *SplitTree
+	index int
+}
+
+// splitMouse moves the border between two splits when it is dragged, and
+// returns whether the mouse event was for a border
+func (t *Tab) splitMouse(v *View, e *tcell.EventMouse) bool {
+	x, y := e.Position()
+	switch e.Buttons() {
+	case tcell.Button1:
+		if t.drag == nil {
+			if !v.mouseReleased || t.zoomed != nil {
+				return false
+			}
+			s, i := t.tree.borderAt(x, y)
+			if s == nil {
+				return false
+			}
+			t.drag = &splitDrag{s, i}
+			v.mouseReleased = false
+			return true
+		}
+		s, i := t.drag.tree, t.drag.index
+		pos := 0
+		sizes := s.childSizes()
+		for k := 0; k <= i; k++ {
+			pos += sizes[k]
+		}
+		if s.kind == VerticalSplit {
+			// The divider of the next split follows the mouse
+			s.resizeChild(i, x-(s.x+pos))
+		} else {
+			// The statusline of the split follows the mouse
+			s.resizeChild(i, y-(s.y+pos-1))
+		}
+		t.Resize()
+		return true
+	case tcell.ButtonNone:
+		if t.drag != nil {
+			t.drag = nil
+			v.mouseReleased = true
+			return true
+		}
+	}
+	return false
+}
+
+// shownViews returns the views of the tab which are drawn: only the zoomed
+// view if there is one
+// The zoom ends when another view of the tab becomes the current one
+func (t *Tab) shownViews() []*View {
+	if t.zoomed == nil {
+		return t.views
+	}
+	if t.CurView < len(t.views) && t.views[t.CurView] == t.zoomed {
+		return []*View{t.zoomed}
+	}
+	t.zoomed = nil
+	t.Resize()
+	return t.views
+}
+
+// resizeSplit grows the current split by delta columns, or rows if height is
+// set, moving the border with the next split, or the previous one for the
+// last split
+func (v *View) resizeSplit(delta int, height bool) {
+	kind := SplitType(VerticalSplit)
+	if height {
+		kind = HorizontalSplit
+	}
+	s, i := v.splitNode.enclosingSplit(kind, false)
+	if s == nil {
+		messenger.Message("No split to resize against")
+		return
+	}
+	if i < len(s.children)-1 {
+		s.resizeChild(i, delta)
+	} else {
+		s.resizeChild(i-1, -delta)
+	}
+	tabs[v.TabNum].zoomed = nil
+	tabs[v.TabNum].Resize()
+}
+
+// GrowSplitWidth makes the current split wider
+func (v *View) GrowSplitWidth(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("GrowSplitWidth", v) {
+		return false
+	}
+
+	v.resizeSplit(splitResizeStep, false)
+
+	if usePlugin {
+		return PostActionCall("GrowSplitWidth", v)
+	}
+	return true
+}
+
+// ShrinkSplitWidth makes the current split narrower
+func (v *View) ShrinkSplitWidth(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ShrinkSplitWidth", v) {
+		return false
+	}
+
+	v.resizeSplit(-splitResizeStep, false)
+
+	if usePlugin {
+		return PostActionCall("ShrinkSplitWidth", v)
+	}
+	return true
+}
+
+// GrowSplitHeight makes the current split taller
+func (v *View) GrowSplitHeight(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("GrowSplitHeight", v) {
+		return false
+	}
+
+	v.resizeSplit(splitResizeStep, true)
+
+	if usePlugin {
+		return PostActionCall("GrowSplitHeight", v)
+	}
+	return true
+}
+
+// ShrinkSplitHeight makes the current split shorter
+func (v *View) ShrinkSplitHeight(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ShrinkSplitHeight", v) {
+		return false
+	}
+
+	v.resizeSplit(-splitResizeStep, true)
+
+	if usePlugin {
+		return PostActionCall("ShrinkSplitHeight", v)
+	}
+	return true
+}
+
+// EqualizeSplits gives all the splits of the tab the same size, apart from
+// those with a locked size such as the outline
+func (v *View) EqualizeSplits(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("EqualizeSplits", v) {
+		return false
+	}
+
+	t := tabs[v.TabNum]
+	t.tree.equalize()
+	t.zoomed = nil
+	t.Resize()
+
+	if usePlugin {
+		return PostActionCall("EqualizeSplits", v)
+	}
+	return true
+}
+
+// ZoomSplit shows the current split over the whole tab, or shows all the
+// splits again
+func (v *View) ZoomSplit(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("ZoomSplit", v) {
+		return false
+	}
+
+	t := tabs[v.TabNum]
+	if t.zoomed != nil {
+		t.zoomed = nil
+	} else if len(t.views) > 1 {
+		t.zoomed = v
+	}
+	t.Resize()
+
+	if usePlugin {
+		return PostActionCall("ZoomSplit", v)
+	}
+	return true
+}
+
+// SwapSplit swaps the current split with the next one, or with the previous
+// one if it is the last split
+func (v *View) SwapSplit(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("SwapSplit", v) {
+		return false
+	}
+
+	if s, i := v.splitNode.enclosingSplit(VerticalSplit, true); s != nil {
+		j := i + 1
+		if j == len(s.children) {
+			j = i - 1
+		}
+		s.children[i], s.children[j] = s.children[j], s.children[i]
+		tabs[v.TabNum].Resize()
+	}
+
+	if usePlugin {
+		return PostActionCall("SwapSplit", v)
+	}
+	return true
+}
+
+// RotateSplits moves every split next to the current one a place forward,
+// and the last one to the first place
+func (v *View) RotateSplits(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("RotateSplits", v) {
+		return false
+	}
+
+	if s, _ := v.splitNode.enclosingSplit(VerticalSplit, true); s != nil {
+		n := len(s.children)
+		s.children = append(s.children[n-1:], s.children[:n-1]...)
+		tabs[v.TabNum].Resize()
+	}
+
+	if usePlugin {
+		return PostActionCall("RotateSplits", v)
+	}
+	return true
+}
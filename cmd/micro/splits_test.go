@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSizes(t *testing.T) {
+	a, b, c := &SplitTree{}, &SplitTree{}, &SplitTree{width: 20, lockWidth: true}
+	s := &SplitTree{kind: VerticalSplit, width: 100, children: []Node{a, b, c}}
+
+	if sizes := s.childSizes(); !reflect.DeepEqual(sizes, []int{40, 40, 20}) {
+		t.Errorf("childSizes: got %v", sizes)
+	}
+
+	s.resizeChild(0, 10)
+	if sizes := s.childSizes(); !reflect.DeepEqual(sizes, []int{50, 30, 20}) {
+		t.Errorf("resizeChild(0, 10): got %v", sizes)
+	}
+	// The proportions are kept when the split is resized
+	s.width = 180
+	if sizes := s.childSizes(); !reflect.DeepEqual(sizes, []int{100, 60, 20}) {
+		t.Errorf("childSizes after a resize: got %v", sizes)
+	}
+
+	// Locked splits keep the size they are resized to
+	s.resizeChild(1, 5)
+	if sizes := s.childSizes(); !reflect.DeepEqual(sizes, []int{100, 65, 15}) || c.width != 15 {
+		t.Errorf("resizeChild(1, 5): got %v", s.childSizes())
+	}
+
+	// Splits don't get smaller than the minimum
+	s.resizeChild(0, 200)
+	if sizes := s.childSizes(); !reflect.DeepEqual(sizes, []int{157, minSplitWidth, 15}) {
+		t.Errorf("resizeChild(0, 200): got %v", sizes)
+	}
+
+	s.equalize()
+	if sizes := s.childSizes(); !reflect.DeepEqual(sizes, []int{82, 83, 15}) {
+		t.Errorf("equalize: got %v", sizes)
+	}
+}
+
+func TestBorderAt(t *testing.T) {
+	top, bottom := &SplitTree{}, &SplitTree{}
+	right := &SplitTree{kind: HorizontalSplit, children: []Node{top, bottom}}
+	left := &SplitTree{}
+	s := &SplitTree{kind: VerticalSplit, width: 80, height: 20, children: []Node{left, right}}
+	s.ResizeSplits()
+
+	tests := []struct {
+		x, y  int
+		tree  *SplitTree
+		index int
+	}{
+		{40, 5, s, 0},
+		{50, 9, right, 0},
+		{39, 9, nil, 0},
+		{50, 10, nil, 0},
+	}
+	for _, test := range tests {
+		if tree, i := s.borderAt(test.x, test.y); tree != test.tree || i != test.index {
+			t.Errorf("borderAt(%d, %d): got %p %d, want %p %d", test.x, test.y, tree, i, test.tree, test.index)
+		}
+	}
+}
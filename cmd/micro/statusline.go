@@ -19,6 +19,11 @@ func (sline *Statusline) Display() {
 
 	file := sline.view.Buf.GetName()
 
+	// Show the mode of the vim layer first
+	if globalSettings["vimmode"].(bool) {
+		file = "-- " + sline.view.vimModeName() + " -- " + file
+	}
+
 	// If the buffer is dirty (has been modified) write a little '+'
 	if sline.view.Buf.IsModified {
 		file += " +"
@@ -36,6 +41,11 @@ func (sline *Statusline) Display() {
 	// Add the filetype
 	file += " " + sline.view.Buf.FileType()
 
+	// Show how many merge conflicts are left to resolve
+	if n := len(sline.view.Buf.Conflicts()); n > 0 {
+		file += " [" + conflictMessage(n) + "]"
+	}
+
 	rightText := ""
 	if len(helpBinding) > 0 {
 		rightText = helpBinding + " for help "
@@ -44,6 +54,11 @@ func (sline *Statusline) Display() {
 		}
 	}
 
+	// Show the keys of a chord which is being typed
+	if chord := sline.view.PendingChord(); chord != "" {
+		rightText = chord + " ... "
+	}
+
 	statusLineStyle := defStyle.Reverse(true)
 	if style, ok := colorscheme["statusline"]; ok {
 		statusLineStyle = style
@@ -56,11 +71,13 @@ func (sline *Statusline) Display() {
 		screen.SetContent(viewX, y, ' ', nil, statusLineStyle)
 		viewX++
 	}
-	for x := 0; x < sline.view.Width; x++ {
+	// The statusline runs under the minimap too
+	width := sline.view.Width + sline.view.mapWidth
+	for x := 0; x < width; x++ {
 		if x < len(fileRunes) {
 			screen.SetContent(viewX+x, y, fileRunes[x], nil, statusLineStyle)
-		} else if x >= sline.view.Width-len(rightText) && x < len(rightText)+sline.view.Width-len(rightText) {
-			screen.SetContent(viewX+x, y, []rune(rightText)[x-sline.view.Width+len(rightText)], nil, statusLineStyle)
+		} else if x >= width-len(rightText) && x < len(rightText)+width-len(rightText) {
+			screen.SetContent(viewX+x, y, []rune(rightText)[x-width+len(rightText)], nil, statusLineStyle)
 		} else {
 			screen.SetContent(viewX+x, y, ' ', nil, statusLineStyle)
 		}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/zyedidia/tcell"
+)
+
+// stickyMaxRows is the most rows the sticky scroll header can take
+const stickyMaxRows = 5
+
+// scopeState caches the scopes of a buffer: each range starts on the line
+// which opens a scope, such as a func signature, and ends on its last line
+type scopeState struct {
+	ranges   []Fold
+	filetype string
+	version  int
+	valid    bool
+}
+
+// goScopeRanges returns the ranges of the funcs, the if, for, switch and
+// select statements, their case clauses and the struct and interface types of
+// Go source
+// The line of the closing brace is left out
+func goScopeRanges(src string) ([]Fold, bool) {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, "", src, 0)
+	if f == nil {
+		return nil, false
+	}
+	var ranges []Fold
+	add := func(start, end token.Pos, closing bool) {
+		ranges = appendLineRange(ranges, fset, start, end, closing)
+	}
+	body := func(start token.Pos, b *ast.BlockStmt) {
+		if b != nil {
+			add(start, b.Rbrace, true)
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			body(n.Pos(), n.Body)
+		case *ast.FuncLit:
+			body(n.Body.Lbrace, n.Body)
+		case *ast.IfStmt:
+			body(n.Pos(), n.Body)
+			if b, ok := n.Else.(*ast.BlockStmt); ok {
+				body(b.Lbrace, b)
+			}
+		case *ast.ForStmt:
+			body(n.Pos(), n.Body)
+		case *ast.RangeStmt:
+			body(n.Pos(), n.Body)
+		case *ast.SwitchStmt:
+			body(n.Pos(), n.Body)
+		case *ast.TypeSwitchStmt:
+			body(n.Pos(), n.Body)
+		case *ast.SelectStmt:
+			body(n.Pos(), n.Body)
+		case *ast.CaseClause:
+			add(n.Case, n.End(), false)
+		case *ast.CommClause:
+			add(n.Case, n.End(), false)
+		case *ast.GenDecl:
+			if n.Tok != token.TYPE {
+				break
+			}
+			for _, spec := range n.Specs {
+				start := spec.Pos()
+				if !n.Lparen.IsValid() {
+					start = n.Pos()
+				}
+				switch t := spec.(*ast.TypeSpec).Type.(type) {
+				case *ast.StructType:
+					add(start, t.Fields.Closing, true)
+				case *ast.InterfaceType:
+					add(start, t.Methods.Closing, true)
+				}
+			}
+		}
+		return true
+	})
+	return ranges, true
+}
+
+// scopeRanges returns the scopes of the buffer, sorted by their first line,
+// outer scopes first
+// Go files are read with the syntax tree, and other files by indentation
+func (b *Buffer) scopeRanges() []Fold {
+	s := &b.scopes
+	if s.valid && s.filetype == b.FileType() && s.version == b.version {
+		return s.ranges
+	}
+	lines := b.Lines(0, b.NumLines)
+	var ranges []Fold
+	ok := false
+	if b.FileType() == "go" {
+		ranges, ok = goScopeRanges(strings.Join(lines, "\n"))
+	}
+	if !ok {
+		ranges = indentFoldRanges(lines, int(b.Settings["tabsize"].(float64)))
+	}
+	s.ranges = sortFolds(ranges)
+	s.filetype, s.version, s.valid = b.FileType(), b.version, true
+	return s.ranges
+}
+
+// enclosingScopes returns the scopes around a line, outer scopes first,
+// leaving out the scopes which start on the line itself
+func (b *Buffer) enclosingScopes(line int) []Fold {
+	var scopes []Fold
+	for _, r := range b.scopeRanges() {
+		if r.Start >= line {
+			break
+		}
+		if line <= r.End && (len(scopes) == 0 || scopes[len(scopes)-1].Start != r.Start) {
+			scopes = append(scopes, r)
+		}
+	}
+	return scopes
+}
+
+// stickyLines returns the lines shown in the sticky scroll header: the first
+// lines of the scopes around the top line of the view which go on below the
+// header
+// The header never covers the cursor
+func (v *View) stickyLines() []int {
+	if !v.Buf.Settings["stickyscroll"].(bool) || v.Type == vtScratch || v.diff != nil {
+		return nil
+	}
+	scopes := v.Buf.enclosingScopes(v.Topline)
+	if maxRows := Min(stickyMaxRows, v.Height/3); len(scopes) > maxRows {
+		scopes = scopes[:maxRows]
+	}
+	for len(scopes) > 0 && scopes[len(scopes)-1].End < v.lineAtRow(len(scopes)) {
+		scopes = scopes[:len(scopes)-1]
+	}
+	if row := v.Buf.visibleIndex(v.Cursor.Y) - v.Buf.visibleIndex(v.Topline); row >= 0 && row < len(scopes) {
+		scopes = scopes[:row]
+	}
+	lines := make([]int, len(scopes))
+	for i, s := range scopes {
+		lines[i] = s.Start
+	}
+	return lines
+}
+
+// stickyStyle returns the background of the sticky scroll header
+func stickyStyle() tcell.Style {
+	if style, ok := colorscheme["sticky"]; ok {
+		return style
+	}
+	if style, ok := colorscheme["cursor-line"]; ok {
+		fg, _, _ := style.Decompose()
+		return defStyle.Background(fg)
+	}
+	return defStyle.Reverse(true)
+}
+
+// DisplaySticky draws the sticky scroll header over the top rows of the view
+func (v *View) DisplaySticky() {
+	style := stickyStyle()
+	tabSize := int(v.Buf.Settings["tabsize"].(float64))
+	for row, lineN := range v.stickyLines() {
+		y := v.y + row
+		x := v.x
+		for ; x < v.x+v.Width; x++ {
+			screen.SetContent(x, y, ' ', nil, style)
+		}
+		if v.x != 0 {
+			screen.SetContent(v.x, y, '|', nil, defStyle.Reverse(true))
+		}
+		if v.Buf.Settings["ruler"].(bool) {
+			lineNumStyle := style
+			if s, ok := colorscheme["line-number"]; ok {
+				lineNumStyle = withForeground(style, s)
+			}
+			num := strconv.Itoa(lineN + 1)
+			x = v.x + v.lineNumOffset - 1 - len(num)
+			for _, ch := range num {
+				screen.SetContent(x, y, ch, nil, lineNumStyle)
+				x++
+			}
+		}
+
+		line := v.Buf.Line(lineN)
+		var styles []tcell.Style
+		if v.Buf.Settings["syntax"].(bool) {
+			styles = lineStyles(v.Buf.rules, line)
+		}
+		col := 0
+		for i, ch := range []rune(line) {
+			width := StringWidth(string(ch), tabSize)
+			if ch == '\t' {
+				width = tabSize - col%tabSize
+			}
+			x = v.x + v.lineNumOffset + col - v.leftCol
+			col += width
+			if x < v.x+v.lineNumOffset {
+				continue
+			}
+			if x+width > v.x+v.Width {
+				break
+			}
+			if ch == '\t' {
+				continue
+			}
+			cellStyle := style
+			if styles != nil {
+				cellStyle = withForeground(style, styles[i])
+			}
+			screen.SetContent(x, y, ch, nil, cellStyle)
+		}
+	}
+}
+
+// stickyMouse jumps to the scope of a row of the sticky scroll header when it
+// is clicked, and returns whether the mouse event was for the header
+func (v *View) stickyMouse(e *tcell.EventMouse) bool {
+	x, y := e.Position()
+	switch e.Buttons() {
+	case tcell.Button1:
+		if v.stickyClick {
+			return true
+		}
+		headers := v.stickyLines()
+		if !v.mouseReleased || x < v.x || x >= v.x+v.Width || y < v.y || y >= v.y+len(headers) {
+			return false
+		}
+		v.stickyClick = true
+		v.mouseReleased = false
+		lineN := headers[y-v.y]
+		v.recordJump()
+		v.gotoSymbolLoc(Loc{Count(GetLeadingWhitespace(v.Buf.Line(lineN))), lineN})
+		return true
+	case tcell.ButtonNone:
+		if v.stickyClick {
+			v.stickyClick = false
+			v.mouseReleased = true
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScopes(t *testing.T) {
+	src := strings.Join([]string{
+		"package p",
+		"",
+		"type T struct {",
+		"\tA int",
+		"}",
+		"",
+		"func f(x int) {",
+		"\tif x > 0 {",
+		"\t\tg()",
+		"\t} else {",
+		"\t\th()",
+		"\t}",
+		"\tswitch x {",
+		"\tcase 1:",
+		"\t\tg()",
+		"\t}",
+		"}",
+	}, "\n")
+	ranges, ok := goScopeRanges(src)
+	ranges = sortFolds(ranges)
+	want := []Fold{{2, 3}, {6, 15}, {7, 8}, {9, 10}, {12, 14}, {13, 14}}
+	if !ok || !reflect.DeepEqual(ranges, want) {
+		t.Errorf("goScopeRanges: got %v %v, want %v", ranges, ok, want)
+	}
+
+	b := &Buffer{Settings: map[string]interface{}{"filetype": "go"}}
+	b.scopes = scopeState{ranges: ranges, filetype: "go", valid: true}
+	tests := []struct {
+		line int
+		want []Fold
+	}{
+		{14, []Fold{{6, 15}, {12, 14}, {13, 14}}},
+		{9, []Fold{{6, 15}}},
+		{6, nil},
+		{3, []Fold{{2, 3}}},
+	}
+	for _, test := range tests {
+		if scopes := b.enclosingScopes(test.line); !reflect.DeepEqual(scopes, test.want) {
+			t.Errorf("enclosingScopes(%d): got %v, want %v", test.line, scopes, test.want)
+		}
+	}
+}
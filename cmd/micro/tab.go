@@ -15,6 +15,10 @@ type Tab struct {
 	CurView int
 
 	tree *SplitTree
+	// The view shown over the whole tab by ZoomSplit
+	zoomed *View
+	// The border between two splits which is being dragged
+	drag *splitDrag
 }
 
 // NewTabFromView creates a new tab and puts the given view in the tab
@@ -40,6 +44,21 @@ func NewTabFromView(v *View) *Tab {
 	return t
 }
 
+// AddTabWithBuffer opens the given buffer in a new tab and makes it the current tab
+func AddTabWithBuffer(buf *Buffer) {
+	tab := NewTabFromView(NewView(buf))
+	tab.SetNum(len(tabs))
+	tabs = append(tabs, tab)
+	curTab = len(tabs) - 1
+	if len(tabs) == 2 {
+		for _, t := range tabs {
+			for _, v := range t.views {
+				v.ToggleTabbar()
+			}
+		}
+	}
+}
+
 // SetNum sets all this tab's views to have the correct tab number
 func (t *Tab) SetNum(num int) {
 	t.tree.tabNum = num
@@ -62,6 +81,9 @@ func (t *Tab) Resize() {
 	}
 
 	t.tree.ResizeSplits()
+	if t.zoomed != nil {
+		t.zoomed.place(t.tree.x, t.tree.y, t.tree.width, t.tree.height)
+	}
 
 	for i, v := range t.views {
 		v.Num = i
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/zyedidia/clipboard"
 	"github.com/zyedidia/tcell"
 	"sort"
 	"strings"
@@ -127,78 +126,41 @@ func (a *TemplateBox) Reset() {
 	a.selects = a.selects[:0]
 }
 
+// snippetActions are the actions of the snippet keymap context
+var snippetActions = map[string]func(a *TemplateBox, v *View){
+	"NextField": (*TemplateBox).selectNext,
+	"Cancel": func(a *TemplateBox, v *View) {
+		a.Reset()
+	},
+}
+
 // HandleEvent handles an event for the prompter
 func (a *TemplateBox) HandleEvent(event tcell.Event, v *View) (swallow bool) {
-	switch e := event.(type) {
-	case *tcell.EventKey:
-		switch e.Key() {
-		case tcell.KeyTAB:
-			a.selectNext(v)
-			return true
-		case tcell.KeyESC:
-			a.Reset()
-			return true
-		}
+	e, ok := event.(*tcell.EventKey)
+	if !ok {
+		return false
 	}
-	switch e := event.(type) {
-	case *tcell.EventKey:
-		if e.Key() != tcell.KeyRune || e.Modifiers() != 0 {
-			for key, actions := range bindings {
-				if e.Key() == key.keyCode {
-					if e.Key() == tcell.KeyRune {
-						if e.Rune() != key.r {
-							continue
-						}
-					}
-					if e.Modifiers() == key.modifiers {
-						for _, action := range actions {
-							funcName := FuncName(action)
-							switch funcName {
-							case "main.(*View).CursorLeft":
-								if a.cursorx > 0 {
-									a.cursorx--
-								}
-							case "main.(*View).CursorRight":
-								if a.cursorx < Count(a.response) {
-									a.cursorx++
-								}
-							case "main.(*View).CursorStart", "main.(*View).StartOfLine":
-								a.cursorx = 0
-							case "main.(*View).CursorEnd", "main.(*View).EndOfLine":
-								a.cursorx = Count(a.response)
-							case "main.(*View).Backspace":
-								if v.Cursor.HasSelection() {
-									v.Cursor.DeleteSelection()
-									v.Cursor.ResetSelection()
-								}
-								if a.cursorx > 0 {
-									a.response = string([]rune(a.response)[:a.cursorx-1]) + string([]rune(a.response)[a.cursorx:])
-									a.cursorx--
-								}
-							case "main.(*View).Paste":
-								clip, _ := clipboard.ReadAll("clipboard")
-								if v.Cursor.HasSelection() {
-									v.Cursor.DeleteSelection()
-									v.Cursor.ResetSelection()
-								}
-								a.response = Insert(a.response, a.cursorx, clip)
-								a.cursorx += Count(clip)
-							}
-						}
-					}
-				}
+	if names := contextActions("snippet", e); names != nil {
+		for _, name := range names {
+			if action, ok := snippetActions[name]; ok {
+				action(a, v)
 			}
 		}
-		switch e.Key() {
-		case tcell.KeyRune:
-			if v.Cursor.HasSelection() {
-				v.Cursor.DeleteSelection()
-				v.Cursor.ResetSelection()
-			}
-			a.response = Insert(a.response, a.cursorx, string(e.Rune()))
-			a.cursorx++
+		return true
+	}
+
+	names := globalActions(e)
+	if hasAction(names, "Backspace") || hasAction(names, "Paste") || e.Key() == tcell.KeyRune {
+		// The text of the field replaces its selection
+		if v.Cursor.HasSelection() {
+			v.Cursor.DeleteSelection()
+			v.Cursor.ResetSelection()
 		}
-		a.selects[a.selected].resultingText = a.response
 	}
+	if !editText(names, &a.response, &a.cursorx) && e.Key() == tcell.KeyRune {
+		a.response = Insert(a.response, a.cursorx, string(e.Rune()))
+		a.cursorx++
+	}
+	a.selects[a.selected].resultingText = a.response
 	return false
 }
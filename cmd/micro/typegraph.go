@@ -0,0 +1,221 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// A typeEdge relates a type of the type graph to another one
+type typeEdge struct {
+	// The key of the type at the other end
+	typ string
+	// Whether it is the pointer to the type which satisfies the interface,
+	// or which is embedded
+	pointer bool
+}
+
+// A typeNode is a named type of the type graph
+type typeNode struct {
+	named *types.Named
+	// The name shown in the type hierarchy, such as pkg.Type
+	name string
+	// The position of the name of the declaration
+	pos   token.Position
+	iface bool
+	// Whether the type is declared in the workspace
+	workspace bool
+	// The interfaces the type satisfies, the concrete types of the workspace
+	// which satisfy it if it is an interface, and its embedded types
+	interfaces      []typeEdge
+	implementations []typeEdge
+	embeds          []typeEdge
+}
+
+// typeGraph holds the named types declared in the Go packages of the
+// workspace, with the interfaces they satisfy and the types they embed
+// The interfaces are looked for in the workspace and in the packages it
+// imports; types are keyed by import path and name, such as example.com/pkg.Type
+type typeGraph struct {
+	types map[string]*typeNode
+	// The names and declarations of types in each file, by absolute path
+	refs map[string][]graphRef
+	fset *token.FileSet
+}
+
+// newTypeGraph returns an empty type graph
+func newTypeGraph() *typeGraph {
+	return &typeGraph{types: make(map[string]*typeNode), refs: make(map[string][]graphRef)}
+}
+
+// typeKey returns the key of a named type in the type graph
+func typeKey(obj *types.TypeName) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// namedType returns the named type of an object naming one, and whether it
+// is a pointer to it
+func namedType(t types.Type) (*types.Named, bool) {
+	p, pointer := t.(*types.Pointer)
+	if pointer {
+		t = p.Elem()
+	}
+	n, _ := t.(*types.Named)
+	return n, pointer
+}
+
+// addType returns the node of a named type, adding it to the graph if needed
+func (g *typeGraph) addType(named *types.Named) *typeNode {
+	key := typeKey(named.Obj())
+	if n, ok := g.types[key]; ok {
+		return n
+	}
+	n := &typeNode{named: named, name: named.Obj().Name()}
+	if pkg := named.Obj().Pkg(); pkg != nil {
+		n.name = pkg.Name() + "." + n.name
+		n.pos = g.fset.Position(named.Obj().Pos())
+	}
+	g.types[key] = n
+	// The embedded types are nodes too, so that they can be expanded
+	embed := func(t types.Type) {
+		if e, pointer := namedType(t); e != nil {
+			g.addType(e)
+			n.embeds = append(n.embeds, typeEdge{typeKey(e.Obj()), pointer})
+		}
+	}
+	switch u := named.Underlying().(type) {
+	case *types.Interface:
+		n.iface = true
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			embed(u.EmbeddedType(i))
+		}
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if u.Field(i).Anonymous() {
+				embed(u.Field(i).Type())
+			}
+		}
+	}
+	return n
+}
+
+// addPackage adds the types declared at the top level of a type checked
+// package, and the exported interfaces of the packages it imports
+func (g *typeGraph) addPackage(fset *token.FileSet, pkg *types.Package, files []*ast.File, info *types.Info) {
+	g.fset = fset
+	for _, file := range files {
+		path := fset.Position(file.Pos()).Filename
+		ref := func(start, end token.Pos, key string, decl bool) {
+			g.refs[path] = append(g.refs[path], graphRef{fset.Position(start).Offset, fset.Position(end).Offset, key, decl})
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				obj, ok := info.Uses[n].(*types.TypeName)
+				if !ok {
+					obj, ok = info.Defs[n].(*types.TypeName)
+				}
+				if ok {
+					if named, _ := namedType(obj.Type()); named != nil {
+						ref(n.Pos(), n.End(), typeKey(named.Obj()), false)
+					}
+				}
+			case *ast.TypeSpec:
+				if obj, ok := info.Defs[n.Name].(*types.TypeName); ok {
+					if named, _ := namedType(obj.Type()); named != nil && named.Obj() == obj {
+						ref(n.Pos(), n.End(), typeKey(obj), true)
+					}
+				}
+			case *ast.FuncDecl:
+				// The methods of a type belong to its declaration
+				if f, ok := info.Defs[n.Name].(*types.Func); ok {
+					if recv := f.Type().(*types.Signature).Recv(); recv != nil {
+						if named, _ := namedType(recv.Type()); named != nil {
+							ref(n.Pos(), n.End(), typeKey(named.Obj()), true)
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	if pkg == nil {
+		return
+	}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if obj, ok := scope.Lookup(name).(*types.TypeName); ok && !obj.IsAlias() {
+			if named, ok := obj.Type().(*types.Named); ok {
+				g.addType(named).workspace = true
+			}
+		}
+	}
+	for _, imp := range pkg.Imports() {
+		scope := imp.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !obj.Exported() || obj.IsAlias() {
+				continue
+			}
+			if named, ok := obj.Type().(*types.Named); ok && types.IsInterface(named) {
+				g.addType(named)
+			}
+		}
+	}
+}
+
+// finish finds out which types satisfy the interfaces of the graph, once all
+// the packages are added
+func (g *typeGraph) finish() {
+	g.addType(types.Universe.Lookup("error").Type().(*types.Named))
+	var ifaces []string
+	for _, key := range g.keys() {
+		if n := g.types[key]; n.iface && n.named.Underlying().(*types.Interface).NumMethods() > 0 {
+			ifaces = append(ifaces, key)
+		}
+	}
+	for _, key := range g.keys() {
+		n := g.types[key]
+		if !n.iface && !n.workspace {
+			continue
+		}
+		for _, ikey := range ifaces {
+			if ikey == key {
+				continue
+			}
+			i := g.types[ikey]
+			iface := i.named.Underlying().(*types.Interface)
+			pointer := false
+			if !types.Implements(n.named, iface) {
+				if n.iface || !types.Implements(types.NewPointer(n.named), iface) {
+					continue
+				}
+				pointer = true
+			}
+			n.interfaces = append(n.interfaces, typeEdge{ikey, pointer})
+			if !n.iface {
+				i.implementations = append(i.implementations, typeEdge{key, pointer})
+			}
+		}
+	}
+}
+
+// keys returns the keys of the types of the graph in order
+func (g *typeGraph) keys() []string {
+	keys := make([]string, 0, len(g.types))
+	for key := range g.types {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// typeAt returns the type named at a byte offset of a file, or else the type
+// whose declaration or method contains it
+func (g *typeGraph) typeAt(path string, offset int) string {
+	return refAt(g.refs[path], offset)
+}
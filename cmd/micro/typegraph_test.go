@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBuildTypeGraph(t *testing.T) {
+	root, _ := ioutil.TempDir("", "typegraph")
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "shape"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "shape", "shape.go"), []byte(`package shape
+
+type Shape interface {
+	Area() float64
+}
+
+type Solid interface {
+	Shape
+	Volume() float64
+}
+`), 0644)
+	main := filepath.Join(root, "main.go")
+	src := `package main
+
+import (
+	"fmt"
+	"sync"
+
+	"example.com/app/shape"
+)
+
+type Square struct {
+	sync.Mutex
+	side float64
+}
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+type Cube struct {
+	*Square
+}
+
+func (c *Cube) Volume() float64 { return c.Area() * c.side }
+
+func (c *Cube) String() string { return "cube" }
+
+var _ shape.Solid = &Cube{}
+
+func main() { fmt.Println(Cube{}) }
+`
+	ioutil.WriteFile(main, []byte(src), 0644)
+	// Imports of the module are found from the working directory
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+	g := buildGoGraph(root, nil).types
+
+	tests := []struct {
+		typ, section, want string
+	}{
+		{"example.com/app.Square", "interfaces", "example.com/app/shape.Shape *sync.Locker"},
+		{"example.com/app.Square", "embeds", "sync.Mutex"},
+		{"example.com/app.Cube", "interfaces", "example.com/app/shape.Shape *example.com/app/shape.Solid *fmt.Stringer sync.Locker"},
+		{"example.com/app.Cube", "embeds", "*example.com/app.Square"},
+		{"example.com/app/shape.Shape", "implementations", "example.com/app.Cube example.com/app.Square"},
+		{"example.com/app/shape.Solid", "implementations", "*example.com/app.Cube"},
+		{"example.com/app/shape.Solid", "interfaces", "example.com/app/shape.Shape"},
+		{"example.com/app/shape.Solid", "embeds", "example.com/app/shape.Shape"},
+	}
+	for _, test := range tests {
+		n, ok := g.types[test.typ]
+		if !ok {
+			t.Errorf("buildGoGraph: %s is missing", test.typ)
+			continue
+		}
+		edges := map[string][]typeEdge{"interfaces": n.interfaces, "implementations": n.implementations, "embeds": n.embeds}[test.section]
+		var got []string
+		for _, e := range edges {
+			if e.pointer {
+				got = append(got, "*"+e.typ)
+			} else {
+				got = append(got, e.typ)
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("buildGoGraph %s %s: got %q, want %q", test.typ, test.section, strings.Join(got, " "), test.want)
+		}
+	}
+
+	for offset, want := range map[int]string{
+		strings.Index(src, "Square struct"): "example.com/app.Square",
+		strings.Index(src, "side float64"):  "example.com/app.Square",
+		strings.Index(src, "return c.Area"): "example.com/app.Cube",
+		strings.Index(src, "Solid = "):      "example.com/app/shape.Solid",
+		strings.Index(src, "Mutex"):         "sync.Mutex",
+		strings.Index(src, "func main"):     "",
+	} {
+		if typ := g.typeAt(main, offset); typ != want {
+			t.Errorf("typeAt %d: got %q, want %q", offset, typ, want)
+		}
+	}
+
+	line := func(typ string) string {
+		return strconv.Itoa(g.types[typ].pos.Line)
+	}
+	expanded := map[string]bool{"0": true}
+	want := "type main.Cube  main.go:17\n" +
+		"  ▾ Interfaces (4)\n" +
+		"      shape.Shape  shape.go:3\n" +
+		"    ▸ shape.Solid (pointer)  shape.go:7\n" +
+		"      fmt.Stringer (pointer)  print.go:" + line("fmt.Stringer") + "\n" +
+		"      sync.Locker  mutex.go:" + line("sync.Locker") + "\n" +
+		"  ▸ Embedded types (1)"
+	if text := typeTreeText(g, typeTreeRows(g, "example.com/app.Cube", expanded), expanded); text != want {
+		t.Errorf("typeTreeText: got %q, want %q", text, want)
+	}
+	expanded = map[string]bool{"1": true, "2": true, "2/example.com/app/shape.Shape": true}
+	want = "interface shape.Solid  shape.go:7\n" +
+		"  ▾ Implementations (1)\n" +
+		"      *main.Cube  main.go:17\n" +
+		"  ▸ Interfaces (1)\n" +
+		"  ▾ Embedded types (1)\n" +
+		"      shape.Shape  shape.go:3"
+	if text := typeTreeText(g, typeTreeRows(g, "example.com/app/shape.Solid", expanded), expanded); text != want {
+		t.Errorf("typeTreeText: got %q, want %q", text, want)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The sections of the type hierarchy of a type
+const (
+	typeInterfaces = iota
+	typeImplementations
+	typeEmbeds
+)
+
+// A typeRow is a line of the type hierarchy tree
+type typeRow struct {
+	// The type of the row, empty for the section headers
+	typ string
+	// Whether the type is related through a pointer to it
+	pointer bool
+	// Identifies the row in the expanded state
+	path    string
+	depth   int
+	section int
+	// Whether expanding the row shows anything
+	children bool
+}
+
+// typeTreeState links a type hierarchy view to the view it jumps in
+type typeTreeState struct {
+	source *View
+	// The type the hierarchy is about
+	root     string
+	expanded map[string]bool
+	rows     []typeRow
+}
+
+// The expanded rows of the type hierarchy of each type, which are kept while
+// the hierarchy is closed or shows another type
+var typeTreeExpanded = make(map[string]map[string]bool)
+
+// related returns the types related to a type in a section of its hierarchy
+func (g *typeGraph) related(typ string, section int) []typeEdge {
+	n, ok := g.types[typ]
+	if !ok {
+		return nil
+	}
+	switch section {
+	case typeInterfaces:
+		return n.interfaces
+	case typeImplementations:
+		return n.implementations
+	}
+	return n.embeds
+}
+
+// typeSections returns the sections of the hierarchy of a type
+func (g *typeGraph) typeSections(typ string) []int {
+	if g.types[typ].iface {
+		return []int{typeImplementations, typeInterfaces, typeEmbeds}
+	}
+	return []int{typeInterfaces, typeEmbeds}
+}
+
+// typeTreeRows returns the rows of the type hierarchy of a type
+// The children of a row are only listed if its path is expanded, and expanding
+// a type lists the types related to it in the same way as to its parent
+func typeTreeRows(g *typeGraph, root string, expanded map[string]bool) []typeRow {
+	rows := []typeRow{{typ: root}}
+	var add func(typ string, section int, path string, depth int)
+	add = func(typ string, section int, path string, depth int) {
+		for _, e := range g.related(typ, section) {
+			p := path + "/" + e.typ
+			rows = append(rows, typeRow{
+				typ:      e.typ,
+				pointer:  e.pointer,
+				path:     p,
+				depth:    depth,
+				section:  section,
+				children: len(g.related(e.typ, section)) > 0,
+			})
+			if expanded[p] {
+				add(e.typ, section, p, depth+1)
+			}
+		}
+	}
+	for _, section := range g.typeSections(root) {
+		path := strconv.Itoa(section)
+		rows = append(rows, typeRow{path: path, depth: 1, section: section, children: len(g.related(root, section)) > 0})
+		if expanded[path] {
+			add(root, section, path, 2)
+		}
+	}
+	return rows
+}
+
+// typeTreeText returns the lines of the type hierarchy tree
+func typeTreeText(g *typeGraph, rows []typeRow, expanded map[string]bool) string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		marker := "  "
+		if row.children && expanded[row.path] {
+			marker = "▾ "
+		} else if row.children {
+			marker = "▸ "
+		}
+		var label string
+		if row.typ == "" {
+			n := strconv.Itoa(len(g.related(rows[0].typ, row.section)))
+			switch row.section {
+			case typeInterfaces:
+				label = "Interfaces (" + n + ")"
+			case typeImplementations:
+				label = "Implementations (" + n + ")"
+			default:
+				label = "Embedded types (" + n + ")"
+			}
+		} else {
+			node := g.types[row.typ]
+			label = node.name
+			if row.pointer && row.section != typeInterfaces {
+				label = "*" + label
+			} else if row.pointer {
+				label += " (pointer)"
+			}
+			if node.pos.IsValid() {
+				label += "  " + filepath.Base(node.pos.Filename) + ":" + strconv.Itoa(node.pos.Line)
+			}
+		}
+		if i == 0 {
+			marker = ""
+			if g.types[row.typ].iface {
+				label = "interface " + label
+			} else {
+				label = "type " + label
+			}
+		}
+		lines[i] = strings.Repeat("  ", row.depth) + marker + label
+	}
+	return strings.Join(lines, "\n")
+}
+
+// render refreshes the text of a type hierarchy view from the type graph,
+// keeping the cursor on the same line
+func (t *typeTreeState) render(v *View, g *typeGraph) {
+	if _, ok := g.types[t.root]; !ok {
+		v.Buf.SetText("The type " + t.root + " is gone")
+		t.rows = nil
+		return
+	}
+	t.rows = typeTreeRows(g, t.root, t.expanded)
+	y := v.Cursor.Y
+	v.Buf.SetText(typeTreeText(g, t.rows, t.expanded))
+	v.Buf.name = "Types " + g.types[t.root].name
+	v.Cursor.X, v.Cursor.Y, v.Cursor.LastVisualX = 0, Min(y, v.Buf.NumLines-1), 0
+	v.Relocate()
+}
+
+// openTypeTree shows the type hierarchy of a type in the type hierarchy view
+// of the source view, opening a split on the left if there is none
+func openTypeTree(src *View, g *typeGraph, typ string) {
+	var tv *View
+	for _, view := range tabs[src.TabNum].views {
+		if view.typeTree != nil && view.typeTree.source == src {
+			tv = view
+		}
+	}
+	if tv == nil {
+		tv = src.openTreeSplit("Types", typeTreeEnter, typeTreeToggle)
+	}
+	expanded, ok := typeTreeExpanded[typ]
+	if !ok {
+		expanded = make(map[string]bool)
+		for _, section := range g.typeSections(typ) {
+			expanded[strconv.Itoa(section)] = true
+		}
+		typeTreeExpanded[typ] = expanded
+	}
+	tv.typeTree = &typeTreeState{source: src, root: typ, expanded: expanded}
+	tv.Cursor.Y = 0
+	tv.typeTree.render(tv, g)
+	tabs[curTab].CurView = tv.Num
+}
+
+// typeTreeEnter jumps to the declaration of the type of the row under the
+// cursor in the source view, and toggles the headers
+func typeTreeEnter(tv *View, line int) {
+	t := tv.typeTree
+	if line >= len(t.rows) {
+		return
+	}
+	row := t.rows[line]
+	if row.typ == "" {
+		typeTreeToggle(tv, line)
+		return
+	}
+	if goGraphs.graph == nil {
+		return
+	}
+	pos := goGraphs.graph.types.types[row.typ].pos
+	if !pos.IsValid() {
+		messenger.Error(row.typ + " is predeclared")
+		return
+	}
+	if !viewIsOpen(t.source) {
+		messenger.Error("The view of the type hierarchy was closed")
+		return
+	}
+	tabs[curTab].CurView = t.source.Num
+	t.source.jumpToPosition(pos)
+}
+
+// typeTreeToggle expands or collapses the row under the cursor
+func typeTreeToggle(tv *View, line int) {
+	t := tv.typeTree
+	if line == 0 || line >= len(t.rows) || !t.rows[line].children || goGraphs.graph == nil {
+		return
+	}
+	path := t.rows[line].path
+	if t.expanded[path] {
+		delete(t.expanded, path)
+	} else {
+		t.expanded[path] = true
+	}
+	t.render(tv, goGraphs.graph.types)
+}
+
+// TypeHierarchy shows the interfaces the Go type under the cursor satisfies,
+// the types which implement it if it is an interface, and the types it
+// embeds, as a tree in a split on the left
+// In the type hierarchy itself it shows the hierarchy of the type of the row
+// under the cursor
+func (v *View) TypeHierarchy(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("TypeHierarchy", v) {
+		return false
+	}
+
+	if v.typeTree != nil || v.Buf.FileType() == "go" {
+		if goGraphs.graph == nil || goGraphs.stale {
+			messenger.Message("Type checking the workspace...")
+		}
+		v.Cursor.Relocate()
+		src, path, offset := v, v.Buf.AbsPath, ByteOffset(v.Cursor.Loc, v.Buf)
+		var typ string
+		if t := v.typeTree; t != nil {
+			src, typ = t.source, t.root
+			if v.Cursor.Y < len(t.rows) && t.rows[v.Cursor.Y].typ != "" {
+				typ = t.rows[v.Cursor.Y].typ
+			}
+		}
+		loadGoGraph(func(gg *goGraph) {
+			g := gg.types
+			if typ == "" {
+				typ = g.typeAt(path, offset)
+			}
+			if !viewIsOpen(src) || src.TabNum != curTab {
+				return
+			}
+			if _, ok := g.types[typ]; !ok {
+				messenger.Error("No type under the cursor")
+				return
+			}
+			messenger.Reset()
+			openTypeTree(src, g, typ)
+		})
+	}
+
+	if usePlugin {
+		return PostActionCall("TypeHierarchy", v)
+	}
+	return true
+}
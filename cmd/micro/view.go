@@ -6,7 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/mattn/go-runewidth"
 	"github.com/mitchellh/go-homedir"
 	"github.com/zyedidia/tcell"
 	"sync"
@@ -18,6 +17,8 @@ const (
 	vtDefault ViewType = iota
 	vtHelp
 	vtLog
+	// vtScratch holds a read-only buffer which is not backed by a file
+	vtScratch
 )
 
 // The View struct stores information about a view into a buffer.
@@ -42,6 +43,10 @@ type View struct {
 	LockWidth  bool
 	LockHeight bool
 
+	// The number of columns of the minimap on the right of the view, which
+	// are not counted in Width
+	mapWidth int
+
 	// Where this view is located
 	x, y int
 
@@ -90,6 +95,43 @@ type View struct {
 
 	splitNode *LeafNode
 
+	// A view whose scroll position and cursor line follow this view
+	linkedView *View
+	// Called with the cursor line when Enter is pressed in a scratch view
+	lineAction func(v *View, line int)
+	// The comparison this view is part of in diff mode
+	diff *DiffPair
+	// The actions of the tree and explorer keymap contexts, called with the
+	// cursor line, if this is a hierarchy tree or the explorer
+	treeActions map[string]func(v *View, line int)
+	// The file whose symbols are listed if this is an outline view
+	outline *outlineState
+	// The function whose calls are listed if this is a call hierarchy view
+	callTree *callTreeState
+	// The type whose relations are listed if this is a type hierarchy view
+	typeTree *typeTreeState
+	// The directory tree listed if this is a file explorer view
+	explorer *explorerState
+	// Counts the mouse moves, so that a hover is only shown when the mouse stopped
+	hoverMoves int
+
+	// The locations the view jumped from, for PrevLoc and NextLoc
+	jumps JumpList
+	// The lines of the last search and the drag state of the minimap
+	minimap minimapState
+	// Whether a click on the sticky scroll header is going on
+	stickyClick bool
+	// The mode and pending keys of the vim layer
+	vim vimState
+	// The keys of a chord which is being typed, and a counter which makes
+	// the timeout of older chords do nothing
+	chord   []Key
+	chordID int
+	// The column on the screen the cursor keeps when it moves up and down
+	// soft wrapped rows, while it is at wrapColLoc
+	wrapCol    int
+	wrapColLoc Loc
+
 	highlight     *[][]Loc
 	highlightLock sync.Mutex
 }
@@ -197,21 +239,25 @@ func (v *View) paste(clip string) {
 // ScrollUp scrolls the view up n lines (if possible)
 func (v *View) ScrollUp(n int) {
 	// Try to scroll by n but if it would overflow, scroll by 1
-	if v.Topline-n >= 0 {
-		v.Topline -= n
-	} else if v.Topline > 0 {
-		v.Topline--
+	top := v.Buf.visibleIndex(v.Topline)
+	if top-n >= 0 {
+		top -= n
+	} else if top > 0 {
+		top--
 	}
+	v.Topline = v.Buf.visibleLine(top)
 }
 
 // ScrollDown scrolls the view down n lines (if possible)
 func (v *View) ScrollDown(n int) {
 	// Try to scroll by n but if it would overflow, scroll by 1
-	if v.Topline+n <= v.Buf.NumLines-v.Height {
-		v.Topline += n
-	} else if v.Topline < v.Buf.NumLines-v.Height {
-		v.Topline++
+	top, numLines := v.Buf.visibleIndex(v.Topline), v.Buf.visibleLines()
+	if top+n <= numLines-v.Height {
+		top += n
+	} else if top < numLines-v.Height {
+		top++
 	}
+	v.Topline = v.Buf.visibleLine(top)
 }
 
 // CanClose returns whether or not the view can be closed
@@ -245,8 +291,19 @@ func (v *View) CanClose() bool {
 func (v *View) OpenBuffer(buf *Buffer) {
 	screen.Clear()
 	v.CloseBuffer()
+	shown := false
+	for _, view := range bufferViews(buf) {
+		shown = shown || view != v
+	}
 	v.Buf = buf
-	v.Cursor = &buf.Cursor
+	if shown {
+		// Each view of a buffer has its own cursor
+		c := *buf.Cursor
+		v.Cursor = &c
+	} else {
+		v.Cursor = buf.Cursor
+	}
+	touchBuffer(buf)
 	v.Topline = 0
 	v.leftCol = 0
 	v.Cursor.ResetSelection()
@@ -256,6 +313,10 @@ func (v *View) OpenBuffer(buf *Buffer) {
 
 	v.matches = Match(v)
 
+	if n := len(buf.Conflicts()); n > 0 {
+		messenger.Message(buf.GetName(), " has ", conflictMessage(n))
+	}
+
 	// Set mouseReleased to true because we assume the mouse is not being pressed when
 	// the editor is opened
 	v.mouseReleased = true
@@ -277,16 +338,75 @@ func (v *View) Open(filename string) {
 	} else {
 		buf = NewBuffer(file, filename)
 	}
+	old := v.Buf
 	v.OpenBuffer(buf)
+	releaseBuffer(old)
+	v.Lint()
+	v.Vet()
+}
+
+// openFile shows the buffer of a file in the view for a jump to it, reading
+// the file if it isn't loaded
+// The buffer the view showed is kept loaded rather than saved, and false is
+// returned if the file can't be read
+func (v *View) openFile(path string) bool {
+	b := findBuffer(path)
+	if b == nil {
+		file, err := os.Open(path)
+		if err != nil {
+			messenger.Error(err.Error())
+			return false
+		}
+		defer file.Close()
+		b = NewBuffer(file, workingDirPath(path))
+	}
+	v.showBuffer(b)
 	v.Lint()
 	v.Vet()
+	return true
 }
 
 // CloseBuffer performs any closing functions on the buffer
 func (v *View) CloseBuffer() {
 	if v.Buf != nil {
+		v.Buf.Cursor = v.Cursor
 		v.Buf.Serialize()
 	}
+	if v.linkedView != nil {
+		v.linkedView.linkedView = nil
+		v.linkedView = nil
+	}
+	if v.diff != nil {
+		v.diff.Close()
+	}
+	v.outline = nil
+	v.callTree = nil
+	v.typeTree = nil
+	v.explorer = nil
+	v.lineAction = nil
+	v.treeActions = nil
+}
+
+// LinkViews makes the scroll position and cursor line of the two views follow each other
+func LinkViews(a, b *View) {
+	a.linkedView = b
+	b.linkedView = a
+	a.syncLinkedView()
+}
+
+// syncLinkedView moves the linked view to this view's scroll position and cursor line
+func (v *View) syncLinkedView() {
+	if v.diff != nil {
+		v.diff.sync(v)
+		return
+	}
+	l := v.linkedView
+	if l == nil {
+		return
+	}
+	l.Topline = v.Topline
+	l.Cursor.Y = v.Cursor.Y
+	l.Cursor.Relocate()
 }
 
 // ReOpen reloads the current buffer
@@ -329,116 +449,68 @@ func (v *View) VSplitIndex(buf *Buffer, splitIndex int) {
 
 // GetSoftWrapLocation gets the location of a visual click on the screen and converts it to col,line
 func (v *View) GetSoftWrapLocation(vx, vy int) (int, int) {
-	if !v.Buf.Settings["softwrap"].(bool) {
-		if vy >= v.Buf.NumLines {
-			vy = v.Buf.NumLines - 1
-		}
-		vx = v.Cursor.GetCharPosInLine(vy, vx)
-		return vx, vy
-	}
-
-	screenX, screenY := 0, v.Topline
-	for lineN := v.Topline; lineN < v.Bottomline(); lineN++ {
-		line := v.Buf.Line(lineN)
-		if lineN >= v.Buf.NumLines {
-			return 0, v.Buf.NumLines - 1
-		}
-
-		colN := 0
-		for _, ch := range line {
-			if screenX >= v.Width-v.lineNumOffset {
-				screenX = 0
-				screenY++
-			}
-
-			if screenX == vx && screenY == vy {
-				return colN, lineN
-			}
-
-			if ch == '\t' {
-				screenX += int(v.Buf.Settings["tabsize"].(float64)) - 1
-			}
-
-			screenX++
-			colN++
-		}
-		if screenY == vy {
-			return colN, lineN
-		}
-		screenX = 0
-		screenY++
-	}
-
-	return 0, 0
+	y, row := v.rowLine(vy - v.Topline)
+	return v.layout(y).charAt(row, vx), y
 }
 
 func (v *View) Bottomline() int {
 	if !v.Buf.Settings["softwrap"].(bool) {
-		return v.Topline + v.Height
+		if v.diff != nil {
+			return v.diff.bottomline(v)
+		}
+		return v.lineAtRow(v.Height)
 	}
 
-	screenX, screenY := 0, 0
-	numLines := 0
-	for lineN := v.Topline; lineN < v.Topline+v.Height; lineN++ {
-		line := v.Buf.Line(lineN)
-
-		colN := 0
-		for _, ch := range line {
-			if screenX >= v.Width-v.lineNumOffset {
-				screenX = 0
-				screenY++
-			}
-
-			if ch == '\t' {
-				screenX += int(v.Buf.Settings["tabsize"].(float64)) - 1
-			}
-
-			screenX++
-			colN++
-		}
-		screenX = 0
-		screenY++
+	rows, numLines := 0, 0
+	for lineN := v.Topline; rows < v.Height; lineN = v.Buf.nextVisible(lineN) {
+		rows += v.lineRows(lineN)
 		numLines++
-
-		if screenY >= v.Height {
-			break
-		}
 	}
-	return numLines + v.Topline
+	return v.lineAtRow(numLines)
 }
 
 // Relocate moves the view window so that the cursor is in view
 // This is useful if the user has scrolled far away, and then starts typing
+// The cursor can't stay in a closed fold, so the folds around it are opened
 func (v *View) Relocate() bool {
-	height := v.Bottomline() - v.Topline
+	b := v.Buf
+	b.openFoldsAt(v.Cursor.Y)
+	if v.Buf.Settings["softwrap"].(bool) {
+		return v.relocateWrapped()
+	}
+	// The lines hidden by folds don't count
+	top := b.visibleIndex(v.Topline)
+	height := b.visibleIndex(v.Bottomline()) - top
 	ret := false
-	cy := v.Cursor.Y
+	cy := b.visibleIndex(v.Cursor.Y)
+	numLines := b.visibleLines()
 	scrollmargin := int(v.Buf.Settings["scrollmargin"].(float64))
-	if cy < v.Topline+scrollmargin && cy > scrollmargin-1 {
-		v.Topline = cy - scrollmargin
+	if cy < top+scrollmargin && cy > scrollmargin-1 {
+		top = cy - scrollmargin
 		ret = true
-	} else if cy < v.Topline {
-		v.Topline = cy
+	} else if cy < top {
+		top = cy
 		ret = true
 	}
-	if cy > v.Topline+height-1-scrollmargin && cy < v.Buf.NumLines-scrollmargin {
-		v.Topline = cy - height + 1 + scrollmargin
+	if cy > top+height-1-scrollmargin && cy < numLines-scrollmargin {
+		top = cy - height + 1 + scrollmargin
 		ret = true
-	} else if cy >= v.Buf.NumLines-scrollmargin && cy > height {
-		v.Topline = v.Buf.NumLines - height
+	} else if cy >= numLines-scrollmargin && cy > height {
+		top = numLines - height
 		ret = true
 	}
+	if ret {
+		v.Topline = b.visibleLine(top)
+	}
 
-	if !v.Buf.Settings["softwrap"].(bool) {
-		cx := v.Cursor.GetVisualX()
-		if cx < v.leftCol {
-			v.leftCol = cx
-			ret = true
-		}
-		if cx+v.lineNumOffset+1 > v.leftCol+v.Width {
-			v.leftCol = cx - v.Width + v.lineNumOffset + 1
-			ret = true
-		}
+	_, cx := v.layout(v.Cursor.Y).pos(v.Cursor.X)
+	if cx < v.leftCol {
+		v.leftCol = cx
+		ret = true
+	}
+	if cx+v.lineNumOffset+1 > v.leftCol+v.Width {
+		v.leftCol = cx - v.Width + v.lineNumOffset + 1
+		ret = true
 	}
 	return ret
 }
@@ -465,7 +537,6 @@ func (v *View) MoveToMouseClick(x, y int) {
 	v.Cursor.X = x
 	v.Cursor.Y = y
 	v.Cursor.LastVisualX = v.Cursor.GetVisualX()
-	cursorLocations.AddLocation(CursorLocation{X: v.Buf.Cursor.X, Y: v.Buf.Cursor.Y, Path: v.Buf.Path})
 	go v.What(false)
 }
 
@@ -475,8 +546,15 @@ func (v *View) HandleEvent(event tcell.Event) {
 	// By default it's true because most events should cause a relocate
 	relocate := true
 
+	// Undo and redo move the cursor of the view in use
+	v.Buf.Cursor = v.Cursor
+	touchBuffer(v.Buf)
 	v.Buf.CheckModTime()
 
+	if hover.open && hover.HandleEvent(event) {
+		return
+	}
+
 	switch e := event.(type) {
 	case *tcell.EventResize:
 		// Window resized
@@ -497,32 +575,28 @@ func (v *View) HandleEvent(event tcell.Event) {
 			}
 		}
 
+		if signatureBox.open && signatureBox.HandleEvent(e) {
+			return
+		}
+
+		// Scratch views such as blame or log listings act on the cursor line with Enter
+		if v.lineAction != nil && e.Key() == tcell.KeyEnter {
+			v.lineAction(v, v.Cursor.Y)
+			return
+		}
+		if v.treeActions != nil && v.treeKey(e) {
+			return
+		}
+
+		// In vim mode keys are commands unless the view is in insert mode
+		if globalSettings["vimmode"].(bool) && v.vimHandleKey(e) {
+			break
+		}
+
 		// Check first if input is a key binding, if it is we 'eat' the input and don't insert a rune
-		isBinding := false
-		if e.Key() != tcell.KeyRune || e.Modifiers() != 0 {
-			for key, actions := range bindings {
-				if e.Key() == key.keyCode {
-					if e.Key() == tcell.KeyRune {
-						if e.Rune() != key.r {
-							continue
-						}
-					}
-					if e.Modifiers() == key.modifiers {
-						relocate = false
-						isBinding = true
-						for _, action := range actions {
-							relocate = action(v, true) || relocate
-							funcName := FuncName(action)
-							if funcName != "main.(*View).ToggleMacro" && funcName != "main.(*View).PlayMacro" {
-								if recordingMacro {
-									curMacro = append(curMacro, action)
-								}
-							}
-						}
-						break
-					}
-				}
-			}
+		isBinding, bindingRelocate := v.handleBindingKey(e)
+		if isBinding {
+			relocate = bindingRelocate
 		}
 		if !isBinding && e.Key() == tcell.KeyRune {
 			// Insert a character
@@ -543,7 +617,11 @@ func (v *View) HandleEvent(event tcell.Event) {
 			}
 
 			if recordingMacro {
-				curMacro = append(curMacro, e.Rune())
+				curMacro.addText(string(e.Rune()))
+			}
+
+			if r := e.Rune(); (r == '(' || r == ',' || r == ')') && v.Buf.FileType() == "go" {
+				v.showSignature()
 			}
 		}
 	case *tcell.EventPaste:
@@ -552,12 +630,23 @@ func (v *View) HandleEvent(event tcell.Event) {
 		}
 
 		v.paste(e.Text())
+		if recordingMacro {
+			curMacro.addText(e.Text())
+		}
 
 		PostActionCall("Paste", v)
 	case *tcell.EventMouse:
+		if tabs[v.TabNum].splitMouse(v, e) || v.minimapMouse(e) || v.stickyMouse(e) {
+			relocate = false
+			break
+		}
 		x, y := e.Position()
 		x -= v.lineNumOffset - v.leftCol + v.x
-		y += v.Topline - v.y
+		if v.diff != nil {
+			y = v.diff.lineAtScreenRow(v, y-v.y)
+		} else {
+			y += v.Topline - v.y
+		}
 		// Don't relocate for mouse events
 		relocate = false
 
@@ -630,6 +719,8 @@ func (v *View) HandleEvent(event tcell.Event) {
 					v.Cursor.CopySelection("primary")
 				}
 				v.mouseReleased = true
+			} else {
+				v.mouseHover(e.Position())
 			}
 		case tcell.WheelUp:
 			// Scroll up
@@ -645,6 +736,7 @@ func (v *View) HandleEvent(event tcell.Event) {
 	if relocate {
 		v.Relocate()
 	}
+	v.syncLinkedView()
 }
 
 // GutterMessage creates a message in this view's gutter
@@ -685,6 +777,7 @@ func (v *View) openHelp(helpPage string) {
 	} else {
 		helpBuffer := NewBuffer(strings.NewReader(string(data)), helpPage+".md")
 		helpBuffer.name = "Help"
+		unlistBuffer(helpBuffer)
 
 		if v.Type == vtHelp {
 			v.OpenBuffer(helpBuffer)
@@ -707,11 +800,24 @@ func (v *View) DisplayView() {
 		// Log views should always follow the cursor...
 		v.Relocate()
 	}
+	if v.Cursor != v.Buf.Cursor {
+		// The text may have been edited from another view of the buffer
+		v.Cursor.Relocate()
+	}
+
+	if v.outline != nil {
+		v.outline.update(v)
+	}
 
 	if v.Buf.Settings["syntax"].(bool) {
 		v.matches = Match(v)
 	}
 
+	v.Buf.UpdateGitDiff()
+	if v.diff != nil {
+		v.diff.update()
+	}
+
 	// The charNum we are currently displaying
 	// starts at the start of the viewport
 	charNum := Loc{0, v.Topline}
@@ -738,6 +844,25 @@ func (v *View) DisplayView() {
 		v.lineNumOffset += 2
 	}
 
+	// One column for the names of the bookmarks
+	bookmarks := v.Buf.bookmarkLines()
+	if len(bookmarks) > 0 {
+		v.lineNumOffset++
+	}
+
+	// And one for the fold markers once something is folded
+	var foldMarkers map[int]rune
+	if len(v.Buf.folds.closed) > 0 {
+		foldMarkers = v.Buf.foldMarkers()
+		v.lineNumOffset++
+	}
+
+	// One more column for the git markers
+	hasGitGutter := v.hasGitGutter()
+	if hasGitGutter {
+		v.lineNumOffset++
+	}
+
 	if v.x != 0 {
 		// One space for the extra split divider
 		v.lineNumOffset++
@@ -749,13 +874,25 @@ func (v *View) DisplayView() {
 	highlightStyle := defStyle
 	curLineN := 0
 
+	top := v.Buf.visibleIndex(v.Topline)
+
 	// ViewLine is the current line from the top of the viewport
 	for viewLine := 0; viewLine < v.Height; viewLine++ {
 		screenY++
 		screenX = v.x
 
 		// This is the current line number of the buffer that we are drawing
-		curLineN = viewLine + v.Topline
+		// The lines hidden by folds are skipped
+		curLineN = v.Buf.visibleLine(top + viewLine)
+		charNum = Loc{0, curLineN}
+
+		// In diff mode filler rows keep the lines aligned with the other side
+		if v.diff != nil {
+			for i := v.diff.fillerRows(v, curLineN); i > 0 && screenY-v.y < v.Height; i-- {
+				v.diff.drawFiller(v, screenY)
+				screenY++
+			}
+		}
 
 		if screenY-v.y >= v.Height {
 			break
@@ -785,21 +922,7 @@ func (v *View) DisplayView() {
 				for _, msg := range v.messages[k] {
 					if msg.lineNum == curLineN {
 						msgOnLine = true
-						gutterStyle := defStyle
-						switch msg.kind {
-						case GutterInfo:
-							if style, ok := colorscheme["gutter-info"]; ok {
-								gutterStyle = style
-							}
-						case GutterWarning:
-							if style, ok := colorscheme["gutter-warning"]; ok {
-								gutterStyle = style
-							}
-						case GutterError:
-							if style, ok := colorscheme["gutter-error"]; ok {
-								gutterStyle = style
-							}
-						}
+						gutterStyle := gutterMessageStyle(msg.kind)
 						v.drawCell(screenX, screenY, '>', nil, gutterStyle)
 						screenX++
 						v.drawCell(screenX, screenY, '>', nil, gutterStyle)
@@ -824,6 +947,30 @@ func (v *View) DisplayView() {
 			}
 		}
 
+		if len(bookmarks) > 0 {
+			if r, ok := bookmarks[curLineN]; ok {
+				v.drawCell(screenX, screenY, r, nil, bookmarkStyle())
+			} else {
+				v.drawCell(screenX, screenY, ' ', nil, defStyle)
+			}
+			screenX++
+		}
+
+		if foldMarkers != nil {
+			if r, ok := foldMarkers[curLineN]; ok {
+				v.drawCell(screenX, screenY, r, nil, foldStyle())
+			} else {
+				v.drawCell(screenX, screenY, ' ', nil, defStyle)
+			}
+			screenX++
+		}
+
+		if hasGitGutter {
+			ch, style := v.gitGutterCell(curLineN)
+			v.drawCell(screenX, screenY, ch, nil, style)
+			screenX++
+		}
+
 		lineNumStyle := defStyle
 		if v.Buf.Settings["ruler"] == true {
 			// Write the line number
@@ -855,27 +1002,38 @@ func (v *View) DisplayView() {
 		}
 
 		// Now we actually draw the line
-		colN := 0
-		strWidth := 0
-		tabSize := int(v.Buf.Settings["tabsize"].(float64))
-		for _, ch := range line {
-			if v.Buf.Settings["softwrap"].(bool) {
-				if screenX-v.x >= v.Width {
-					screenY++
-
-					x := 0
-					if hasGutterMessages {
-						v.drawCell(v.x+x, screenY, ' ', nil, defStyle)
-						x++
-						v.drawCell(v.x+x, screenY, ' ', nil, defStyle)
-						x++
-					}
-					for i := 0; i < v.lineNumOffset; i++ {
-						screen.SetContent(v.x+i+x, screenY, ' ', nil, lineNumStyle)
-					}
-					screenX = v.x + v.lineNumOffset
+		// The layout gives the row and the column of each character, and the
+		// rows of a soft wrapped line start after the gutter
+		runes := []rune(line)
+		layout := v.layout(curLineN)
+		textX := screenX
+		row := 0
+		// nextRow moves down to a row of the line, and returns whether it is
+		// in the view
+		nextRow := func(r int) bool {
+			for ; row < r; row++ {
+				v.drawLineEnd(curLineN, screenX, screenY)
+				screenY++
+				x := v.x
+				if v.x != 0 {
+					v.drawCell(x, screenY, '|', nil, defStyle.Reverse(true))
+					x++
 				}
+				for ; x < textX; x++ {
+					v.drawCell(x, screenY, ' ', nil, lineNumStyle)
+				}
+				// The indentation of the row is blank
+				v.drawLineEnd(curLineN, textX, screenY)
+			}
+			return screenY-v.y < v.Height
+		}
+		colN := 0
+		for ; colN < len(runes); colN++ {
+			ch := runes[colN]
+			if !nextRow(layout.row[colN]) {
+				break
 			}
+			screenX = textX + layout.col[colN]
 
 			if tabs[curTab].CurView == v.Num && !v.Cursor.HasSelection() && v.Cursor.Y == curLineN && colN == v.Cursor.X {
 				v.DisplayCursor(screenX-v.leftCol, screenY)
@@ -885,7 +1043,7 @@ func (v *View) DisplayView() {
 
 			if v.Buf.Settings["syntax"].(bool) {
 				// Syntax highlighting is enabled
-				highlightStyle = v.matches[viewLine][colN]
+				highlightStyle = v.matches[curLineN-v.Topline][colN]
 			}
 
 			if v.Cursor.HasSelection() &&
@@ -907,6 +1065,14 @@ func (v *View) DisplayView() {
 						break
 					}
 				}
+				if v.diff != nil {
+					lineStyle = v.diff.cellStyle(v, curLineN, colN, lineStyle)
+				} else if style, ok := v.conflictLineStyle(curLineN); ok {
+					_, bg, _ := style.Decompose()
+					lineStyle = lineStyle.Background(bg)
+				} else if style, ok := v.explorerLineStyle(curLineN); ok {
+					lineStyle = withForeground(lineStyle, style)
+				}
 			}
 
 			// We need to display the background of the linestyle with the correct color if cursorline is enabled
@@ -949,37 +1115,34 @@ func (v *View) DisplayView() {
 					v.drawCell(screenX-v.leftCol, screenY, indentChar[0], nil, lineIndentStyle)
 				}
 				// Now the tab has to be displayed as a bunch of spaces
-				visLoc := strWidth
-				remainder := tabSize - (visLoc % tabSize)
-				for i := 0; i < remainder-1; i++ {
-					screenX++
-					if screenX-v.x-v.leftCol >= v.lineNumOffset {
-						v.drawCell(screenX-v.leftCol, screenY, ' ', nil, lineStyle)
+				for i := 1; i < layout.width[colN]; i++ {
+					if screenX+i-v.x-v.leftCol >= v.lineNumOffset {
+						v.drawCell(screenX+i-v.leftCol, screenY, ' ', nil, lineStyle)
 					}
 				}
-				strWidth += remainder
-			} else if runewidth.RuneWidth(ch) > 1 {
+			} else if layout.width[colN] > 0 {
+				// The combining characters which follow are drawn in the same cell
+				var combc []rune
+				for i := colN + 1; i < len(runes) && layout.width[i] == 0; i++ {
+					combc = append(combc, runes[i])
+				}
 				if screenX-v.x-v.leftCol >= v.lineNumOffset {
-					v.drawCell(screenX, screenY, ch, nil, lineStyle)
+					v.drawCell(screenX-v.leftCol, screenY, ch, combc, lineStyle)
 				}
-				for i := 0; i < runewidth.RuneWidth(ch)-1; i++ {
-					screenX++
-					if screenX-v.x-v.leftCol >= v.lineNumOffset {
-						v.drawCell(screenX-v.leftCol, screenY, '<', nil, lineStyle)
+				for i := 1; i < layout.width[colN]; i++ {
+					if screenX+i-v.x-v.leftCol >= v.lineNumOffset {
+						v.drawCell(screenX+i-v.leftCol, screenY, '<', nil, lineStyle)
 					}
 				}
-				strWidth += StringWidth(string(ch), tabSize)
-			} else {
-				if screenX-v.x-v.leftCol >= v.lineNumOffset {
-					v.drawCell(screenX-v.leftCol, screenY, ch, nil, lineStyle)
-				}
-				strWidth += StringWidth(string(ch), tabSize)
 			}
 			charNum = charNum.Move(1, v.Buf)
-			screenX++
-			colN++
+			screenX += layout.width[colN]
+		}
+		// Here we are at a newline, which may be on the next row
+		if !nextRow(layout.row[len(runes)]) {
+			continue
 		}
-		// Here we are at a newline
+		screenX = textX + layout.col[len(runes)]
 
 		if tabs[curTab].CurView == v.Num && !v.Cursor.HasSelection() && v.Cursor.Y == curLineN && colN == v.Cursor.X {
 			v.DisplayCursor(screenX-v.leftCol, screenY)
@@ -996,30 +1159,53 @@ func (v *View) DisplayView() {
 			if style, ok := colorscheme["selection"]; ok {
 				selectStyle = style
 			}
-			v.drawCell(screenX, screenY, ' ', nil, selectStyle)
+			if screenX-v.x-v.leftCol >= v.lineNumOffset {
+				v.drawCell(screenX-v.leftCol, screenY, ' ', nil, selectStyle)
+			}
 			screenX++
 		}
 
-		charNum = charNum.Move(1, v.Buf)
+		if f, ok := v.Buf.closedFold(curLineN); ok {
+			// Show how many lines are folded
+			for _, ch := range foldSummary(f) {
+				if screenX-v.x-v.leftCol >= v.lineNumOffset {
+					v.drawCell(screenX-v.leftCol, screenY, ch, nil, foldStyle())
+				}
+				screenX++
+			}
+		}
 
-		for i := 0; i < v.Width; i++ {
-			lineStyle := defStyle
-			if v.Buf.Settings["cursorline"].(bool) && tabs[curTab].CurView == v.Num && !v.Cursor.HasSelection() && v.Cursor.Y == curLineN {
-				if style, ok := colorscheme["cursor-line"]; ok {
+		v.drawLineEnd(curLineN, screenX, screenY)
+	}
+}
+
+// drawLineEnd clears the rest of a row of the view after the text of a line
+// drawn up to screenX, with the background of the line
+func (v *View) drawLineEnd(curLineN, screenX, screenY int) {
+	for i := 0; i < v.Width; i++ {
+		lineStyle := defStyle
+		if v.diff != nil {
+			if style, ok := v.diff.lineStyle(v, curLineN); ok {
+				lineStyle = style
+			}
+		} else if style, ok := v.conflictLineStyle(curLineN); ok {
+			lineStyle = style
+		}
+		if v.Buf.Settings["cursorline"].(bool) && tabs[curTab].CurView == v.Num && !v.Cursor.HasSelection() && v.Cursor.Y == curLineN {
+			if style, ok := colorscheme["cursor-line"]; ok {
+				fg, _, _ := style.Decompose()
+				lineStyle = lineStyle.Background(fg)
+			}
+		}
+		if screenX-v.x-v.leftCol+i >= v.lineNumOffset {
+			colorcolumn := int(v.Buf.Settings["colorcolumn"].(float64))
+			if colorcolumn != 0 && screenX-v.lineNumOffset+i == colorcolumn-1 {
+				if style, ok := colorscheme["color-column"]; ok {
 					fg, _, _ := style.Decompose()
 					lineStyle = lineStyle.Background(fg)
 				}
 			}
-			if screenX-v.x-v.leftCol+i >= v.lineNumOffset {
-				colorcolumn := int(v.Buf.Settings["colorcolumn"].(float64))
-				if colorcolumn != 0 && screenX-v.lineNumOffset+i == colorcolumn-1 {
-					if style, ok := colorscheme["color-column"]; ok {
-						fg, _, _ := style.Decompose()
-						lineStyle = lineStyle.Background(fg)
-					}
-				}
-				v.drawCell(screenX-v.leftCol+i, screenY, ' ', nil, lineStyle)
-			}
+			v.drawCell(screenX-v.leftCol+i, screenY, ' ', nil, lineStyle)
 		}
 	}
 }
@@ -1038,15 +1224,18 @@ func (v *View) DisplayCursor(x, y int) {
 // Display renders the view, the cursor, and statusline
 func (v *View) Display() {
 	v.DisplayView()
+	v.DisplaySticky()
+	v.DisplayMinimap()
 	// Don't draw the cursor if it is out of the viewport or if it has a selection
-	if (v.Cursor.Y-v.Topline < 0 || v.Cursor.Y-v.Topline > v.Height-1) || v.Cursor.HasSelection() {
+	row := v.screenRow(v.Cursor.Loc)
+	if (row < 0 || row > v.Height-1) || v.Cursor.HasSelection() {
 		screen.HideCursor()
 	}
 	_, screenH := screen.Size()
 	if v.Buf.Settings["statusline"].(bool) {
 		v.sline.Display()
 	} else if (v.y + v.Height) != screenH-1 {
-		for x := 0; x < v.Width; x++ {
+		for x := 0; x < v.Width+v.mapWidth; x++ {
 			screen.SetContent(v.x+x, v.y+v.Height, '-', nil, defStyle.Reverse(true))
 		}
 	}
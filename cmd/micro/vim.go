@@ -0,0 +1,910 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zyedidia/clipboard"
+	"github.com/zyedidia/tcell"
+)
+
+// The modes of the vim layer
+// Operator-pending mode is normal mode with an operator typed
+const (
+	vimNormal = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+)
+
+// The results of parsing the keys typed in normal or visual mode
+const (
+	vimPending = iota
+	vimDone
+	vimBad
+)
+
+const (
+	vimOperators = "dcy<>"
+	vimMotions   = "hjklwWeEbB0^$G%"
+	vimActions   = "iaIAoOxXpPuJDCYsS~.vVnN/:"
+	// The objects which can follow i or a after an operator or in visual mode
+	vimObjects = "wW\"'`()b{}B[]<>p"
+	// The actions in visual mode, the operators are handled separately
+	vimVisualActions = "xsJoOvVpP~:"
+)
+
+// A vimCommand is a command typed in normal or visual mode
+type vimCommand struct {
+	// The register given with ", or 0
+	register rune
+	// The count, or 0 if none was typed
+	count    int
+	operator rune
+	// A motion or text object, or the operator again for linewise operators (dd)
+	motion string
+	action rune
+	// The character given to f, t, F, T and r
+	arg rune
+}
+
+// A vimRegister holds text which was yanked or deleted
+type vimRegister struct {
+	text     string
+	linewise bool
+}
+
+// A vimChange is the last change, repeated with .
+type vimChange struct {
+	cmd vimCommand
+	// The text typed in insert mode if the change started insert mode
+	text string
+	// For changes made in visual mode, the size of the selection
+	visual      int
+	visualLines int
+	visualChars int
+}
+
+// vimState holds the mode of a view and the keys typed so far
+type vimState struct {
+	mode int
+	keys []rune
+	// The other end of the visual selection
+	anchor Loc
+	// What is typed in insert mode, along with the count and command which started it
+	insertText  string
+	insertCount int
+	change      *vimChange
+}
+
+var (
+	vimRegisters  = make(map[rune]vimRegister)
+	vimLastChange *vimChange
+	vimRepeating  bool
+)
+
+// vimCount reads a count from keys starting at i
+func vimCount(keys []rune, i int) (int, int) {
+	n := 0
+	for i < len(keys) && keys[i] >= '0' && keys[i] <= '9' && (n > 0 || keys[i] != '0') {
+		n = n*10 + int(keys[i]-'0')
+		i++
+	}
+	return n, i
+}
+
+// parseVimMotion parses a motion, or a text object if objects is set, starting at keys[i]
+func parseVimMotion(keys []rune, i int, objects bool, cmd *vimCommand) int {
+	r := keys[i]
+	switch {
+	case strings.ContainsRune(vimMotions, r):
+		cmd.motion = string(r)
+		i++
+	case r == 'g':
+		if i+1 >= len(keys) {
+			return vimPending
+		}
+		if !strings.ContainsRune("gjk0$", keys[i+1]) {
+			return vimBad
+		}
+		cmd.motion = string(keys[i : i+2])
+		i += 2
+	case strings.ContainsRune("fFtT", r):
+		if i+1 >= len(keys) {
+			return vimPending
+		}
+		cmd.motion, cmd.arg = string(r), keys[i+1]
+		i += 2
+	case objects && (r == 'i' || r == 'a'):
+		if i+1 >= len(keys) {
+			return vimPending
+		}
+		if !strings.ContainsRune(vimObjects, keys[i+1]) {
+			return vimBad
+		}
+		cmd.motion = string(r) + string(keys[i+1])
+		i += 2
+	default:
+		return vimBad
+	}
+	if i != len(keys) {
+		return vimBad
+	}
+	return vimDone
+}
+
+// parseVimCommand parses the keys typed in normal or visual mode
+func parseVimCommand(keys []rune, visual bool) (vimCommand, int) {
+	var cmd vimCommand
+	i := 0
+	if len(keys) > 0 && keys[0] == '"' {
+		if len(keys) < 2 {
+			return cmd, vimPending
+		}
+		cmd.register = keys[1]
+		i = 2
+	}
+	cmd.count, i = vimCount(keys, i)
+	if i >= len(keys) {
+		return cmd, vimPending
+	}
+	r := keys[i]
+	i++
+
+	if r == 'r' {
+		if i >= len(keys) {
+			return cmd, vimPending
+		}
+		cmd.action, cmd.arg = r, keys[i]
+		if i+1 != len(keys) {
+			return cmd, vimBad
+		}
+		return cmd, vimDone
+	}
+
+	if strings.ContainsRune(vimOperators, r) {
+		cmd.operator = r
+		if visual {
+			if i != len(keys) {
+				return cmd, vimBad
+			}
+			return cmd, vimDone
+		}
+		// A count can also be typed between the operator and the motion
+		n, j := vimCount(keys, i)
+		if n > 0 {
+			cmd.count = Max(cmd.count, 1) * n
+		}
+		if j >= len(keys) {
+			return cmd, vimPending
+		}
+		if keys[j] == r {
+			cmd.motion = string(r)
+			if j+1 != len(keys) {
+				return cmd, vimBad
+			}
+			return cmd, vimDone
+		}
+		return cmd, parseVimMotion(keys, j, true, &cmd)
+	}
+
+	actions := vimActions
+	if visual {
+		actions = vimVisualActions
+	}
+	if strings.ContainsRune(actions, r) {
+		cmd.action = r
+		if i != len(keys) {
+			return cmd, vimBad
+		}
+		return cmd, vimDone
+	}
+	return cmd, parseVimMotion(keys, i-1, visual, &cmd)
+}
+
+// vimKey translates a key event into the key typed in normal or visual mode
+// It returns false for keys which should be handled by the normal bindings
+func vimKey(e *tcell.EventKey) (rune, bool) {
+	switch e.Key() {
+	case tcell.KeyRune:
+		if e.Modifiers()&(tcell.ModCtrl|tcell.ModAlt) == 0 {
+			return e.Rune(), true
+		}
+	case tcell.KeyUp:
+		return 'k', e.Modifiers() == 0
+	case tcell.KeyDown:
+		return 'j', e.Modifiers() == 0
+	case tcell.KeyLeft, tcell.KeyBackspace, tcell.KeyBackspace2:
+		return 'h', e.Modifiers() == 0
+	case tcell.KeyRight:
+		return 'l', e.Modifiers() == 0
+	case tcell.KeyEnter:
+		return 'j', true
+	case tcell.KeyDelete:
+		return 'x', true
+	case tcell.KeyTab:
+		// Don't insert a tab in normal mode
+		return 0, true
+	}
+	return 0, false
+}
+
+// vimModeName returns the name of the mode shown in the statusline
+func (v *View) vimModeName() string {
+	s := &v.vim
+	name := "NORMAL"
+	switch s.mode {
+	case vimInsert:
+		name = "INSERT"
+	case vimVisual:
+		name = "VISUAL"
+	case vimVisualLine:
+		name = "VISUAL LINE"
+	default:
+		if cmd, status := parseVimCommand(s.keys, false); status == vimPending && cmd.operator != 0 {
+			name = "OPERATOR"
+		}
+	}
+	if len(s.keys) > 0 {
+		name += " " + string(s.keys)
+	}
+	return name
+}
+
+// vimHandleKey handles a key when vim mode is on
+// It returns false if the key should be handled by the normal bindings
+func (v *View) vimHandleKey(e *tcell.EventKey) bool {
+	s := &v.vim
+	if s.mode == vimInsert {
+		return v.vimInsertKey(e)
+	}
+
+	if e.Key() == tcell.KeyEscape {
+		s.keys = nil
+		if s.mode != vimNormal {
+			v.vimExitVisual()
+		} else if searching || lastSearch != "" {
+			ExitSearch(v)
+		}
+		return true
+	}
+	if e.Key() == tcell.KeyCtrlR && len(s.keys) == 0 {
+		v.Redo(true)
+		v.vimClampCursor()
+		return true
+	}
+
+	r, ok := vimKey(e)
+	if !ok {
+		s.keys = nil
+		return false
+	}
+	if r == 0 {
+		return true
+	}
+	s.keys = append(s.keys, r)
+	cmd, status := parseVimCommand(s.keys, s.mode != vimNormal)
+	switch status {
+	case vimBad:
+		s.keys = nil
+	case vimDone:
+		s.keys = nil
+		v.vimExecute(cmd)
+	}
+	return true
+}
+
+// vimInsertKey records the text typed in insert mode so it can be repeated
+func (v *View) vimInsertKey(e *tcell.EventKey) bool {
+	s := &v.vim
+	switch e.Key() {
+	case tcell.KeyEscape:
+		v.vimExitInsert()
+		return true
+	case tcell.KeyRune:
+		if e.Modifiers()&(tcell.ModCtrl|tcell.ModAlt) == 0 {
+			s.insertText += string(e.Rune())
+		}
+	case tcell.KeyEnter:
+		s.insertText += "\n"
+	case tcell.KeyTab:
+		s.insertText += "\t"
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if t := []rune(s.insertText); len(t) > 0 {
+			s.insertText = string(t[:len(t)-1])
+		}
+	}
+	return false
+}
+
+// vimInsertText inserts text recorded in insert mode
+func (v *View) vimInsertText(text string) {
+	for _, r := range text {
+		switch r {
+		case '\n':
+			v.InsertNewline(false)
+		case '\t':
+			v.InsertTab(false)
+		default:
+			v.Buf.Insert(v.Cursor.Loc, string(r))
+			v.Cursor.Right()
+		}
+	}
+}
+
+// vimStartInsert enters insert mode
+// The command is remembered so . can repeat it along with the typed text
+func (v *View) vimStartInsert(cmd vimCommand, change *vimChange) {
+	s := &v.vim
+	s.mode = vimInsert
+	s.insertText = ""
+	s.insertCount = 1
+	if cmd.operator == 0 {
+		s.insertCount = Max(cmd.count, 1)
+	}
+	if change == nil {
+		change = &vimChange{cmd: cmd}
+	}
+	s.change = change
+}
+
+// vimExitInsert goes back to normal mode
+func (v *View) vimExitInsert() {
+	s := &v.vim
+	for i := 1; i < s.insertCount; i++ {
+		v.vimInsertText(s.insertText)
+	}
+	if s.change != nil {
+		s.change.text = s.insertText
+		vimLastChange = s.change
+		s.change = nil
+	}
+	s.mode = vimNormal
+	if v.Cursor.X > 0 {
+		v.Cursor.Left()
+	}
+	v.vimClampCursor()
+}
+
+// vimClampCursor keeps the cursor on a character in normal and visual mode
+func (v *View) vimClampCursor() {
+	v.Cursor.Relocate()
+	if n := Count(v.Buf.Line(v.Cursor.Y)); v.Cursor.X >= n && n > 0 {
+		v.Cursor.X = n - 1
+	}
+	v.Cursor.LastVisualX = v.Cursor.GetVisualX()
+}
+
+// vimRecord remembers a change so it can be repeated with .
+func vimRecord(change *vimChange) {
+	if !vimRepeating {
+		vimLastChange = change
+	}
+}
+
+// vimGetRegister returns the contents of a register
+func vimGetRegister(r rune) vimRegister {
+	switch r {
+	case 0:
+		r = '"'
+	case '+', '*':
+		target := "clipboard"
+		if r == '*' {
+			target = "primary"
+		}
+		clip, _ := clipboard.ReadAll(target)
+		return vimRegister{clip, strings.HasSuffix(clip, "\n")}
+	}
+	return vimRegisters[unicode.ToLower(r)]
+}
+
+// vimSetRegister stores yanked or deleted text in a register and the unnamed register
+func vimSetRegister(r rune, reg vimRegister, yank bool) {
+	switch {
+	case r == '_':
+		return
+	case r == '+' || r == '*':
+		target := "clipboard"
+		if r == '*' {
+			target = "primary"
+		}
+		clipboard.WriteAll(reg.text, target)
+	case unicode.IsUpper(r):
+		// Uppercase registers append to the lowercase ones
+		r = unicode.ToLower(r)
+		prev := vimRegisters[r]
+		reg = vimRegister{prev.text + reg.text, prev.linewise || reg.linewise}
+	}
+	if r != 0 && r != '"' {
+		vimRegisters[r] = reg
+	}
+	vimRegisters['"'] = reg
+	if yank && r == 0 {
+		vimRegisters['0'] = reg
+	}
+}
+
+// vimLinesRange returns the range of text covering whole lines, including
+// the newline before them if they are the last lines of the buffer
+func (v *View) vimLinesRange(y1, y2 int) (Loc, Loc) {
+	if y2 < v.Buf.NumLines-1 {
+		return Loc{0, y1}, Loc{0, y2 + 1}
+	}
+	if y1 > 0 {
+		return Loc{Count(v.Buf.Line(y1 - 1)), y1 - 1}, v.Buf.End()
+	}
+	return v.Buf.Start(), v.Buf.End()
+}
+
+// vimLinesText returns the text of the lines, each one ending with a newline
+func (v *View) vimLinesText(y1, y2 int) string {
+	return strings.Join(v.Buf.Lines(y1, y2+1), "\n") + "\n"
+}
+
+// vimOperatorRange returns the text the operator of a normal mode command works on
+func (v *View) vimOperatorRange(cmd vimCommand) (start, end Loc, linewise, ok bool) {
+	n := Max(cmd.count, 1)
+	if cmd.motion == string(cmd.operator) {
+		y2 := Min(v.Cursor.Y+n-1, v.Buf.NumLines-1)
+		return Loc{0, v.Cursor.Y}, Loc{0, y2}, true, true
+	}
+	if len(cmd.motion) == 2 && (cmd.motion[0] == 'i' || cmd.motion[0] == 'a') {
+		return v.vimTextObject(cmd.motion)
+	}
+
+	motion := cmd.motion
+	if cmd.operator == 'c' && (motion == "w" || motion == "W") &&
+		vimClass(v.Cursor.RuneUnder(v.Cursor.X), false) != 0 {
+		// cw changes to the end of the word like ce
+		motion = strings.Replace(motion, "w", "e", 1)
+		motion = strings.Replace(motion, "W", "E", 1)
+	}
+	target, kind, ok := v.vimMotion(motion, cmd.arg, cmd.count, cmd.operator)
+	if !ok {
+		return
+	}
+	start, end = v.Cursor.Loc, target
+	if end.LessThan(start) {
+		start, end = end, start
+	}
+	switch kind {
+	case vimLinewise:
+		return Loc{0, start.Y}, Loc{0, end.Y}, true, true
+	case vimInclusive:
+		end = end.Move(1, v.Buf)
+	}
+	return start, end, false, true
+}
+
+// vimApply runs an operator on a range of text
+// For linewise ranges start.Y and end.Y are the first and last lines
+func (v *View) vimApply(cmd vimCommand, start, end Loc, linewise bool, change *vimChange) {
+	var reg vimRegister
+	if linewise {
+		reg = vimRegister{v.vimLinesText(start.Y, end.Y), true}
+	} else {
+		reg = vimRegister{v.Buf.Substr(start, end), false}
+	}
+
+	switch cmd.operator {
+	case 'y':
+		vimSetRegister(cmd.register, reg, true)
+		if !linewise {
+			v.Cursor.Loc = start
+		}
+	case 'd':
+		vimSetRegister(cmd.register, reg, false)
+		if linewise {
+			from, to := v.vimLinesRange(start.Y, end.Y)
+			v.Buf.Remove(from, to)
+			v.Cursor.Y = Min(start.Y, v.Buf.NumLines-1)
+			v.Cursor.X = vimFirstNonBlank(v.Buf, v.Cursor.Y)
+		} else {
+			v.Buf.Remove(start, end)
+			v.Cursor.Loc = start
+		}
+		vimRecord(change)
+	case 'c':
+		vimSetRegister(cmd.register, reg, false)
+		if linewise {
+			// Keep the indentation of the first line
+			ws := Count(GetLeadingWhitespace(v.Buf.Line(start.Y)))
+			v.Buf.Remove(Loc{ws, start.Y}, Loc{Count(v.Buf.Line(end.Y)), end.Y})
+			v.Cursor.Loc = Loc{ws, start.Y}
+		} else {
+			v.Buf.Remove(start, end)
+			v.Cursor.Loc = start
+		}
+		v.Cursor.Relocate()
+		v.vimStartInsert(cmd, change)
+		return
+	case '>', '<':
+		if !linewise {
+			end = end.Move(-1, v.Buf)
+		}
+		from, to := Loc{0, start.Y}, Loc{0, end.Y + 1}
+		if end.Y >= v.Buf.NumLines-1 {
+			to = v.Buf.End()
+		}
+		v.Cursor.SetSelectionStart(from)
+		v.Cursor.SetSelectionEnd(to)
+		if cmd.operator == '>' {
+			v.IndentSelection(false)
+		} else {
+			v.OutdentSelection(false)
+		}
+		v.Cursor.ResetSelection()
+		v.Cursor.Loc = Loc{vimFirstNonBlank(v.Buf, start.Y), start.Y}
+		vimRecord(change)
+	}
+	v.vimClampCursor()
+}
+
+// vimPaste puts the contents of a register after or before the cursor
+func (v *View) vimPaste(reg vimRegister, after bool, count int) {
+	text := strings.Repeat(reg.text, count)
+	if text == "" {
+		return
+	}
+	if reg.linewise {
+		y := v.Cursor.Y
+		if after {
+			y++
+		}
+		if y >= v.Buf.NumLines {
+			v.Buf.Insert(v.Buf.End(), "\n"+strings.TrimSuffix(text, "\n"))
+		} else {
+			v.Buf.Insert(Loc{0, y}, text)
+		}
+		v.Cursor.Loc = Loc{vimFirstNonBlank(v.Buf, y), y}
+	} else {
+		loc := v.Cursor.Loc
+		if after && Count(v.Buf.Line(loc.Y)) > 0 {
+			loc.X++
+		}
+		v.Buf.Insert(loc, text)
+		v.Cursor.Loc = loc.Move(Count(text)-1, v.Buf)
+	}
+	v.vimClampCursor()
+}
+
+// vimJoin joins n lines starting at the cursor line
+func (v *View) vimJoin(n int) {
+	for i := 0; i < Max(n-1, 1) && v.Cursor.Y < v.Buf.NumLines-1; i++ {
+		y := v.Cursor.Y
+		line := v.Buf.Line(y)
+		next := v.Buf.Line(y + 1)
+		sep := " "
+		if strings.TrimSpace(next) == "" || line == "" || strings.HasSuffix(line, " ") {
+			sep = ""
+		}
+		v.Buf.Replace(Loc{Count(line), y}, Loc{Count(GetLeadingWhitespace(next)), y + 1}, sep)
+		v.Cursor.Loc = Loc{Count(line), y}
+	}
+	v.vimClampCursor()
+}
+
+// vimMapRunes replaces the runes between start and end on one line using f
+func (v *View) vimMapRunes(start, end Loc, f func(rune) rune) {
+	for y := start.Y; y <= end.Y && y < v.Buf.NumLines; y++ {
+		line := []rune(v.Buf.Line(y))
+		s, e := 0, len(line)
+		if y == start.Y {
+			s = start.X
+		}
+		if y == end.Y {
+			e = Min(end.X, len(line))
+		}
+		if s >= e {
+			continue
+		}
+		mapped := make([]rune, 0, e-s)
+		for _, r := range line[s:e] {
+			mapped = append(mapped, f(r))
+		}
+		v.Buf.Replace(Loc{s, y}, Loc{e, y}, string(mapped))
+	}
+}
+
+// vimToggleCase swaps the case of a rune
+func vimToggleCase(r rune) rune {
+	if unicode.IsUpper(r) {
+		return unicode.ToLower(r)
+	}
+	return unicode.ToUpper(r)
+}
+
+// vimExecute runs a complete command
+func (v *View) vimExecute(cmd vimCommand) {
+	if v.vim.mode == vimVisual || v.vim.mode == vimVisualLine {
+		v.vimExecuteVisual(cmd)
+		return
+	}
+	n := Max(cmd.count, 1)
+	change := &vimChange{cmd: cmd}
+
+	if cmd.operator != 0 {
+		if start, end, linewise, ok := v.vimOperatorRange(cmd); ok {
+			v.vimApply(cmd, start, end, linewise, change)
+		}
+		return
+	}
+
+	// Shorthands for operators
+	short := map[rune]vimCommand{
+		'x': {operator: 'd', motion: "l"},
+		'X': {operator: 'd', motion: "h"},
+		'D': {operator: 'd', motion: "$"},
+		'C': {operator: 'c', motion: "$"},
+		's': {operator: 'c', motion: "l"},
+		'S': {operator: 'c', motion: "c"},
+		'Y': {operator: 'y', motion: "y"},
+	}
+	if op, ok := short[cmd.action]; ok {
+		op.register, op.count = cmd.register, cmd.count
+		if strings.ContainsRune("DC", cmd.action) {
+			// The count of D and C is the number of lines
+			op.count = 0
+			if n > 1 {
+				op.motion = "j"
+				op.count = n - 1
+				end := Min(v.Cursor.Y+n-1, v.Buf.NumLines-1)
+				v.vimApply(op, v.Cursor.Loc, Loc{Count(v.Buf.Line(end)), end}, false, change)
+				return
+			}
+		}
+		if start, end, linewise, ok := v.vimOperatorRange(op); ok && (start != end || linewise) {
+			v.vimApply(op, start, end, linewise, change)
+		}
+		return
+	}
+
+	switch cmd.action {
+	case 0:
+		loc, kind, ok := v.vimMotion(cmd.motion, cmd.arg, cmd.count, 0)
+		if !ok {
+			return
+		}
+		if cmd.motion != "G" && cmd.motion != "gg" && cmd.motion != "$" && cmd.motion != "%" &&
+			strings.ContainsRune("wWeEbBhl0^fFtT", rune(cmd.motion[0])) {
+			// Motions within a line remember the column
+			v.Cursor.Loc = loc
+			v.vimClampCursor()
+		} else {
+			v.Cursor.Loc = loc
+			lastX := v.Cursor.LastVisualX
+			v.vimClampCursor()
+			if kind == vimLinewise && cmd.motion != "G" && cmd.motion != "gg" {
+				v.Cursor.LastVisualX = lastX
+			}
+		}
+	case 'i':
+		v.vimStartInsert(cmd, change)
+	case 'a':
+		if Count(v.Buf.Line(v.Cursor.Y)) > 0 {
+			v.Cursor.X++
+		}
+		v.vimStartInsert(cmd, change)
+	case 'I':
+		v.Cursor.X = vimFirstNonBlank(v.Buf, v.Cursor.Y)
+		v.vimStartInsert(cmd, change)
+	case 'A':
+		v.Cursor.End()
+		v.vimStartInsert(cmd, change)
+	case 'o':
+		v.Cursor.End()
+		v.InsertNewline(false)
+		v.vimStartInsert(cmd, change)
+	case 'O':
+		ws := GetLeadingWhitespace(v.Buf.Line(v.Cursor.Y))
+		v.Buf.Insert(Loc{0, v.Cursor.Y}, ws+"\n")
+		v.Cursor.Loc = Loc{Count(ws), v.Cursor.Y}
+		v.vimStartInsert(cmd, change)
+	case 'p', 'P':
+		v.vimPaste(vimGetRegister(cmd.register), cmd.action == 'p', n)
+		vimRecord(change)
+	case 'u':
+		for i := 0; i < n; i++ {
+			v.Undo(true)
+		}
+		v.vimClampCursor()
+	case 'J':
+		v.vimJoin(n)
+		vimRecord(change)
+	case '~':
+		end := Min(v.Cursor.X+n, Count(v.Buf.Line(v.Cursor.Y)))
+		v.vimMapRunes(v.Cursor.Loc, Loc{end, v.Cursor.Y}, vimToggleCase)
+		v.Cursor.X = end
+		v.vimClampCursor()
+		vimRecord(change)
+	case 'r':
+		if v.Cursor.X+n > Count(v.Buf.Line(v.Cursor.Y)) {
+			return
+		}
+		v.Buf.Replace(v.Cursor.Loc, Loc{v.Cursor.X + n, v.Cursor.Y}, strings.Repeat(string(cmd.arg), n))
+		v.Cursor.X += n - 1
+		v.vimClampCursor()
+		vimRecord(change)
+	case '.':
+		v.vimRepeat(cmd.count)
+	case 'v', 'V':
+		v.vim.mode = vimVisual
+		if cmd.action == 'V' {
+			v.vim.mode = vimVisualLine
+		}
+		v.vim.anchor = v.Cursor.Loc
+		v.vimUpdateSelection()
+	case 'n':
+		v.FindNext(true)
+	case 'N':
+		v.FindPrevious(true)
+	case '/':
+		v.Find(true)
+	case ':':
+		v.CommandMode(true)
+	}
+}
+
+// vimSelection returns the range of the visual selection
+// For visual line mode start.Y and end.Y are the first and last lines
+func (v *View) vimSelection() (start, end Loc) {
+	start, end = v.vim.anchor, v.Cursor.Loc
+	if end.LessThan(start) {
+		start, end = end, start
+	}
+	if v.vim.mode == vimVisualLine {
+		return Loc{0, start.Y}, Loc{0, end.Y}
+	}
+	return start, end.Move(1, v.Buf)
+}
+
+// vimUpdateSelection shows the visual selection with the cursor's selection
+func (v *View) vimUpdateSelection() {
+	start, end := v.vimSelection()
+	if v.vim.mode == vimVisualLine {
+		start, end = Loc{0, start.Y}, Loc{0, end.Y + 1}
+		if end.Y >= v.Buf.NumLines {
+			end = v.Buf.End()
+		}
+	}
+	if end.GreaterThan(v.Buf.End()) {
+		end = v.Buf.End()
+	}
+	v.Cursor.SetSelectionStart(start)
+	v.Cursor.SetSelectionEnd(end)
+}
+
+// vimExitVisual goes back to normal mode from visual mode
+func (v *View) vimExitVisual() {
+	v.vim.mode = vimNormal
+	v.Cursor.ResetSelection()
+	v.vimClampCursor()
+}
+
+// vimExecuteVisual runs a complete command in visual mode
+func (v *View) vimExecuteVisual(cmd vimCommand) {
+	s := &v.vim
+	linewise := s.mode == vimVisualLine
+	start, end := v.vimSelection()
+	change := &vimChange{cmd: cmd, visual: s.mode, visualLines: end.Y - start.Y}
+	if !linewise && start.Y == end.Y {
+		change.visualChars = end.X - start.X
+	}
+
+	switch {
+	case cmd.action == 'x':
+		cmd.operator, cmd.action = 'd', 0
+	case cmd.action == 's':
+		cmd.operator, cmd.action = 'c', 0
+	}
+
+	if cmd.operator != 0 {
+		v.Cursor.ResetSelection()
+		s.mode = vimNormal
+		v.vimApply(cmd, start, end, linewise, change)
+		return
+	}
+
+	switch cmd.action {
+	case 0:
+		if len(cmd.motion) == 2 && (cmd.motion[0] == 'i' || cmd.motion[0] == 'a') {
+			// Text objects select the object
+			tStart, tEnd, tLinewise, ok := v.vimTextObject(cmd.motion)
+			if !ok {
+				return
+			}
+			if tLinewise {
+				s.mode = vimVisualLine
+				s.anchor, v.Cursor.Loc = tStart, tEnd
+			} else {
+				s.anchor, v.Cursor.Loc = tStart, tEnd.Move(-1, v.Buf)
+			}
+		} else if loc, _, ok := v.vimMotion(cmd.motion, cmd.arg, cmd.count, 0); ok {
+			v.Cursor.Loc = loc
+		}
+		v.vimClampCursor()
+		v.vimUpdateSelection()
+	case 'o', 'O':
+		s.anchor, v.Cursor.Loc = v.Cursor.Loc, s.anchor
+		v.vimUpdateSelection()
+	case 'v', 'V':
+		mode := vimVisual
+		if cmd.action == 'V' {
+			mode = vimVisualLine
+		}
+		if s.mode == mode {
+			v.vimExitVisual()
+		} else {
+			s.mode = mode
+			v.vimUpdateSelection()
+		}
+	case 'J':
+		v.vimExitVisual()
+		v.Cursor.Loc = Loc{0, start.Y}
+		v.vimJoin(end.Y - start.Y + 1)
+		vimRecord(change)
+	case '~':
+		v.vimExitVisual()
+		if linewise {
+			end = Loc{Count(v.Buf.Line(end.Y)), end.Y}
+		}
+		v.vimMapRunes(start, end, vimToggleCase)
+		v.Cursor.Loc = Loc{0, start.Y}
+		if !linewise {
+			v.Cursor.Loc = start
+		}
+		v.vimClampCursor()
+		vimRecord(change)
+	case 'p', 'P':
+		// Replace the selection with the register
+		reg := vimGetRegister(cmd.register)
+		v.vimExitVisual()
+		v.vimApply(vimCommand{operator: 'd', register: '_'}, start, end, linewise, nil)
+		v.vimPaste(reg, linewise && v.Cursor.Y < start.Y, Max(cmd.count, 1))
+	case 'r':
+		v.vimExitVisual()
+		if linewise {
+			end = Loc{Count(v.Buf.Line(end.Y)), end.Y}
+		}
+		v.vimMapRunes(start, end, func(rune) rune { return cmd.arg })
+		v.Cursor.Loc = start
+		v.vimClampCursor()
+		vimRecord(change)
+	case ':':
+		v.CommandMode(true)
+	}
+}
+
+// vimRepeat repeats the last change, with a new count if one is given
+func (v *View) vimRepeat(count int) {
+	change := vimLastChange
+	if change == nil {
+		return
+	}
+	cmd := change.cmd
+	if count > 0 {
+		cmd.count = count
+	}
+
+	vimRepeating = true
+	defer func() { vimRepeating = false }()
+
+	if change.visual != 0 {
+		// Select the same amount of text as the original selection
+		v.vim.mode = change.visual
+		v.vim.anchor = v.Cursor.Loc
+		end := Loc{v.Cursor.X, Min(v.Cursor.Y+change.visualLines, v.Buf.NumLines-1)}
+		if change.visualLines == 0 && change.visual == vimVisual {
+			end.X = v.Cursor.X + Max(change.visualChars, 1) - 1
+		}
+		v.Cursor.Loc = end
+		v.vimClampCursor()
+	}
+	v.vimExecute(cmd)
+	if v.vim.mode == vimInsert {
+		v.vimInsertText(change.text)
+		v.vim.insertText = change.text
+		v.vim.change = nil
+		v.vimExitInsert()
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/zyedidia/tcell"
+)
+
+func TestParseVimCommand(t *testing.T) {
+	var tests = []struct {
+		keys   string
+		visual bool
+		status int
+		cmd    vimCommand
+	}{
+		{"d", false, vimPending, vimCommand{operator: 'd'}},
+		{"dw", false, vimDone, vimCommand{operator: 'd', motion: "w"}},
+		{"3dd", false, vimDone, vimCommand{count: 3, operator: 'd', motion: "d"}},
+		{"2d3w", false, vimDone, vimCommand{count: 6, operator: 'd', motion: "w"}},
+		{"ci\"", false, vimDone, vimCommand{operator: 'c', motion: "i\""}},
+		{"\"ayy", false, vimDone, vimCommand{register: 'a', operator: 'y', motion: "y"}},
+		{"dfx", false, vimDone, vimCommand{operator: 'd', motion: "f", arg: 'x'}},
+		{"g", false, vimPending, vimCommand{}},
+		{"gg", false, vimDone, vimCommand{motion: "gg"}},
+		{"2gj", false, vimDone, vimCommand{count: 2, motion: "gj"}},
+		{"gx", false, vimBad, vimCommand{}},
+		{"10G", false, vimDone, vimCommand{count: 10, motion: "G"}},
+		{"0", false, vimDone, vimCommand{motion: "0"}},
+		{"rx", false, vimDone, vimCommand{action: 'r', arg: 'x'}},
+		{"2.", false, vimDone, vimCommand{count: 2, action: '.'}},
+		{"i", false, vimDone, vimCommand{action: 'i'}},
+		{"dq", false, vimBad, vimCommand{operator: 'd'}},
+		{"diq", false, vimBad, vimCommand{operator: 'd'}},
+		{"z", false, vimBad, vimCommand{}},
+		{"d", true, vimDone, vimCommand{operator: 'd'}},
+		{"ip", true, vimDone, vimCommand{motion: "ip"}},
+		{"i", true, vimPending, vimCommand{}},
+		{"o", true, vimDone, vimCommand{action: 'o'}},
+	}
+	for _, test := range tests {
+		cmd, status := parseVimCommand([]rune(test.keys), test.visual)
+		if status != test.status {
+			t.Errorf("parseVimCommand(%q) status = %d, want %d", test.keys, status, test.status)
+			continue
+		}
+		if status != vimBad && cmd != test.cmd {
+			t.Errorf("parseVimCommand(%q) = %+v, want %+v", test.keys, cmd, test.cmd)
+		}
+	}
+}
+
+func TestVimClass(t *testing.T) {
+	var tests = []struct {
+		r    rune
+		big  bool
+		want int
+	}{
+		{' ', false, 0},
+		{'\t', true, 0},
+		{'a', false, 1},
+		{'_', false, 1},
+		{'.', false, 2},
+		{'.', true, 1},
+	}
+	for _, test := range tests {
+		if got := vimClass(test.r, test.big); got != test.want {
+			t.Errorf("vimClass(%q, %v) = %d, want %d", test.r, test.big, got, test.want)
+		}
+	}
+}
+
+// newVimView returns a view of the text in vim normal mode
+func newVimView(text string) *View {
+	globalSettings = DefaultGlobalSettings()
+	globalSettings["vimmode"] = true
+	s := tcell.NewSimulationScreen("")
+	s.Init()
+	screen = s
+	messenger = new(Messenger)
+	autocomplete = new(AutocompletionBox)
+	template = new(TemplateBox)
+	hover = new(HoverBox)
+	signatureBox = new(SignatureBox)
+	InitBindings()
+	vimRegisters = make(map[rune]vimRegister)
+	vimLastChange = nil
+	return NewViewWidthHeight(NewBufferFromString(text, ""), 80, 24)
+}
+
+// vimType sends keys to a view, where \x1b is Esc
+func vimType(v *View, keys string) {
+	for _, r := range keys {
+		if r == '\x1b' {
+			v.HandleEvent(tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+		} else {
+			v.HandleEvent(tcell.NewEventKey(tcell.KeyRune, r, 0))
+		}
+	}
+}
+
+func TestVimCommands(t *testing.T) {
+	const text = "one two three\nfour five\nsix\n\nseven eight\n"
+	var tests = []struct {
+		text string
+		keys string
+		want string
+		loc  Loc
+	}{
+		// Operators with motions
+		{text, "dw", "two three\nfour five\nsix\n\nseven eight\n", Loc{0, 0}},
+		{text, "wcwxx\x1b", "one xx three\nfour five\nsix\n\nseven eight\n", Loc{5, 0}},
+		{text, "d2j", "\nseven eight\n", Loc{0, 0}},
+		{text, "2dd", "six\n\nseven eight\n", Loc{0, 0}},
+		{text, "wd$", "one \nfour five\nsix\n\nseven eight\n", Loc{3, 0}},
+		{text, "dfe", " two three\nfour five\nsix\n\nseven eight\n", Loc{0, 0}},
+		{"f(a, (b)) x", "f(d%", "f x", Loc{1, 0}},
+		// Text objects
+		{`say "hello there" now`, `fhci"bye` + "\x1b", `say "bye" now`, Loc{7, 0}},
+		{text, "dip", "\nseven eight\n", Loc{0, 0}},
+		{text, "wdiw", "one  three\nfour five\nsix\n\nseven eight\n", Loc{4, 0}},
+		// Registers and pasting
+		{text, "yyp", "one two three\none two three\nfour five\nsix\n\nseven eight\n", Loc{0, 1}},
+		{text, "\"ayyjj\"ap", "one two three\nfour five\nsix\none two three\n\nseven eight\n", Loc{0, 3}},
+		{text, "\"adwjdw\"aP", "two three\none five\nsix\n\nseven eight\n", Loc{3, 1}},
+		// Repeating the last change
+		{text, "dw.", "three\nfour five\nsix\n\nseven eight\n", Loc{0, 0}},
+		{text, "Ax\x1bj.", "one two threex\nfour fivex\nsix\n\nseven eight\n", Loc{9, 1}},
+		// Visual and visual line mode
+		{text, "ved", " two three\nfour five\nsix\n\nseven eight\n", Loc{0, 0}},
+		{text, "vey$p", "one two threeone\nfour five\nsix\n\nseven eight\n", Loc{15, 0}},
+		{text, "Vjd", "six\n\nseven eight\n", Loc{0, 0}},
+		{text, "Vj>", "\tone two three\n\tfour five\nsix\n\nseven eight\n", Loc{1, 0}},
+	}
+	for _, test := range tests {
+		v := newVimView(test.text)
+		vimType(v, test.keys)
+		if got := v.Buf.String(); got != test.want || v.Cursor.Loc != test.loc {
+			t.Errorf("%q on %q: got %q at %v, want %q at %v", test.keys, test.text, got, v.Cursor.Loc, test.want, test.loc)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"strings"
+)
+
+// The kinds of vim motions, which decide the text an operator works on
+const (
+	// The character under the target is not included
+	vimExclusive = iota
+	// The character under the target is included
+	vimInclusive
+	// Whole lines are included
+	vimLinewise
+)
+
+// vimClass returns the class of a rune for word motions: 0 for whitespace,
+// 1 for word characters and 2 for punctuation
+// With big set, WORD motions treat everything but whitespace as one class
+func vimClass(r rune, big bool) int {
+	if IsWhitespace(r) {
+		return 0
+	}
+	if big || IsWordChar(string(r)) {
+		return 1
+	}
+	return 2
+}
+
+// vimCursor returns a copy of the view's cursor which motions can move around
+func (v *View) vimCursor() *Cursor {
+	return &Cursor{buf: v.Buf, Loc: v.Cursor.Loc, LastVisualX: v.Cursor.LastVisualX}
+}
+
+// vimFirstNonBlank returns the position of the first non-whitespace character of the line
+func vimFirstNonBlank(b *Buffer, y int) int {
+	return Count(GetLeadingWhitespace(b.Line(y)))
+}
+
+// vimWordForward moves the cursor to the start of the next word (w)
+func vimWordForward(c *Cursor, big bool) {
+	if cls := vimClass(c.RuneUnder(c.X), big); cls != 0 {
+		for c.X < Count(c.buf.Line(c.Y)) && vimClass(c.RuneUnder(c.X), big) == cls {
+			c.Right()
+		}
+	}
+	for c.Loc != c.buf.End() && vimClass(c.RuneUnder(c.X), big) == 0 {
+		y := c.Y
+		c.Right()
+		if c.Y != y && Count(c.buf.Line(c.Y)) == 0 {
+			// An empty line counts as a word
+			break
+		}
+	}
+}
+
+// vimWordEnd moves the cursor to the end of the word (e)
+func vimWordEnd(c *Cursor, big bool) {
+	c.Right()
+	for c.Loc != c.buf.End() && vimClass(c.RuneUnder(c.X), big) == 0 {
+		c.Right()
+	}
+	cls := vimClass(c.RuneUnder(c.X), big)
+	for c.X+1 < Count(c.buf.Line(c.Y)) && vimClass(c.RuneUnder(c.X+1), big) == cls {
+		c.Right()
+	}
+}
+
+// vimWordBackward moves the cursor to the start of the previous word (b)
+func vimWordBackward(c *Cursor, big bool) {
+	c.Left()
+	for c.Loc != c.buf.Start() && vimClass(c.RuneUnder(c.X), big) == 0 {
+		if Count(c.buf.Line(c.Y)) == 0 {
+			return
+		}
+		c.Left()
+	}
+	cls := vimClass(c.RuneUnder(c.X), big)
+	for c.X > 0 && vimClass(c.RuneUnder(c.X-1), big) == cls {
+		c.Left()
+	}
+}
+
+// vimFindInLine finds the count'th occurrence of r on the cursor's line (f, F, t, T)
+func vimFindInLine(c *Cursor, motion rune, r rune, count int) bool {
+	line := []rune(c.buf.Line(c.Y))
+	x := c.X
+	forward := motion == 'f' || motion == 't'
+	for i := 0; i < count; i++ {
+		start := x
+		if i == 0 && (motion == 't' || motion == 'T') {
+			// Don't stop right away when the target is next to the cursor
+			if forward {
+				start++
+			} else {
+				start--
+			}
+		}
+		found := false
+		if forward {
+			for j := start + 1; j < len(line); j++ {
+				if line[j] == r {
+					x, found = j, true
+					break
+				}
+			}
+		} else {
+			for j := start - 1; j >= 0; j-- {
+				if line[j] == r {
+					x, found = j, true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	switch motion {
+	case 't':
+		x--
+	case 'T':
+		x++
+	}
+	c.X = x
+	return true
+}
+
+// vimBrackets maps the keys of bracket text objects to the brackets
+var vimBrackets = map[rune][2]rune{
+	'(': {'(', ')'}, ')': {'(', ')'}, 'b': {'(', ')'},
+	'{': {'{', '}'}, '}': {'{', '}'}, 'B': {'{', '}'},
+	'[': {'[', ']'}, ']': {'[', ']'},
+	'<': {'<', '>'}, '>': {'<', '>'},
+}
+
+// vimFindOpen searches backwards from before loc for the unmatched opening bracket
+func vimFindOpen(b *Buffer, loc Loc, open, close rune) (Loc, bool) {
+	depth := 0
+	for y := loc.Y; y >= 0; y-- {
+		line := []rune(b.Line(y))
+		x := len(line) - 1
+		if y == loc.Y {
+			x = Min(loc.X-1, len(line)-1)
+		}
+		for ; x >= 0; x-- {
+			switch line[x] {
+			case close:
+				depth++
+			case open:
+				if depth == 0 {
+					return Loc{x, y}, true
+				}
+				depth--
+			}
+		}
+	}
+	return Loc{}, false
+}
+
+// vimFindClose searches forwards from after loc for the unmatched closing bracket
+func vimFindClose(b *Buffer, loc Loc, open, close rune) (Loc, bool) {
+	depth := 0
+	for y := loc.Y; y < b.NumLines; y++ {
+		line := []rune(b.Line(y))
+		x := 0
+		if y == loc.Y {
+			x = loc.X + 1
+		}
+		for ; x < len(line); x++ {
+			switch line[x] {
+			case open:
+				depth++
+			case close:
+				if depth == 0 {
+					return Loc{x, y}, true
+				}
+				depth--
+			}
+		}
+	}
+	return Loc{}, false
+}
+
+// vimMatchPair moves the cursor to the bracket matching the first one at or after it (%)
+func vimMatchPair(c *Cursor) bool {
+	line := []rune(c.buf.Line(c.Y))
+	for x := c.X; x < len(line); x++ {
+		for _, pair := range [][2]rune{{'(', ')'}, {'[', ']'}, {'{', '}'}} {
+			var loc Loc
+			var ok bool
+			if line[x] == pair[0] {
+				loc, ok = vimFindClose(c.buf, Loc{x, c.Y}, pair[0], pair[1])
+			} else if line[x] == pair[1] {
+				loc, ok = vimFindOpen(c.buf, Loc{x, c.Y}, pair[0], pair[1])
+			} else {
+				continue
+			}
+			if ok {
+				c.Loc = loc
+			}
+			return ok
+		}
+	}
+	return false
+}
+
+// vimMotion returns where the motion takes the cursor and what kind of motion it is
+// The operator is needed because a few motions behave differently after one
+func (v *View) vimMotion(motion string, arg rune, count int, operator rune) (Loc, int, bool) {
+	n := Max(count, 1)
+	c := v.vimCursor()
+	kind := vimExclusive
+	switch motion {
+	case "h":
+		c.X = Max(c.X-n, 0)
+	case "l":
+		c.X = Min(c.X+n, Count(v.Buf.Line(c.Y)))
+	case "j":
+		c.DownN(n)
+		kind = vimLinewise
+	case "k":
+		c.UpN(n)
+		kind = vimLinewise
+	case "w", "W":
+		for i := 0; i < n; i++ {
+			y := c.Y
+			vimWordForward(c, motion == "W")
+			if operator != 0 && i == n-1 && c.Y > y && c.Y > v.Cursor.Y {
+				// An operator stops at the end of the line of the last word
+				c.Y = y
+				c.End()
+			}
+		}
+	case "e", "E":
+		for i := 0; i < n; i++ {
+			vimWordEnd(c, motion == "E")
+		}
+		kind = vimInclusive
+	case "b", "B":
+		for i := 0; i < n; i++ {
+			vimWordBackward(c, motion == "B")
+		}
+	case "gj", "gk":
+		if motion == "gk" {
+			n = -n
+		}
+		v.moveDisplayLines(c, n)
+	case "0":
+		c.X = 0
+	case "g0":
+		c.X, _ = v.displayLineBounds(c)
+	case "g$":
+		_, c.X = v.displayLineBounds(c)
+		if c.X < Count(v.Buf.Line(c.Y)) {
+			// The row ends with a character rather than the end of the line
+			kind = vimInclusive
+		}
+	case "^":
+		c.X = vimFirstNonBlank(v.Buf, c.Y)
+	case "$":
+		c.DownN(n - 1)
+		c.End()
+	case "G", "gg":
+		y := v.Buf.NumLines - 1
+		if motion == "gg" {
+			y = 0
+		}
+		if count > 0 {
+			y = Min(count-1, v.Buf.NumLines-1)
+		}
+		c.Loc = Loc{vimFirstNonBlank(v.Buf, y), y}
+		kind = vimLinewise
+	case "%":
+		if !vimMatchPair(c) {
+			return c.Loc, kind, false
+		}
+		kind = vimInclusive
+	case "f", "t", "F", "T":
+		if !vimFindInLine(c, rune(motion[0]), arg, n) {
+			return c.Loc, kind, false
+		}
+		if motion == "f" || motion == "t" {
+			kind = vimInclusive
+		}
+	default:
+		return c.Loc, kind, false
+	}
+	return c.Loc, kind, true
+}
+
+// vimTextObject returns the range of a text object around the cursor
+// For linewise text objects only the lines of start and end are meaningful, and
+// both are included. Otherwise end is exclusive
+func (v *View) vimTextObject(object string) (start, end Loc, linewise, ok bool) {
+	inner := object[0] == 'i'
+	kind := rune(object[1])
+	y := v.Cursor.Y
+	line := []rune(v.Buf.Line(y))
+
+	switch kind {
+	case 'w', 'W':
+		if len(line) == 0 {
+			return
+		}
+		big := kind == 'W'
+		x := Min(v.Cursor.X, len(line)-1)
+		cls := vimClass(line[x], big)
+		s, e := x, x+1
+		for s > 0 && vimClass(line[s-1], big) == cls {
+			s--
+		}
+		for e < len(line) && vimClass(line[e], big) == cls {
+			e++
+		}
+		if !inner && cls != 0 {
+			// Include the whitespace after the word, or before it if there is none after
+			if e < len(line) && vimClass(line[e], big) == 0 {
+				for e < len(line) && vimClass(line[e], big) == 0 {
+					e++
+				}
+			} else {
+				for s > 0 && vimClass(line[s-1], big) == 0 {
+					s--
+				}
+			}
+		}
+		return Loc{s, y}, Loc{e, y}, false, true
+	case '"', '\'', '`':
+		var quotes []int
+		for x, r := range line {
+			if r == kind && (x == 0 || line[x-1] != '\\') {
+				quotes = append(quotes, x)
+			}
+		}
+		for i := 0; i+1 < len(quotes); i += 2 {
+			if v.Cursor.X <= quotes[i+1] {
+				if inner {
+					return Loc{quotes[i] + 1, y}, Loc{quotes[i+1], y}, false, true
+				}
+				return Loc{quotes[i], y}, Loc{quotes[i+1] + 1, y}, false, true
+			}
+		}
+		return
+	case 'p':
+		blank := func(y int) bool {
+			return strings.TrimSpace(v.Buf.Line(y)) == ""
+		}
+		b := blank(y)
+		s, e := y, y
+		for s > 0 && blank(s-1) == b {
+			s--
+		}
+		for e < v.Buf.NumLines-1 && blank(e+1) == b {
+			e++
+		}
+		if !inner {
+			if e < v.Buf.NumLines-1 {
+				for e < v.Buf.NumLines-1 && blank(e+1) != b {
+					e++
+				}
+			} else {
+				for s > 0 && blank(s-1) != b {
+					s--
+				}
+			}
+		}
+		return Loc{0, s}, Loc{0, e}, true, true
+	}
+
+	pair, isBracket := vimBrackets[kind]
+	if !isBracket {
+		return
+	}
+	cur := v.Cursor.Loc
+	if cur.X < len(line) && line[cur.X] == pair[0] {
+		// On the opening bracket itself
+		cur.X++
+	}
+	open, ok := vimFindOpen(v.Buf, cur, pair[0], pair[1])
+	if !ok {
+		return
+	}
+	close, ok := vimFindClose(v.Buf, open, pair[0], pair[1])
+	if !ok {
+		return
+	}
+	if !inner {
+		return open, Loc{close.X + 1, close.Y}, false, true
+	}
+	if close.Y-open.Y >= 2 && open.X == Count(v.Buf.Line(open.Y))-1 &&
+		strings.TrimSpace(string([]rune(v.Buf.Line(close.Y))[:close.X])) == "" {
+		// The brackets are on their own lines so the inside is made of whole lines
+		return Loc{0, open.Y + 1}, Loc{0, close.Y - 1}, true, true
+	}
+	return Loc{open.X + 1, open.Y}, close, false, true
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A WorkspaceSymbol is a declaration in one of the Go packages of the workspace
+type WorkspaceSymbol struct {
+	Symbol
+	Package string
+	// The absolute path of the file
+	Path string
+}
+
+// QualifiedName returns the name of the symbol as pkg.Type.Method
+func (s WorkspaceSymbol) QualifiedName() string {
+	return s.Package + "." + s.FullName()
+}
+
+// workspaceIndex holds the symbols of every Go file under the workspace root
+// It is built in the background and only changed by jobs on the main goroutine
+type workspaceIndex struct {
+	root     string
+	files    map[string][]WorkspaceSymbol
+	building bool
+}
+
+var workspace workspaceIndex
+
+// workspaceRoot returns the directory of the go.mod file above the working
+// directory, or the working directory if there is none
+func workspaceRoot() string {
+	if root := goModuleRoot(); root != "" {
+		return root
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return wd
+}
+
+// goModuleRoot returns the directory of the go.mod file above the working
+// directory, or an empty string if there is none
+func goModuleRoot() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for dir := wd; ; {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// skipIndexDir returns whether a directory is left out of the workspace index
+func skipIndexDir(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+		name == "vendor" || name == "testdata" || name == "node_modules"
+}
+
+// goFileSymbols returns the symbols of a Go source file as workspace symbols
+func goFileSymbols(path, pkgName, src string) []WorkspaceSymbol {
+	var symbols []WorkspaceSymbol
+	for _, s := range GoSymbols(src) {
+		symbols = append(symbols, WorkspaceSymbol{s, pkgName, path})
+	}
+	return symbols
+}
+
+// indexGoPackages parses the Go packages under root, including their tests,
+// and returns the symbols of each file, exported or not
+func indexGoPackages(root string) map[string][]WorkspaceSymbol {
+	files := make(map[string][]WorkspaceSymbol)
+	filepath.Walk(root, func(dir string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if dir != root && skipIndexDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		buildPkg, err := build.ImportDir(dir, 0)
+		if err != nil {
+			return nil
+		}
+		add := func(names []string, pkgName string) {
+			for _, name := range names {
+				path := filepath.Join(dir, name)
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					continue
+				}
+				files[path] = goFileSymbols(path, pkgName, string(data))
+			}
+		}
+		add(buildPkg.GoFiles, buildPkg.Name)
+		add(buildPkg.TestGoFiles, buildPkg.Name)
+		add(buildPkg.XTestGoFiles, buildPkg.Name+"_test")
+		return nil
+	})
+	return files
+}
+
+// IndexWorkspace starts building the workspace symbol index in the background
+// unless it is being built or is built for the current workspace root
+func IndexWorkspace() {
+	root := workspaceRoot()
+	if workspace.building || (workspace.files != nil && workspace.root == root) {
+		return
+	}
+	workspace.building = true
+	go func() {
+		files := indexGoPackages(root)
+		jobs <- JobFunction{func(string, ...string) {
+			workspace.root, workspace.files, workspace.building = root, files, false
+		}, "", nil}
+	}()
+}
+
+// refreshWorkspaceFile updates the symbols of a Go file in the workspace index
+// after it was saved
+func refreshWorkspaceFile(path, text string) {
+	if workspace.files == nil || !strings.HasPrefix(path, workspace.root+string(filepath.Separator)) {
+		return
+	}
+	go func() {
+		f, err := parser.ParseFile(token.NewFileSet(), path, text, parser.PackageClauseOnly)
+		if err != nil {
+			return
+		}
+		symbols := goFileSymbols(path, f.Name.Name, text)
+		jobs <- JobFunction{func(string, ...string) {
+			workspace.files[path] = symbols
+		}, "", nil}
+	}()
+}
+
+// workingDirPath returns the path relative to the working directory if it is
+// inside of it
+func workingDirPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// workspaceSymbols returns all the symbols of the index sorted by qualified name
+func workspaceSymbols() []WorkspaceSymbol {
+	var symbols []WorkspaceSymbol
+	for _, s := range workspace.files {
+		symbols = append(symbols, s...)
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].QualifiedName() < symbols[j].QualifiedName()
+	})
+	return symbols
+}
+
+// GotoWorkspaceSymbol opens a fuzzy finder of the declarations in all the Go
+// packages of the workspace, searched as pkg.Type.Method
+func (v *View) GotoWorkspaceSymbol(usePlugin bool) bool {
+	if usePlugin && !PreActionCall("GotoWorkspaceSymbol", v) {
+		return false
+	}
+
+	if workspace.files == nil || workspace.root != workspaceRoot() {
+		IndexWorkspace()
+		messenger.Message("Indexing the workspace, try again in a moment")
+	} else {
+		symbols := workspaceSymbols()
+		autocomplete.Open(func(v *View) (messages Messages) {
+			for i, s := range symbols {
+				messages = append(messages, Message{
+					Searchable:       s.QualifiedName(),
+					MessageToDisplay: fmt.Sprintf("%s %s (%s:%d)", s.Kind, s.QualifiedName(), workingDirPath(s.Path), s.Loc.Y+1),
+					Value2:           []byte(strconv.Itoa(i)),
+				})
+			}
+			return messages
+		}, func(message Message) {
+			i, err := strconv.Atoi(string(message.Value2))
+			if err != nil || i >= len(symbols) {
+				return
+			}
+			s := symbols[i]
+			v.recordJump()
+			if s.Path != v.Buf.AbsPath && !v.openFile(s.Path) {
+				return
+			}
+			v.gotoSymbolLoc(s.Loc)
+		}, nil, v)
+	}
+
+	if usePlugin {
+		return PostActionCall("GotoWorkspaceSymbol", v)
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestIndexGoPackages(t *testing.T) {
+	root, err := ioutil.TempDir("", "micro-workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"server/server.go":     "package server\n\ntype Server struct{}\n\nfunc (s *Server) Handle() {}\n\nfunc newServer() *Server { return nil }\n",
+		"server/vendor/v.go":   "package v\n\nfunc Vendored() {}\n",
+		"main.go":              "package main\n\nvar debug bool\n",
+		"main_test.go":         "package main\n\nfunc helper() {}\n",
+		".hidden/h.go":         "package h\n\nfunc Hidden() {}\n",
+		"server/testdata/t.go": "package t\n\nfunc Data() {}\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(src), 0644)
+	}
+
+	var names []string
+	for _, symbols := range indexGoPackages(root) {
+		for _, s := range symbols {
+			names = append(names, s.QualifiedName())
+		}
+	}
+	sort.Strings(names)
+	expected := []string{"main.debug", "main.helper", "server.Server", "server.Server.Handle", "server.newServer"}
+	if len(names) != len(expected) {
+		t.Fatalf("indexGoPackages found %v, want %v", names, expected)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("Symbol %d is %s, want %s", i, names[i], expected[i])
+		}
+	}
+}
+
+func TestWorkspaceRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "micro-workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	root, _ = filepath.EvalSymlinks(root)
+	sub := filepath.Join(root, "mod", "pkg")
+	os.MkdirAll(sub, 0755)
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	os.Chdir(sub)
+	if r := goModuleRoot(); r != "" {
+		t.Errorf("goModuleRoot without go.mod: got %q", r)
+	}
+	if r := workspaceRoot(); r != sub {
+		t.Errorf("workspaceRoot without go.mod: got %q, want %q", r, sub)
+	}
+	ioutil.WriteFile(filepath.Join(root, "mod", "go.mod"), []byte("module mod\n"), 0644)
+	if r, want := goModuleRoot(), filepath.Join(root, "mod"); r != want {
+		t.Errorf("goModuleRoot: got %q, want %q", r, want)
+	}
+	if r, want := workspaceRoot(), filepath.Join(root, "mod"); r != want {
+		t.Errorf("workspaceRoot: got %q, want %q", r, want)
+	}
+}